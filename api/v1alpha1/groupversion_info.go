@@ -0,0 +1,20 @@
+// Package v1alpha1 包含 shepherd.io v1alpha1 API 组的类型定义
+// +kubebuilder:object:generate=true
+// +groupName=shepherd.io
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/scheme"
+)
+
+var (
+	// GroupVersion 注册对象使用的 group version
+	GroupVersion = schema.GroupVersion{Group: "shepherd.io", Version: "v1alpha1"}
+
+	// SchemeBuilder 用于将 go 类型注册到 GroupVersionKind scheme
+	SchemeBuilder = &scheme.Builder{GroupVersion: GroupVersion}
+
+	// AddToScheme 将该 group version 的类型添加到 scheme
+	AddToScheme = SchemeBuilder.AddToScheme
+)
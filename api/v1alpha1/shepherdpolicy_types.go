@@ -0,0 +1,121 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ShepherdPolicySpec 声明一组工作负载的监控策略
+type ShepherdPolicySpec struct {
+	// Priority 多个策略同时命中同一 Pod 时，优先级高的生效
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+
+	// NodeSelector 限定应用该策略的节点，为空表示全部节点
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Selector 选择策略作用的工作负载，为空时策略作为节点默认配置
+	// +optional
+	Selector *WorkloadSelector `json:"selector,omitempty"`
+
+	// Thresholds 延迟阈值
+	// +optional
+	Thresholds Thresholds `json:"thresholds,omitempty"`
+
+	// Sampling 采样配置
+	// +optional
+	Sampling Sampling `json:"sampling,omitempty"`
+
+	// Output 命中工作负载的事件输出目标，连接参数沿用 agent 配置
+	// +optional
+	Output *OutputTarget `json:"output,omitempty"`
+}
+
+// WorkloadSelector 按命名空间与 Pod 标签选择工作负载
+type WorkloadSelector struct {
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+	// +optional
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+	// LabelSelectors 标签选择器，满足任意一个即可
+	// +optional
+	LabelSelectors []string `json:"labelSelectors,omitempty"`
+}
+
+// Thresholds 延迟阈值配置
+type Thresholds struct {
+	// LatencyNs 调度延迟超过该值才输出事件，0 表示使用 agent 默认值
+	// +optional
+	LatencyNs uint64 `json:"latencyNs,omitempty"`
+}
+
+// Sampling 采样配置
+type Sampling struct {
+	// Ratio 高频事件下按 1/Ratio 采样，1 表示不采样，0 表示使用 agent 默认值
+	// +optional
+	Ratio uint32 `json:"ratio,omitempty"`
+}
+
+// OutputTarget 事件输出目标
+type OutputTarget struct {
+	// +kubebuilder:validation:Enum=file;stdout;kafka;clickhouse
+	Type string `json:"type"`
+}
+
+// NodeApplyStatus 记录策略在某个节点上的应用情况
+type NodeApplyStatus struct {
+	NodeName           string      `json:"nodeName"`
+	ObservedGeneration int64       `json:"observedGeneration"`
+	AppliedAt          metav1.Time `json:"appliedAt"`
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	// ConditionAccepted 策略能否转换为生效规则，为 False 时策略不会在任何节点生效
+	ConditionAccepted = "Accepted"
+
+	ReasonValid       = "Valid"
+	ReasonInvalidSpec = "InvalidSpec"
+)
+
+// ShepherdPolicyStatus 策略状态
+type ShepherdPolicyStatus struct {
+	// AppliedNodes 已应用该策略的节点
+	// +optional
+	AppliedNodes []NodeApplyStatus `json:"appliedNodes,omitempty"`
+
+	// Conditions 策略的校验结果
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=sp
+// +kubebuilder:printcolumn:name="Priority",type=integer,JSONPath=`.spec.priority`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ShepherdPolicy 集群级的调度延迟监控策略
+type ShepherdPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ShepherdPolicySpec   `json:"spec,omitempty"`
+	Status ShepherdPolicyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ShepherdPolicyList ShepherdPolicy 列表
+type ShepherdPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ShepherdPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ShepherdPolicy{}, &ShepherdPolicyList{})
+}
@@ -10,8 +10,6 @@
 #include "bpf/bpf_endian.h"
 #include "bpf/bpf_ipv6.h"
 
-extern int LINUX_KERNEL_VERSION __kconfig;
-
 // 定义数据结构来存储调度延迟信息
 struct sched_latency_t
 {
@@ -24,24 +22,96 @@ struct sched_latency_t
     __u64 is_preempt;          // 是否抢占(0: 否, 1: 是)
     char comm[16];             // 进程名
     __u32 preempted_pid_state; // 被抢占的进程状态
+    __u64 cgroup_id;           // 进程所属 cgroup ID
+    __u64 preempted_cgroup_id; // 被抢占进程所属 cgroup ID
+    __u32 cpu;                 // 本次运行所在 CPU
+    __s32 prev_cpu;            // 上次运行所在 CPU，-1 表示未知
+    __s32 prio;                // 内核优先级
+    __s32 nice;                // nice 值
+    __u32 policy;              // 调度策略 SCHED_*
+    __s32 kern_stack_id;           // 进程上次被切出时的内核栈，-1 表示未采集
+    __s32 user_stack_id;           // 进程上次被切出时的用户栈
+    __s32 preempted_kern_stack_id; // 抢占进程在抢占点的内核栈
+    __s32 preempted_user_stack_id; // 抢占进程在抢占点的用户栈
+    __u32 latency_type;            // 0: 唤醒延迟 1: 被抢占后重新入队的等待延迟
+    __u32 waker_pid;               // 唤醒方进程ID，中断上下文中为被中断的进程
+    __u32 waker_tid;               // 唤醒方线程ID
+    char waker_comm[16];           // 唤醒方进程名
+    __s32 waker_cpu;               // 唤醒发生的 CPU，-1 表示无唤醒方
+    __u32 waker_ctx;               // 唤醒上下文 WAKER_*
+    __u32 idle_cpus;               // 等待期间始终空闲且允许运行该进程的其他 CPU 数量
+    __u32 migrations;              // 等待期间被迁移的次数
+    __u64 hardirq_ns;              // 等待期间所在 CPU 处理硬中断的时间，等待期间被迁移时为 0
+    __u64 softirq_ns;              // 等待期间所在 CPU 处理软中断的时间，等待期间被迁移时为 0
 } __attribute__((packed));
 
 struct sched_latency_t *unused_sched_latency_t __attribute__((unused));
 
-// 定义 ring buffer 用于传输数据到用户空间
+// 调度延迟采集策略，字段为 0 时使用默认值
+struct sched_policy_t
+{
+    __u64 threshold_ns;   // 延迟阈值(纳秒)
+    __u64 sampling_ratio; // 高频事件采样率 1/N
+    __u32 aggregate;      // 直方图聚合 0: 关闭 1: 按 cgroup 2: 按 tgid，仅节点默认策略生效
+    __u32 disable_events; // 1: 不输出原始事件，仅节点默认策略生效
+    __u32 capture_stacks; // 1: 采集调用栈，仅节点默认策略生效
+    __u32 runqueue_stats; // 1: 采样各 CPU 运行队列长度，仅节点默认策略生效
+};
+
+struct sched_policy_t *unused_sched_policy_t __attribute__((unused));
+
+// 定义 perf buffer 用于传输数据到用户空间
 struct
 {
     __uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
     __uint(max_entries, 256 * 1024);
 } sched_events SEC(".maps");
 
-// 用于临时存储唤醒时间的 hash map
+// 内核支持时改用 ring buffer 传输，不支持时用户态将其替换为占位的数组 map
+struct
+{
+    __uint(type, BPF_MAP_TYPE_RINGBUF);
+    __uint(max_entries, 256 * 1024);
+} sched_events_rb SEC(".maps");
+
+// 由用户态在加载前按内核特性设置，只对 tp_btf 变体生效
+volatile const __u32 use_ringbuf = 0;
+
+#define LATENCY_WAKEUP 0
+#define LATENCY_REQUEUE 1
+
+#define MAX_CPUS 1024
+
+// 唤醒上下文，0 表示未知或无唤醒方(重新入队)
+#define WAKER_UNKNOWN 0
+#define WAKER_TASK 1
+#define WAKER_HARDIRQ 2
+#define WAKER_SOFTIRQ 3
+#define WAKER_NMI 4
+
+// 线程进入运行队列的时间、原因及唤醒方
+struct enqueue_info_t
+{
+    __u64 ts;
+    __u32 type; // LATENCY_WAKEUP / LATENCY_REQUEUE
+    __u32 waker_pid;
+    __u32 waker_tid;
+    __s32 waker_cpu;
+    __u32 waker_ctx;
+    char waker_comm[16];
+    __u32 migrations; // 入队后被负载均衡迁移的次数
+    __u32 irq_cpu;    // 入队所在 CPU，用于计算等待期间的中断时间
+    __u64 hardirq_ns; // 入队时 irq_cpu 累计的硬中断时间
+    __u64 softirq_ns; // 入队时 irq_cpu 累计的软中断时间
+};
+
+// 用于临时存储入队时间的 hash map
 struct
 {
     __uint(type, BPF_MAP_TYPE_HASH);
     __uint(max_entries, 10240);
     __type(key, __u32);
-    __type(value, __u64);
+    __type(value, struct enqueue_info_t);
 } wakeup_times SEC(".maps");
 
 struct trace_event_raw_sched_wakeup
@@ -75,66 +145,304 @@ struct trace_event_raw_sched_wakeup_new
     __s32 target_cpu;
 } __attribute__((packed));
 
-// 公共函数：处理进程唤醒
-static __always_inline void handle_wakeup(u32 pid)
+// preempt_count 各位域，与 include/linux/preempt.h 一致
+#define PREEMPT_SOFTIRQ_OFFSET 0x00000100
+#define PREEMPT_HARDIRQ_MASK 0x000f0000
+#define PREEMPT_NMI_MASK 0x00f00000
+
+// 经典 tracepoint 公共字段 common_flags 中的上下文标记，与 include/linux/trace_events.h 一致
+#define TRACE_FLAG_HARDIRQ 0x08
+#define TRACE_FLAG_SOFTIRQ 0x10
+#define TRACE_FLAG_NMI 0x40
+
+extern const unsigned long __per_cpu_offset __ksym __weak;
+
+// per-cpu 变量在指定 CPU 上的地址 = 符号地址 + __per_cpu_offset[cpu]，无法获取时返回 NULL
+static __always_inline const char *per_cpu_addr(const char *sym, __u32 cpu)
 {
-    if (pid == 0)
+    unsigned long offset = 0;
+
+    if (!sym || !&__per_cpu_offset)
+        return 0;
+    if (bpf_probe_read_kernel(&offset, sizeof(offset), &__per_cpu_offset + cpu))
+        return 0;
+    return sym + offset;
+}
+
+// x86 的 preempt_count 是 per-cpu 变量：6.2 之前及 6.15 之后为 __preempt_count，期间位于 pcpu_hot
+extern const int __preempt_count __ksym __weak;
+extern const char pcpu_hot __ksym __weak;
+
+struct pcpu_hot___shepherd
+{
+    int preempt_count;
+} __attribute__((preserve_access_index));
+
+// arm64 的 preempt_count 位于 thread_info
+struct thread_info___arm64
+{
+    __u64 preempt_count;
+} __attribute__((preserve_access_index));
+
+struct task_struct___arm64
+{
+    struct thread_info___arm64 thread_info;
+} __attribute__((preserve_access_index));
+
+// 读取当前 CPU 的 preempt_count，无法获取时返回 -1
+static __always_inline int get_preempt_count(void)
+{
+#if defined(__TARGET_ARCH_arm64)
+    struct task_struct___arm64 *task = (void *)bpf_get_current_task();
+    __u64 count = 0;
+
+    if (bpf_core_read(&count, sizeof(count), &task->thread_info.preempt_count))
+        return -1;
+    return (int)count;
+#else
+    const char *base = 0;
+    int count = 0;
+
+    if (&__preempt_count)
+        base = (const char *)&__preempt_count;
+    else if (&pcpu_hot && bpf_core_type_exists(struct pcpu_hot___shepherd))
+        base = &pcpu_hot + bpf_core_field_offset(struct pcpu_hot___shepherd, preempt_count);
+
+    base = per_cpu_addr(base, bpf_get_smp_processor_id());
+    if (!base || bpf_probe_read_kernel(&count, sizeof(count), base))
+        return -1;
+    return count;
+#endif
+}
+
+static __always_inline __u32 waker_ctx_from_preempt_count(int count)
+{
+    if (count < 0)
+        return WAKER_UNKNOWN;
+    if (count & PREEMPT_NMI_MASK)
+        return WAKER_NMI;
+    if (count & PREEMPT_HARDIRQ_MASK)
+        return WAKER_HARDIRQ;
+    if (count & PREEMPT_SOFTIRQ_OFFSET)
+        return WAKER_SOFTIRQ;
+    return WAKER_TASK;
+}
+
+static __always_inline __u32 waker_ctx_from_trace_flags(__u8 flags)
+{
+    if (flags & TRACE_FLAG_NMI)
+        return WAKER_NMI;
+    if (flags & TRACE_FLAG_HARDIRQ)
+        return WAKER_HARDIRQ;
+    if (flags & TRACE_FLAG_SOFTIRQ)
+        return WAKER_SOFTIRQ;
+    return WAKER_TASK;
+}
+
+#define IRQ_HARDIRQ 0
+#define IRQ_SOFTIRQ 1
+
+// 每个 CPU 累计的中断处理时间，仅在开启中断跟踪后更新
+struct cpu_irq_time_t
+{
+    __u64 hardirq_ns;
+    __u64 softirq_ns;
+};
+
+struct
+{
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, MAX_CPUS);
+    __type(key, __u32);
+    __type(value, struct cpu_irq_time_t);
+} cpu_irq_time SEC(".maps");
+
+struct cpu_irq_time_t *unused_cpu_irq_time_t __attribute__((unused));
+
+// 当前 CPU 正在处理的中断
+struct irq_state_t
+{
+    __u64 hardirq_ts; // 硬中断开始时间，0 表示不在硬中断中
+    __u64 softirq_ts; // 软中断开始时间，0 表示不在软中断中
+    __u64 nested_ns;  // 软中断处理期间嵌套的硬中断时间
+};
+
+struct
+{
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, __u32);
+    __type(value, struct irq_state_t);
+} irq_state SEC(".maps");
+
+struct irq_key_t
+{
+    __u32 type; // IRQ_HARDIRQ / IRQ_SOFTIRQ
+    __u32 vec;  // 硬中断号或软中断向量
+};
+
+struct irq_vec_stat_t
+{
+    __u64 count;
+    __u64 time_ns;
+};
+
+struct irq_key_t *unused_irq_key_t __attribute__((unused));
+struct irq_vec_stat_t *unused_irq_vec_stat_t __attribute__((unused));
+
+// 按中断号与软中断向量累计的处理次数与时间
+struct
+{
+    __uint(type, BPF_MAP_TYPE_PERCPU_HASH);
+    __uint(max_entries, 1024);
+    __type(key, struct irq_key_t);
+    __type(value, struct irq_vec_stat_t);
+} irq_vec_time SEC(".maps");
+
+static __always_inline void account_irq(__u32 type, __u32 vec, __u64 delta)
+{
+    __u32 cpu = bpf_get_smp_processor_id();
+    struct cpu_irq_time_t *total = bpf_map_lookup_elem(&cpu_irq_time, &cpu);
+    if (total)
     {
-        return;
+        if (type == IRQ_HARDIRQ)
+            total->hardirq_ns += delta;
+        else
+            total->softirq_ns += delta;
+    }
+
+    struct irq_key_t key = {.type = type, .vec = vec};
+    struct irq_vec_stat_t *stat = bpf_map_lookup_elem(&irq_vec_time, &key);
+    if (!stat)
+    {
+        struct irq_vec_stat_t zero = {};
+        bpf_map_update_elem(&irq_vec_time, &key, &zero, BPF_NOEXIST);
+        stat = bpf_map_lookup_elem(&irq_vec_time, &key);
+        if (!stat)
+            return;
     }
+    stat->count++;
+    stat->time_ns += delta;
+}
 
-    __u64 ts = bpf_ktime_get_ns();
-    bpf_map_update_elem(&wakeup_times, &pid, &ts, BPF_ANY);
+static __always_inline void handle_hardirq_entry(void)
+{
+    __u32 key = 0;
+    struct irq_state_t *state = bpf_map_lookup_elem(&irq_state, &key);
+    if (state)
+        state->hardirq_ts = bpf_ktime_get_ns();
 }
 
-#if LINUX_KERNEL_VERSION >= KERNEL_VERSION(5, 10, 0)
-SEC("tp_btf/sched_wakeup")
-int sched_wakeup(u64 *ctx)
+static __always_inline void handle_hardirq_exit(__u32 irq)
 {
-    struct task_struct *task = (void *)ctx[0];
-    handle_wakeup(task->pid);
+    __u32 key = 0;
+    struct irq_state_t *state = bpf_map_lookup_elem(&irq_state, &key);
+    if (!state || !state->hardirq_ts)
+        return;
+
+    __u64 delta = bpf_ktime_get_ns() - state->hardirq_ts;
+    state->hardirq_ts = 0;
+    if (state->softirq_ts)
+        state->nested_ns += delta;
+    account_irq(IRQ_HARDIRQ, irq, delta);
+}
+
+static __always_inline void handle_softirq_entry(void)
+{
+    __u32 key = 0;
+    struct irq_state_t *state = bpf_map_lookup_elem(&irq_state, &key);
+    if (!state)
+        return;
+
+    state->softirq_ts = bpf_ktime_get_ns();
+    state->nested_ns = 0;
+}
+
+static __always_inline void handle_softirq_exit(__u32 vec)
+{
+    __u32 key = 0;
+    struct irq_state_t *state = bpf_map_lookup_elem(&irq_state, &key);
+    if (!state || !state->softirq_ts)
+        return;
+
+    // 软中断时间不含其间嵌套的硬中断
+    __u64 delta = bpf_ktime_get_ns() - state->softirq_ts;
+    delta = delta > state->nested_ns ? delta - state->nested_ns : 0;
+    state->softirq_ts = 0;
+    account_irq(IRQ_SOFTIRQ, vec, delta);
+}
+
+// tp_btf 与经典 tracepoint 两种变体由用户态按内核特性选择其一加载
+SEC("tp_btf/irq_handler_entry")
+int irq_handler_entry(u64 *ctx)
+{
+    handle_hardirq_entry();
     return 0;
 }
 
-SEC("tp_btf/sched_wakeup_new")
-int sched_wakeup_new(u64 *ctx)
+SEC("tp_btf/irq_handler_exit")
+int irq_handler_exit(u64 *ctx)
 {
-    struct task_struct *task = (void *)ctx[0];
-    handle_wakeup(task->pid);
+    handle_hardirq_exit((__u32)ctx[0]);
     return 0;
 }
-#else
-SEC("tp/sched/sched_wakeup")
-int sched_wakeup(struct trace_event_raw_sched_wakeup *ctx)
+
+SEC("tp_btf/softirq_entry")
+int softirq_entry(u64 *ctx)
 {
-    handle_wakeup(ctx->pid);
+    handle_softirq_entry();
     return 0;
 }
 
-SEC("tp/sched/sched_wakeup_new")
-int sched_wakeup_new(struct trace_event_raw_sched_wakeup_new *ctx)
+SEC("tp_btf/softirq_exit")
+int softirq_exit(u64 *ctx)
 {
-    handle_wakeup(ctx->pid);
+    handle_softirq_exit((__u32)ctx[0]);
     return 0;
 }
-#endif
 
-// 定义流控相关的常量和map
-#define SAMPLING_RATIO 100   // 采样率 1/100
-#define THRESHOLD_NS 1000000 // 延迟阈值 1ms
+SEC("tp/irq/irq_handler_entry")
+int irq_handler_entry_tp(void *ctx)
+{
+    handle_hardirq_entry();
+    return 0;
+}
 
-// 用于记录每个 CPU 的最后一次采样时间
-struct
+SEC("tp/irq/irq_handler_exit")
+int irq_handler_exit_tp(struct trace_event_raw_irq_handler_exit *ctx)
 {
-    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
-    __uint(max_entries, 1);
-    __type(key, u32);
-    __type(value, u64);
-} last_sample SEC(".maps");
+    handle_hardirq_exit(ctx->irq);
+    return 0;
+}
 
-#define TASK_RUNNING 0
+SEC("tp/irq/softirq_entry")
+int softirq_entry_tp(void *ctx)
+{
+    handle_softirq_entry();
+    return 0;
+}
+
+SEC("tp/irq/softirq_exit")
+int softirq_exit_tp(struct trace_event_raw_softirq *ctx)
+{
+    handle_softirq_exit(ctx->vec);
+    return 0;
+}
+
+// 记录入队时所在 CPU 累计的中断时间，出队时的差值即等待期间的中断时间
+static __always_inline void snapshot_irq_time(struct enqueue_info_t *info, __u32 cpu)
+{
+    struct cpu_irq_time_t *total = bpf_map_lookup_elem(&cpu_irq_time, &cpu);
 
-u64 get_task_cgroup_id(struct task_struct *task)
+    info->irq_cpu = cpu;
+    if (total)
+    {
+        info->hardirq_ns = total->hardirq_ns;
+        info->softirq_ns = total->softirq_ns;
+    }
+}
+
+static __always_inline u64 get_task_cgroup_id(struct task_struct *task)
 {
     u64 cgroup_id = 0;
     struct css_set *cgroups;
@@ -149,114 +457,1141 @@ u64 get_task_cgroup_id(struct task_struct *task)
     return cgroup_id;
 }
 
-// 公共函数：处理调度切换事件
-static __always_inline void handle_sched_switch(u32 prev_pid, u32 prev_tgid,
-                                                u32 next_pid, u32 next_tgid, __u32 prev_state,
-                                                const char *prev_comm, const char *next_comm, void *ctx)
+#define FILTER_OFF 0
+#define FILTER_ALLOW 1
+#define FILTER_DENY 2
+#define FILTER_COMM_LEN 16
+
+// 进程过滤模式，由用户态按配置与控制接口写入，修改后立即生效
+struct filter_config_t
 {
-    __u64 *wakeup_ts;
-    __u64 now = bpf_ktime_get_ns();
+    __u32 tgid_mode;
+    __u32 comm_mode;
+    __u32 cgroup_mode;
+    __u32 pad;
+};
 
-    if (prev_pid == 0 || next_pid == 0)
-    {
-        return;
-    }
+struct filter_config_t *unused_filter_config_t __attribute__((unused));
 
-    // 查找进程的唤醒时间
-    wakeup_ts = bpf_map_lookup_elem(&wakeup_times, &next_pid);
-    if (!wakeup_ts)
-        return;
+struct
+{
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, __u32);
+    __type(value, struct filter_config_t);
+} filter_config SEC(".maps");
 
-    // 计算调度延迟
-    __u64 delay = now - *wakeup_ts;
+struct
+{
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 4096);
+    __type(key, __u32);
+    __type(value, __u8);
+} filter_tgids SEC(".maps");
 
-    // 流控逻辑开始
+struct
+{
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 4096);
+    __type(key, __u64);
+    __type(value, __u8);
+} filter_cgroups SEC(".maps");
+
+// 进程名前缀按最长前缀匹配，prefixlen 以比特为单位
+struct filter_comm_key_t
+{
+    __u32 prefixlen;
+    char comm[FILTER_COMM_LEN];
+};
+
+struct filter_comm_key_t *unused_filter_comm_key_t __attribute__((unused));
+
+struct
+{
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __uint(max_entries, 1024);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+    __type(key, struct filter_comm_key_t);
+    __type(value, __u8);
+} filter_comms SEC(".maps");
+
+static __always_inline bool filter_verdict(__u32 mode, bool matched)
+{
+    if (mode == FILTER_ALLOW)
+        return matched;
+    if (mode == FILTER_DENY)
+        return !matched;
+    return true;
+}
+
+// 判断进程是否通过过滤，各维度同时满足才保留；cgroup 未知(0)时不参与过滤
+static __always_inline bool filter_pass(u32 tgid, const char *comm, u64 cgroup_id)
+{
     __u32 key = 0;
-    __u64 *last_ts = bpf_map_lookup_elem(&last_sample, &key);
-    if (!last_ts)
-        return;
+    struct filter_config_t *cfg = bpf_map_lookup_elem(&filter_config, &key);
+    if (!cfg)
+        return true;
 
-    // 基于时间的流控
-    if ((now - *last_ts) < THRESHOLD_NS)
-    {
-        if (bpf_get_prandom_u32() % SAMPLING_RATIO != 0)
-        {
-            bpf_map_delete_elem(&wakeup_times, &next_pid);
-            return;
-        }
-    }
+    if (cfg->tgid_mode != FILTER_OFF &&
+        !filter_verdict(cfg->tgid_mode, bpf_map_lookup_elem(&filter_tgids, &tgid) != NULL))
+        return false;
 
-    // 更新最后采样时间
-    bpf_map_update_elem(&last_sample, &key, &now, BPF_ANY);
+    if (cfg->cgroup_mode != FILTER_OFF && cgroup_id != 0 &&
+        !filter_verdict(cfg->cgroup_mode, bpf_map_lookup_elem(&filter_cgroups, &cgroup_id) != NULL))
+        return false;
 
-    // 延迟阈值过滤
-    if (delay < THRESHOLD_NS)
+    if (cfg->comm_mode != FILTER_OFF)
     {
-        bpf_map_delete_elem(&wakeup_times, &next_pid);
-        return;
+        struct filter_comm_key_t comm_key = {.prefixlen = FILTER_COMM_LEN * 8};
+        bpf_probe_read_kernel_str(&comm_key.comm, sizeof(comm_key.comm), comm);
+        if (!filter_verdict(cfg->comm_mode, bpf_map_lookup_elem(&filter_comms, &comm_key) != NULL))
+            return false;
     }
 
-    // 准备输出数据
-    struct sched_latency_t latency = {
-        .pid = next_tgid ? next_tgid : next_pid, // 如果有 tgid 则使用 tgid，否则使用 pid
-        .tid = next_pid,
-        .delay_ns = delay,
-        .ts = now,
-        .preempted_pid_state = prev_state,
-    };
+    return true;
+}
 
-    bpf_probe_read_kernel_str(&latency.comm, sizeof(latency.comm), next_comm);
+static __always_inline void sample_runqueue(__u32 cpu, __u64 now, bool local);
 
-    // 如果前一个状态是 TASK_RUNNING，则认为是抢占
-    if (prev_state == TASK_RUNNING)
+// 公共函数：处理进程唤醒，唤醒方即当前执行 try_to_wake_up 的上下文
+static __always_inline void handle_wakeup(u32 pid, u32 tgid, const char *comm, u64 cgroup_id,
+                                          __u32 waker_ctx, __u32 target_cpu)
+{
+    if (pid == 0)
     {
-        latency.is_preempt = 1;
-        latency.preempted_pid = prev_tgid ? prev_tgid : prev_pid;
-        bpf_probe_read_kernel_str(&latency.preempted_comm, sizeof(latency.preempted_comm), prev_comm);
+        return;
     }
 
-    bpf_printk("pid: %d, delay: %llu ns, is_preempt: %d\n",
-               latency.pid, latency.delay_ns, latency.is_preempt);
+    // 被过滤的进程不记录唤醒时间，切换时也就不会产生事件
+    if (!filter_pass(tgid, comm, cgroup_id))
+    {
+        return;
+    }
 
-    // 输出到 perf event
-    bpf_perf_event_output(ctx, &sched_events, BPF_F_CURRENT_CPU, &latency, sizeof(latency));
+    __u64 pid_tgid = bpf_get_current_pid_tgid();
+    struct enqueue_info_t info = {
+        .ts = bpf_ktime_get_ns(),
+        .type = LATENCY_WAKEUP,
+        .waker_pid = pid_tgid >> 32,
+        .waker_tid = (__u32)pid_tgid,
+        .waker_cpu = bpf_get_smp_processor_id(),
+        .waker_ctx = waker_ctx,
+    };
+    bpf_get_current_comm(&info.waker_comm, sizeof(info.waker_comm));
+    snapshot_irq_time(&info, target_cpu);
+    bpf_map_update_elem(&wakeup_times, &pid, &info, BPF_ANY);
 
-    // 删除已处理的唤醒时间记录
-    bpf_map_delete_elem(&wakeup_times, &next_pid);
+    // 被唤醒进程已加入目标 CPU 的运行队列
+    sample_runqueue(target_cpu, info.ts, target_cpu == info.waker_cpu);
 }
 
-#if LINUX_KERNEL_VERSION >= KERNEL_VERSION(5, 10, 0)
-SEC("tp_btf/sched_switch")
-int sched_switch(u64 *ctx)
+// 5.16 之前 x86 的 task_struct 没有 cpu 字段，位于 thread_info
+struct thread_info___cpu
 {
-    struct task_struct *prev = (struct task_struct *)ctx[1];
-    struct task_struct *next = (struct task_struct *)ctx[2];
+    __u32 cpu;
+} __attribute__((preserve_access_index));
 
-    u32 prev_pid = BPF_CORE_READ(prev, pid);
-    u32 prev_tgid = BPF_CORE_READ(prev, tgid);
-    u32 next_pid = BPF_CORE_READ(next, pid);
-    u32 next_tgid = BPF_CORE_READ(next, tgid);
+struct task_struct___cpu
+{
+    struct thread_info___cpu thread_info;
+} __attribute__((preserve_access_index));
 
-#if defined(__TARGET_ARCH_x86)
-    __u32 state = BPF_CORE_READ(prev, __state);
-#elif defined(__TARGET_ARCH_arm64)
-    __u32 state = BPF_CORE_READ(prev, state);
-#else
-    __u32 state = BPF_CORE_READ(prev, __state);
-#endif
+// 5.14 起 task_struct.state 改名为 __state，tp_btf 在 5.5 起可用，两种布局都需要支持
+struct task_struct___state
+{
+    unsigned int __state;
+} __attribute__((preserve_access_index));
 
-    handle_sched_switch(prev_pid, prev_tgid, next_pid, next_tgid,
-                        state, prev->comm, next->comm, ctx);
+struct task_struct___old
+{
+    long state;
+} __attribute__((preserve_access_index));
+
+static __always_inline __u32 get_task_state(struct task_struct *task)
+{
+    struct task_struct___state *t = (void *)task;
+    if (bpf_core_field_exists(t->__state))
+        return BPF_CORE_READ(t, __state);
+    return BPF_CORE_READ((struct task_struct___old *)task, state);
+}
+
+static __always_inline __u32 get_task_cpu(struct task_struct *task)
+{
+    if (bpf_core_field_exists(task->cpu))
+        return BPF_CORE_READ(task, cpu);
+    return BPF_CORE_READ((struct task_struct___cpu *)task, thread_info.cpu);
+}
+
+SEC("tp_btf/sched_wakeup")
+int sched_wakeup(u64 *ctx)
+{
+    struct task_struct *task = (void *)ctx[0];
+    handle_wakeup(task->pid, task->tgid, task->comm, get_task_cgroup_id(task),
+                  waker_ctx_from_preempt_count(get_preempt_count()), get_task_cpu(task));
     return 0;
 }
-#else
-SEC("tp/sched/sched_switch")
-int sched_switch(struct trace_event_raw_sched_switch *ctx)
+
+SEC("tp_btf/sched_wakeup_new")
+int sched_wakeup_new(u64 *ctx)
 {
-    handle_sched_switch(ctx->prev_pid, 0, ctx->next_pid, 0,
-                        ctx->prev_state, ctx->prev_comm, ctx->next_comm, ctx);
+    struct task_struct *task = (void *)ctx[0];
+    handle_wakeup(task->pid, task->tgid, task->comm, get_task_cgroup_id(task),
+                  waker_ctx_from_preempt_count(get_preempt_count()), get_task_cpu(task));
+    return 0;
+}
+
+// 经典 tracepoint 只有线程 id，线程所属的 tgid 在其作为 current 运行时记录，线程退出时删除
+struct
+{
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(max_entries, 65536);
+    __type(key, __u32);
+    __type(value, __u32);
+} task_tgids SEC(".maps");
+
+// 经典 tracepoint 切换时无法读取 next 的 cgroup，线程所属的 cgroup 在创建时继承父进程的记录，
+// 并在其作为 current 被切出时更新，线程退出时删除
+struct
+{
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(max_entries, 65536);
+    __type(key, __u32);
+    __type(value, __u64);
+} task_cgroups SEC(".maps");
+
+// 线程退出通知，用户态据此清理 tid -> tgid 缓存
+struct
+{
+    __uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+} task_exits SEC(".maps");
+
+static __always_inline void record_current_tgid(void)
+{
+    __u64 pid_tgid = bpf_get_current_pid_tgid();
+    __u32 tid = (__u32)pid_tgid;
+    __u32 tgid = pid_tgid >> 32;
+
+    if (tid != 0)
+        bpf_map_update_elem(&task_tgids, &tid, &tgid, BPF_ANY);
+}
+
+// 返回线程上次被切出时所属的 cgroup，尚未被切出过的线程返回 0
+static __always_inline __u64 lookup_cgroup(__u32 tid)
+{
+    __u64 *cgroup_id = bpf_map_lookup_elem(&task_cgroups, &tid);
+    return cgroup_id ? *cgroup_id : 0;
+}
+
+// 返回线程的 tgid，尚未作为 current 运行过的线程返回 0，由用户态通过 /proc 解析
+static __always_inline __u32 lookup_tgid(__u32 tid)
+{
+    __u32 *tgid = bpf_map_lookup_elem(&task_tgids, &tid);
+    return tgid ? *tgid : 0;
+}
+
+// 经典 tracepoint 只有线程 id 与进程名，过滤时 tgid 未知则以线程 id 代替，cgroup 不参与过滤
+SEC("tp/sched/sched_wakeup")
+int sched_wakeup_tp(struct trace_event_raw_sched_wakeup *ctx)
+{
+    record_current_tgid();
+
+    __u32 tgid = lookup_tgid(ctx->pid);
+    handle_wakeup(ctx->pid, tgid ? tgid : ctx->pid, ctx->comm, 0,
+                  waker_ctx_from_trace_flags(ctx->common_flags), ctx->target_cpu);
+    return 0;
+}
+
+SEC("tp/sched/sched_wakeup_new")
+int sched_wakeup_new_tp(struct trace_event_raw_sched_wakeup_new *ctx)
+{
+    record_current_tgid();
+
+    __u32 tgid = lookup_tgid(ctx->pid);
+    handle_wakeup(ctx->pid, tgid ? tgid : ctx->pid, ctx->comm, 0,
+                  waker_ctx_from_trace_flags(ctx->common_flags), ctx->target_cpu);
+    return 0;
+}
+
+// 只在经典 tracepoint 变体中挂载，current 即父进程，新线程尚未运行前即可确定其 cgroup
+SEC("tp/sched/sched_process_fork")
+int sched_process_fork_tp(struct trace_event_raw_sched_process_fork *ctx)
+{
+    __u32 child_pid = ctx->child_pid;
+    __u64 cgroup_id = bpf_get_current_cgroup_id();
+
+    bpf_map_update_elem(&task_cgroups, &child_pid, &cgroup_id, BPF_ANY);
+    return 0;
+}
+
+// 只在经典 tracepoint 变体中挂载，current 即退出的线程
+SEC("tp/sched/sched_process_exit")
+int sched_process_exit_tp(struct trace_event_raw_sched_process_template *ctx)
+{
+    __u32 tid = (__u32)bpf_get_current_pid_tgid();
+
+    bpf_map_delete_elem(&task_tgids, &tid);
+    bpf_map_delete_elem(&task_cgroups, &tid);
+    bpf_perf_event_output(ctx, &task_exits, BPF_F_CURRENT_CPU, &tid, sizeof(tid));
+    return 0;
+}
+
+// 等待期间被迁移到其他 CPU 的运行队列，唤醒时 select_task_rq 的迁移发生在 sched_wakeup 之前，不计入
+static __always_inline void handle_migrate(u32 pid)
+{
+    struct enqueue_info_t *info = bpf_map_lookup_elem(&wakeup_times, &pid);
+    if (info)
+        __sync_fetch_and_add(&info->migrations, 1);
+}
+
+SEC("tp_btf/sched_migrate_task")
+int sched_migrate_task(u64 *ctx)
+{
+    struct task_struct *task = (void *)ctx[0];
+    handle_migrate(task->pid);
+    return 0;
+}
+
+SEC("tp/sched/sched_migrate_task")
+int sched_migrate_task_tp(struct trace_event_raw_sched_migrate_task *ctx)
+{
+    handle_migrate(ctx->pid);
+    return 0;
+}
+
+// 定义流控相关的常量和map
+#define SAMPLING_RATIO 100   // 默认采样率 1/100
+#define THRESHOLD_NS 1000000 // 默认延迟阈值 1ms
+
+// 节点默认采集策略
+struct
+{
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, __u32);
+    __type(value, struct sched_policy_t);
+} sched_config SEC(".maps");
+
+// 按 cgroup 覆盖的采集策略，由用户态根据 ShepherdPolicy 维护
+struct
+{
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 65536);
+    __type(key, __u64);
+    __type(value, struct sched_policy_t);
+} cgroup_policies SEC(".maps");
+
+// 用于记录每个 CPU 的最后一次采样时间
+struct
+{
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, u32);
+    __type(value, u64);
+} last_sample SEC(".maps");
+
+#define TASK_RUNNING 0
+// 经典 sched_switch tracepoint 将被抢占的 prev 上报为 TASK_REPORT_MAX（__trace_sched_switch_state），
+// 而非 TASK_RUNNING，与 include/linux/sched.h 一致（TASK_REPORT_IDLE << 1）
+#define TASK_REPORT_MAX 0x100
+
+// 调度策略，与 include/uapi/linux/sched.h 一致
+#define SCHED_NORMAL 0
+#define SCHED_FIFO 1
+#define SCHED_DEADLINE 6
+#define MAX_RT_PRIO 100
+#define DEFAULT_PRIO 120
+
+// 记录线程上次被切出时所在的 CPU
+struct
+{
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(max_entries, 65536);
+    __type(key, __u32);
+    __type(value, __u32);
+} task_last_cpu SEC(".maps");
+
+// 监控范围：开启后仅输出 cgroup 位于 scope_cgroups 中的被延迟进程，由用户态按 Pod 选择器维护
+struct
+{
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 65536);
+    __type(key, __u64);
+    __type(value, __u8);
+} scope_cgroups SEC(".maps");
+
+// 监控范围开关，0: 关闭(全部输出) 1: 开启
+struct
+{
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, __u32);
+    __type(value, __u32);
+} scope_enabled SEC(".maps");
+
+// 调试开关，0: 关闭 1: 开启，开启后通过 bpf_printk 输出每个上报的事件，由用户态读取 trace_pipe 转发到日志
+struct
+{
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, __u32);
+    __type(value, __u32);
+} debug_enabled SEC(".maps");
+
+static __always_inline bool is_debug(void)
+{
+    __u32 key = 0;
+    __u32 *enabled = bpf_map_lookup_elem(&debug_enabled, &key);
+    return enabled && *enabled;
+}
+
+#define AGGREGATE_CGROUP 1
+#define AGGREGATE_TGID 2
+#define HIST_SLOTS 32
+
+// 调度延迟 log2 直方图，第 i 个槽位统计延迟位于 [2^i, 2^(i+1)) 微秒的次数
+struct latency_hist_t
+{
+    __u64 slots[HIST_SLOTS];
+    __u64 count;
+    __u64 sum_ns;
+};
+
+struct latency_hist_t *unused_latency_hist_t __attribute__((unused));
+
+// 按 cgroup id 或 tgid 聚合的直方图，由用户态周期读取并清零
+struct
+{
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 10240);
+    __type(key, __u64);
+    __type(value, struct latency_hist_t);
+} latency_hists SEC(".maps");
+
+static __always_inline __u32 log2_u64(__u64 v)
+{
+    __u32 r = 0;
+
+    if (v >> 32)
+    {
+        v >>= 32;
+        r += 32;
+    }
+    if (v >> 16)
+    {
+        v >>= 16;
+        r += 16;
+    }
+    if (v >> 8)
+    {
+        v >>= 8;
+        r += 8;
+    }
+    if (v >> 4)
+    {
+        v >>= 4;
+        r += 4;
+    }
+    if (v >> 2)
+    {
+        v >>= 2;
+        r += 2;
+    }
+    if (v >> 1)
+        r += 1;
+
+    return r;
+}
+
+static __always_inline void update_hist(__u64 id, __u64 delay_ns)
+{
+    static const struct latency_hist_t zero = {};
+    struct latency_hist_t *hist = bpf_map_lookup_elem(&latency_hists, &id);
+    if (!hist)
+    {
+        bpf_map_update_elem(&latency_hists, &id, &zero, BPF_NOEXIST);
+        hist = bpf_map_lookup_elem(&latency_hists, &id);
+        if (!hist)
+            return;
+    }
+
+    __u64 us = delay_ns / 1000;
+    __u32 slot = us ? log2_u64(us) : 0;
+    if (slot >= HIST_SLOTS)
+        slot = HIST_SLOTS - 1;
+
+    __sync_fetch_and_add(&hist->slots[slot], 1);
+    __sync_fetch_and_add(&hist->count, 1);
+    __sync_fetch_and_add(&hist->sum_ns, delay_ns);
+}
+
+#define STACK_DEPTH 127
+
+// 调用栈，用户态按栈 id 读取并符号化
+struct
+{
+    __uint(type, BPF_MAP_TYPE_STACK_TRACE);
+    __uint(max_entries, 16384);
+    __uint(key_size, sizeof(__u32));
+    __uint(value_size, STACK_DEPTH * sizeof(__u64));
+} stack_traces SEC(".maps");
+
+// 采集调用栈失败的次数。栈 id 复用哈希冲突的槽位，map 不会写满，但冲突时旧 id 会指向新的调用栈
+struct
+{
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, __u32);
+    __type(value, __u64);
+} stack_errors SEC(".maps");
+
+static __always_inline __s32 get_stack_id(void *ctx, __u64 flags)
+{
+    __s32 id = bpf_get_stackid(ctx, &stack_traces, flags | BPF_F_REUSE_STACKID);
+    if (id < 0)
+    {
+        __u32 key = 0;
+        __u64 *errors = bpf_map_lookup_elem(&stack_errors, &key);
+        if (errors)
+            (*errors)++;
+    }
+
+    return id;
+}
+
+struct task_stack_t
+{
+    __s32 kern_stack_id;
+    __s32 user_stack_id;
+};
+
+// 线程上次被切出时的调用栈，作为其再次被调度时的受害者调用栈
+struct
+{
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(max_entries, 65536);
+    __type(key, __u32);
+    __type(value, struct task_stack_t);
+} task_stacks SEC(".maps");
+
+// 每个 CPU 进入 idle 的时间，0 表示正在运行任务
+struct
+{
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, MAX_CPUS);
+    __type(key, __u32);
+    __type(value, __u64);
+} cpu_idle_since SEC(".maps");
+
+extern const unsigned int nr_cpu_ids __ksym __weak;
+
+static __always_inline __u32 get_nr_cpus(void)
+{
+    __u32 nr = MAX_CPUS;
+
+    if (&nr_cpu_ids)
+        bpf_probe_read_kernel(&nr, sizeof(nr), &nr_cpu_ids);
+    if (nr > MAX_CPUS)
+        nr = MAX_CPUS;
+    return nr;
+}
+
+// 统计自 since 起始终空闲且位于 allowed 中的其他 CPU 数量，allowed 为空时视为允许全部 CPU
+static __always_inline __u32 count_idle_cpus(const struct cpumask *allowed, __u32 self, __u64 since)
+{
+    __u32 nr = get_nr_cpus();
+    __u32 idle = 0;
+    __u64 bits = ~0ULL;
+
+    for (__u32 i = 0; i < MAX_CPUS; i++)
+    {
+        if (i >= nr)
+            break;
+        if (allowed && (i % 64) == 0)
+        {
+            if (bpf_probe_read_kernel(&bits, sizeof(bits), (const __u64 *)allowed + i / 64))
+                bits = 0;
+        }
+        if (i == self || !(bits & (1ULL << (i % 64))))
+            continue;
+
+        __u32 key = i;
+        __u64 *idle_since = bpf_map_lookup_elem(&cpu_idle_since, &key);
+        if (idle_since && *idle_since && *idle_since <= since)
+            idle++;
+    }
+
+    return idle;
+}
+
+static __always_inline struct sched_policy_t *get_node_config(void)
+{
+    __u32 key = 0;
+    return bpf_map_lookup_elem(&sched_config, &key);
+}
+
+extern const char runqueues __ksym __weak;
+
+#define RQ_SLOTS 12
+
+// 运行队列长度直方图，长度 0~7 各占一个槽位，之后按 [8,16) [16,32) [32,64) [64,+inf) 划分
+struct rq_stats_t
+{
+    __u64 slots[RQ_SLOTS];
+    __u64 samples;
+    __u64 sum;        // 采样到的 nr_running 之和
+    __u64 oversub_ns; // nr_running > 1 的累计时长
+    __u64 last_ts;    // 上次在本 CPU sched_switch 中采样的时间
+    __u64 last_nr;    // 上次在本 CPU sched_switch 中采样的 nr_running
+};
+
+struct rq_stats_t *unused_rq_stats_t __attribute__((unused));
+
+// 每个 CPU 的运行队列长度统计，用户态周期读取累计值
+struct
+{
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, MAX_CPUS);
+    __type(key, __u32);
+    __type(value, struct rq_stats_t);
+} rq_stats SEC(".maps");
+
+// 读取 CPU 运行队列中的任务数(含正在运行的任务)，无法获取时返回 -1
+static __always_inline __s64 read_nr_running(__u32 cpu)
+{
+    const char *rq;
+    unsigned int nr = 0;
+
+    if (!&runqueues || !bpf_core_type_exists(struct rq))
+        return -1;
+
+    rq = per_cpu_addr(&runqueues, cpu);
+    if (!rq || bpf_probe_read_kernel(&nr, sizeof(nr), rq + bpf_core_field_offset(struct rq, nr_running)))
+        return -1;
+    return nr;
+}
+
+static __always_inline __u32 rq_slot(__u64 nr)
+{
+    if (nr < 8)
+        return nr;
+    if (nr < 16)
+        return 8;
+    if (nr < 32)
+        return 9;
+    if (nr < 64)
+        return 10;
+    return RQ_SLOTS - 1;
+}
+
+// 采样 CPU 的运行队列长度，local 表示在该 CPU 上持有运行队列锁时采样，此时才累计超额订阅时长
+static __always_inline void sample_runqueue(__u32 cpu, __u64 now, bool local)
+{
+    struct sched_policy_t *node_config = get_node_config();
+    if (!node_config || !node_config->runqueue_stats)
+        return;
+
+    __s64 nr = read_nr_running(cpu);
+    if (nr < 0)
+        return;
+
+    struct rq_stats_t *stats = bpf_map_lookup_elem(&rq_stats, &cpu);
+    if (!stats)
+        return;
+
+    __sync_fetch_and_add(&stats->slots[rq_slot(nr)], 1);
+    __sync_fetch_and_add(&stats->samples, 1);
+    __sync_fetch_and_add(&stats->sum, nr);
+
+    if (!local)
+        return;
+
+    // 两次采样之间按上次采样的长度计算，唤醒与出队导致的变化在下次切换时才体现
+    if (stats->last_ts && stats->last_nr > 1 && now > stats->last_ts)
+        stats->oversub_ns += now - stats->last_ts;
+    stats->last_ts = now;
+    stats->last_nr = nr;
+}
+
+// 获取 cgroup 对应的采集策略，未配置时回退到节点默认策略及内置默认值
+static __always_inline void get_sched_policy(u64 cgroup_id, __u64 *threshold_ns, __u64 *sampling_ratio)
+{
+    __u32 key = 0;
+    struct sched_policy_t *policy;
+
+    *threshold_ns = THRESHOLD_NS;
+    *sampling_ratio = SAMPLING_RATIO;
+
+    policy = bpf_map_lookup_elem(&cgroup_policies, &cgroup_id);
+    if (!policy)
+        policy = bpf_map_lookup_elem(&sched_config, &key);
+    if (!policy)
+        return;
+
+    if (policy->threshold_ns)
+        *threshold_ns = policy->threshold_ns;
+    if (policy->sampling_ratio)
+        *sampling_ratio = policy->sampling_ratio;
+}
+
+// 设置监控范围时只保留命中的进程，cgroup 未知（经典 tracepoint 下所属 cgroup 尚未记录的线程）时无法确认，同样丢弃
+static __always_inline bool in_scope(u64 cgroup_id)
+{
+    __u32 key = 0;
+    __u32 *enabled = bpf_map_lookup_elem(&scope_enabled, &key);
+    if (!enabled || *enabled == 0)
+        return true;
+    if (cgroup_id == 0)
+        return false;
+
+    return bpf_map_lookup_elem(&scope_cgroups, &cgroup_id) != NULL;
+}
+
+// 公共函数：处理调度切换事件
+static __always_inline void handle_sched_switch(u32 prev_pid, u32 prev_tgid,
+                                                u32 next_pid, u32 next_tgid, __u32 prev_state,
+                                                u64 prev_cgroup_id, u64 next_cgroup_id,
+                                                s32 next_prio, s32 next_static_prio, u32 next_policy,
+                                                const char *prev_comm, const char *next_comm,
+                                                const struct cpumask *next_allowed, bool ringbuf, void *ctx)
+{
+    struct enqueue_info_t *wakeup;
+    __u64 now = bpf_ktime_get_ns();
+    __u32 cpu = bpf_get_smp_processor_id();
+    struct sched_policy_t *node_config = get_node_config();
+    struct task_stack_t prev_stack = {.kern_stack_id = -1, .user_stack_id = -1};
+
+    if (prev_pid != 0)
+    {
+        bpf_map_update_elem(&task_last_cpu, &prev_pid, &cpu, BPF_ANY);
+
+        // 切换时 current 仍为 prev，采集到的即 prev 的调用栈
+        if (node_config && node_config->capture_stacks)
+        {
+            prev_stack.kern_stack_id = get_stack_id(ctx, 0);
+            prev_stack.user_stack_id = get_stack_id(ctx, BPF_F_USER_STACK);
+            bpf_map_update_elem(&task_stacks, &prev_pid, &prev_stack, BPF_ANY);
+        }
+    }
+
+    sample_runqueue(cpu, now, true);
+
+    // 切换到 idle 线程即 CPU 进入空闲，从 idle 线程切出即退出空闲
+    if (next_pid == 0 || prev_pid == 0)
+    {
+        __u64 idle_since = next_pid == 0 ? now : 0;
+        bpf_map_update_elem(&cpu_idle_since, &cpu, &idle_since, BPF_ANY);
+    }
+
+    // 被抢占的进程仍处于 TASK_RUNNING，不经过唤醒直接回到运行队列，从此刻开始计算其等待时间
+    if (prev_pid != 0 && prev_state == TASK_RUNNING &&
+        filter_pass(prev_tgid ? prev_tgid : prev_pid, prev_comm, prev_cgroup_id))
+    {
+        struct enqueue_info_t info = {
+            .ts = now,
+            .type = LATENCY_REQUEUE,
+            .waker_cpu = -1,
+        };
+        snapshot_irq_time(&info, cpu);
+        bpf_map_update_elem(&wakeup_times, &prev_pid, &info, BPF_ANY);
+    }
+
+    if (prev_pid == 0 || next_pid == 0)
+    {
+        return;
+    }
+
+    // 查找进程的唤醒时间
+    wakeup = bpf_map_lookup_elem(&wakeup_times, &next_pid);
+    if (!wakeup)
+        return;
+
+    // 不在监控范围内或被过滤的进程直接丢弃，过滤规则可能在唤醒之后才修改
+    if (!in_scope(next_cgroup_id) ||
+        !filter_pass(next_tgid ? next_tgid : next_pid, next_comm, next_cgroup_id))
+    {
+        bpf_map_delete_elem(&wakeup_times, &next_pid);
+        return;
+    }
+
+    // 计算调度延迟
+    __u64 delay = now - wakeup->ts;
+
+    // 直方图在采样与阈值过滤之前更新，保留完整分布
+    if (node_config && node_config->aggregate)
+    {
+        __u64 id = next_cgroup_id;
+        if (node_config->aggregate == AGGREGATE_TGID)
+            id = next_tgid ? next_tgid : next_pid;
+        update_hist(id, delay);
+    }
+
+    if (node_config && node_config->disable_events)
+    {
+        bpf_map_delete_elem(&wakeup_times, &next_pid);
+        return;
+    }
+
+    __u64 threshold_ns, sampling_ratio;
+    get_sched_policy(next_cgroup_id, &threshold_ns, &sampling_ratio);
+
+    // 流控逻辑开始
+    __u32 key = 0;
+    __u64 *last_ts = bpf_map_lookup_elem(&last_sample, &key);
+    if (!last_ts)
+        return;
+
+    // 基于时间的流控
+    if ((now - *last_ts) < threshold_ns)
+    {
+        if (bpf_get_prandom_u32() % sampling_ratio != 0)
+        {
+            bpf_map_delete_elem(&wakeup_times, &next_pid);
+            return;
+        }
+    }
+
+    // 更新最后采样时间
+    bpf_map_update_elem(&last_sample, &key, &now, BPF_ANY);
+
+    // 延迟阈值过滤
+    if (delay < threshold_ns)
+    {
+        bpf_map_delete_elem(&wakeup_times, &next_pid);
+        return;
+    }
+
+    // 准备输出数据
+    struct sched_latency_t latency = {
+        .pid = next_tgid ? next_tgid : next_pid, // 如果有 tgid 则使用 tgid，否则使用 pid
+        .tid = next_pid,
+        .delay_ns = delay,
+        .ts = now,
+        .preempted_pid_state = prev_state,
+        .cgroup_id = next_cgroup_id,
+        .cpu = cpu,
+        .prev_cpu = -1,
+        .prio = next_prio,
+        .nice = next_static_prio - DEFAULT_PRIO,
+        .policy = next_policy,
+        .kern_stack_id = -1,
+        .user_stack_id = -1,
+        .preempted_kern_stack_id = -1,
+        .preempted_user_stack_id = -1,
+        .latency_type = wakeup->type,
+        .waker_pid = wakeup->waker_pid,
+        .waker_tid = wakeup->waker_tid,
+        .waker_cpu = wakeup->waker_cpu,
+        .waker_ctx = wakeup->waker_ctx,
+        .idle_cpus = count_idle_cpus(next_allowed, cpu, wakeup->ts),
+        .migrations = wakeup->migrations,
+    };
+    __builtin_memcpy(&latency.waker_comm, wakeup->waker_comm, sizeof(latency.waker_comm));
+
+    // 等待期间被迁移时无法区分各 CPU 上的中断时间，不做统计
+    struct cpu_irq_time_t *irq_total = bpf_map_lookup_elem(&cpu_irq_time, &cpu);
+    if (irq_total && wakeup->irq_cpu == cpu)
+    {
+        if (irq_total->hardirq_ns > wakeup->hardirq_ns)
+            latency.hardirq_ns = irq_total->hardirq_ns - wakeup->hardirq_ns;
+        if (irq_total->softirq_ns > wakeup->softirq_ns)
+            latency.softirq_ns = irq_total->softirq_ns - wakeup->softirq_ns;
+    }
+
+    struct task_stack_t *next_stack = bpf_map_lookup_elem(&task_stacks, &next_pid);
+    if (next_stack)
+    {
+        latency.kern_stack_id = next_stack->kern_stack_id;
+        latency.user_stack_id = next_stack->user_stack_id;
+    }
+
+    __u32 *last_cpu = bpf_map_lookup_elem(&task_last_cpu, &next_pid);
+    if (last_cpu)
+        latency.prev_cpu = *last_cpu;
+
+    bpf_probe_read_kernel_str(&latency.comm, sizeof(latency.comm), next_comm);
+
+    // 如果前一个状态是 TASK_RUNNING，则认为是抢占
+    if (prev_state == TASK_RUNNING)
+    {
+        latency.is_preempt = 1;
+        latency.preempted_pid = prev_tgid ? prev_tgid : prev_pid;
+        latency.preempted_cgroup_id = prev_cgroup_id;
+        latency.preempted_kern_stack_id = prev_stack.kern_stack_id;
+        latency.preempted_user_stack_id = prev_stack.user_stack_id;
+        bpf_probe_read_kernel_str(&latency.preempted_comm, sizeof(latency.preempted_comm), prev_comm);
+    }
+
+    // trace_pipe 为全局共享，仅在调试时输出，前缀用于用户态区分本程序的输出
+    if (is_debug())
+        bpf_printk("shepherd: pid: %d, delay: %llu ns, is_preempt: %d\n",
+                   latency.pid, latency.delay_ns, latency.is_preempt);
+
+    // 输出到 ring buffer 或 perf event，经典 tracepoint 变体固定使用 perf event，编译后不包含 ring buffer 调用
+    if (ringbuf)
+        bpf_ringbuf_output(&sched_events_rb, &latency, sizeof(latency), 0);
+    else
+        bpf_perf_event_output(ctx, &sched_events, BPF_F_CURRENT_CPU, &latency, sizeof(latency));
+
+    // 删除已处理的唤醒时间记录
+    bpf_map_delete_elem(&wakeup_times, &next_pid);
+}
+
+SEC("tp_btf/sched_switch")
+int sched_switch(u64 *ctx)
+{
+    struct task_struct *prev = (struct task_struct *)ctx[1];
+    struct task_struct *next = (struct task_struct *)ctx[2];
+
+    u32 prev_pid = BPF_CORE_READ(prev, pid);
+    u32 prev_tgid = BPF_CORE_READ(prev, tgid);
+    u32 next_pid = BPF_CORE_READ(next, pid);
+    u32 next_tgid = BPF_CORE_READ(next, tgid);
+
+    handle_sched_switch(prev_pid, prev_tgid, next_pid, next_tgid,
+                        get_task_state(prev), get_task_cgroup_id(prev), get_task_cgroup_id(next),
+                        BPF_CORE_READ(next, prio), BPF_CORE_READ(next, static_prio),
+                        BPF_CORE_READ(next, policy),
+                        prev->comm, next->comm, BPF_CORE_READ(next, cpus_ptr), use_ringbuf, ctx);
+    return 0;
+}
+
+SEC("tp/sched/sched_switch")
+int sched_switch_tp(struct trace_event_raw_sched_switch *ctx)
+{
+    // 经典 tracepoint 触发时 current 仍是 prev，next 的 cgroup 取自其上次被切出时的记录，未知时为 0
+    // 只有 next_prio 可用：按优先级区间推断调度策略，CFS 任务的动态优先级即静态优先级
+    // next 的 cpumask 同样无法获取，统计空闲 CPU 时视为允许全部 CPU
+    s32 prio = ctx->next_prio;
+    u32 policy = SCHED_NORMAL;
+    if (prio < 0)
+        policy = SCHED_DEADLINE;
+    else if (prio < MAX_RT_PRIO)
+        policy = SCHED_FIFO;
+
+    // current 即 prev，可直接取得其 tgid；next 的 tgid 取自此前的记录，未知时为 0
+    __u32 prev_tgid = bpf_get_current_pid_tgid() >> 32;
+    __u64 prev_cgroup_id = bpf_get_current_cgroup_id();
+    record_current_tgid();
+    __u32 prev_pid = ctx->prev_pid;
+    if (prev_pid != 0)
+        bpf_map_update_elem(&task_cgroups, &prev_pid, &prev_cgroup_id, BPF_ANY);
+
+    // 被抢占的 prev 仍可运行，按 TASK_RUNNING 处理，否则重新入队与抢占均无法识别
+    __u32 prev_state = ctx->prev_state;
+    if (prev_state & TASK_REPORT_MAX)
+        prev_state = TASK_RUNNING;
+
+    handle_sched_switch(prev_pid, prev_tgid, ctx->next_pid, lookup_tgid(ctx->next_pid),
+                        prev_state, prev_cgroup_id, lookup_cgroup(ctx->next_pid),
+                        prio, prio < MAX_RT_PRIO ? DEFAULT_PRIO : prio, policy,
+                        ctx->prev_comm, ctx->next_comm, NULL, false, ctx);
+    return 0;
+}
+
+// CFS 带宽限流的 cgroup 与 CPU
+struct cfs_throttle_key_t
+{
+    __u64 cgroup_id;
+    __u32 cpu;
+    __u32 pad;
+};
+
+// 按 cgroup 累计的限流次数与限流时长
+struct cfs_throttle_stat_t
+{
+    __u64 periods;      // 单个 CPU 上 cfs_rq 被解除限流的次数
+    __u64 throttled_ns; // 各 CPU 上 cfs_rq 处于限流状态的累计时长
+};
+
+struct cfs_throttle_stat_t *unused_cfs_throttle_stat_t __attribute__((unused));
+
+// cfs_rq 开始限流的时间
+struct
+{
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 65536);
+    __type(key, struct cfs_throttle_key_t);
+    __type(value, __u64);
+} cfs_throttle_start SEC(".maps");
+
+// 由用户态周期读取累计值
+struct
+{
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 10240);
+    __type(key, __u64);
+    __type(value, struct cfs_throttle_stat_t);
+} cfs_throttle_stats SEC(".maps");
+
+static __always_inline void cfs_throttle_key(struct cfs_rq *cfs_rq, struct cfs_throttle_key_t *key)
+{
+    key->cgroup_id = BPF_CORE_READ(cfs_rq, tg, css.cgroup, kn, id);
+    key->cpu = BPF_CORE_READ(cfs_rq, rq, cpu);
+}
+
+// 5.8 之后 throttle_cfs_rq 在运行时间被补充时会放弃限流，因此只记录开始时间，在解除限流时计数
+SEC("kprobe/throttle_cfs_rq")
+int BPF_KPROBE(kprobe_throttle_cfs_rq, struct cfs_rq *cfs_rq)
+{
+    struct cfs_throttle_key_t key = {};
+    __u64 now = bpf_ktime_get_ns();
+
+    cfs_throttle_key(cfs_rq, &key);
+    bpf_map_update_elem(&cfs_throttle_start, &key, &now, BPF_ANY);
+    return 0;
+}
+
+SEC("kprobe/unthrottle_cfs_rq")
+int BPF_KPROBE(kprobe_unthrottle_cfs_rq, struct cfs_rq *cfs_rq)
+{
+    struct cfs_throttle_key_t key = {};
+
+    cfs_throttle_key(cfs_rq, &key);
+    __u64 *start = bpf_map_lookup_elem(&cfs_throttle_start, &key);
+    if (!start)
+        return 0;
+
+    __u64 delta = bpf_ktime_get_ns() - *start;
+    bpf_map_delete_elem(&cfs_throttle_start, &key);
+
+    struct cfs_throttle_stat_t *stat = bpf_map_lookup_elem(&cfs_throttle_stats, &key.cgroup_id);
+    if (!stat)
+    {
+        struct cfs_throttle_stat_t zero = {};
+        bpf_map_update_elem(&cfs_throttle_stats, &key.cgroup_id, &zero, BPF_NOEXIST);
+        stat = bpf_map_lookup_elem(&cfs_throttle_stats, &key.cgroup_id);
+        if (!stat)
+            return 0;
+    }
+
+    __sync_fetch_and_add(&stat->periods, 1);
+    __sync_fetch_and_add(&stat->throttled_ns, delta);
+    return 0;
+}
+
+// 可选探针：由用户态按配置挂载到任意内核函数，按函数地址统计调用次数与耗时
+struct probe_func_stat_t
+{
+    __u64 count;
+    __u64 time_ns;
+};
+
+struct probe_func_stat_t *unused_probe_func_stat_t __attribute__((unused));
+
+struct
+{
+    __uint(type, BPF_MAP_TYPE_PERCPU_HASH);
+    __uint(max_entries, 4096);
+    __type(key, __u64);
+    __type(value, struct probe_func_stat_t);
+} probe_func_stats SEC(".maps");
+
+struct probe_func_start_key_t
+{
+    __u64 ip;
+    __u32 tid;
+    __u32 pad;
+};
+
+// 函数入口时间，递归调用只保留最内层
+struct
+{
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(max_entries, 65536);
+    __type(key, struct probe_func_start_key_t);
+    __type(value, __u64);
+} probe_func_start SEC(".maps");
+
+static __always_inline struct probe_func_stat_t *probe_func_stat(__u64 ip)
+{
+    struct probe_func_stat_t *stat = bpf_map_lookup_elem(&probe_func_stats, &ip);
+    if (stat)
+        return stat;
+
+    struct probe_func_stat_t zero = {};
+    bpf_map_update_elem(&probe_func_stats, &ip, &zero, BPF_NOEXIST);
+    return bpf_map_lookup_elem(&probe_func_stats, &ip);
+}
+
+static __always_inline void probe_func_count(__u64 ip)
+{
+    struct probe_func_stat_t *stat = probe_func_stat(ip);
+    if (stat)
+        stat->count++;
+}
+
+static __always_inline void probe_func_enter(__u64 ip)
+{
+    struct probe_func_start_key_t key = {
+        .ip = ip,
+        .tid = (__u32)bpf_get_current_pid_tgid(),
+    };
+    __u64 now = bpf_ktime_get_ns();
+    bpf_map_update_elem(&probe_func_start, &key, &now, BPF_ANY);
+}
+
+static __always_inline void probe_func_exit(__u64 ip)
+{
+    struct probe_func_start_key_t key = {
+        .ip = ip,
+        .tid = (__u32)bpf_get_current_pid_tgid(),
+    };
+    __u64 *start = bpf_map_lookup_elem(&probe_func_start, &key);
+    if (!start)
+        return;
+
+    __u64 delta = bpf_ktime_get_ns() - *start;
+    bpf_map_delete_elem(&probe_func_start, &key);
+
+    struct probe_func_stat_t *stat = probe_func_stat(ip);
+    if (!stat)
+        return;
+    stat->count++;
+    stat->time_ns += delta;
+}
+
+// kprobe 与 kretprobe 由用户态决定以 kprobe.multi 或单个 kprobe 方式加载
+SEC("kprobe")
+int kprobe_func_count(struct pt_regs *ctx)
+{
+    probe_func_count(bpf_get_func_ip(ctx));
+    return 0;
+}
+
+SEC("kprobe")
+int kprobe_func_entry(struct pt_regs *ctx)
+{
+    probe_func_enter(bpf_get_func_ip(ctx));
+    return 0;
+}
+
+SEC("kretprobe")
+int kretprobe_func_exit(struct pt_regs *ctx)
+{
+    probe_func_exit(bpf_get_func_ip(ctx));
+    return 0;
+}
+
+// fentry 与 fexit 的挂载目标在加载时指定，用户态为每个目标函数单独加载一份
+SEC("fentry")
+int fentry_func_count(u64 *ctx)
+{
+    probe_func_count(bpf_get_func_ip(ctx));
+    return 0;
+}
+
+SEC("fentry")
+int fentry_func_entry(u64 *ctx)
+{
+    probe_func_enter(bpf_get_func_ip(ctx));
+    return 0;
+}
+
+SEC("fexit")
+int fexit_func_exit(u64 *ctx)
+{
+    probe_func_exit(bpf_get_func_ip(ctx));
     return 0;
 }
-#endif
 
 char __license[] SEC("license") = "Dual BSD/GPL";
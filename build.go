@@ -1,4 +1,4 @@
 //go:generate sh -c "echo Generating for $TARGET_GOARCH"
-//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -type sched_latency_t -target $TARGET_GOARCH -go-package binary -output-dir ./internal/binary -cc clang -no-strip Shepherd ./bpf/trace.c -- -I./bpf/headers -Wno-address-of-packed-member
+//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -type sched_latency_t -type sched_policy_t -type latency_hist_t -type rq_stats_t -type irq_key_t -type irq_vec_stat_t -type cpu_irq_time_t -type cfs_throttle_stat_t -type filter_config_t -type filter_comm_key_t -type probe_func_stat_t -target $TARGET_GOARCH -go-package binary -output-dir ./internal/binary -cc clang -no-strip Shepherd ./bpf/trace.c -- -I./bpf/headers -Wno-address-of-packed-member
 
 package main
@@ -26,6 +26,32 @@ func main() {
 
 	config.SetFlags(rootCmd.Flags())
 
+	var cleanupCmd = &cobra.Command{
+		Use:   "cleanup",
+		Short: "Remove the BPF maps and links pinned by the agent, detaching its programs",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := run.Cleanup(config.Config); err != nil {
+				log.Fatal(err)
+				os.Exit(1)
+			}
+		},
+	}
+	config.SetCleanupFlags(cleanupCmd.Flags())
+	rootCmd.AddCommand(cleanupCmd)
+
+	var diagnoseCmd = &cobra.Command{
+		Use:   "diagnose",
+		Short: "Load each BPF program individually, report verifier results and write a support bundle",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := run.Diagnose(config.Config); err != nil {
+				log.Fatal(err)
+				os.Exit(1)
+			}
+		},
+	}
+	config.SetDiagnoseFlags(diagnoseCmd.Flags())
+	rootCmd.AddCommand(diagnoseCmd)
+
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatal(err)
 		os.Exit(1)
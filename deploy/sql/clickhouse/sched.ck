@@ -22,9 +22,184 @@ CREATE TABLE shepherd.sched_latency
 
     `preempted_pid_state` UInt32,
 
+    `cgroup_id` UInt64,
+
+    `preempted_cgroup_id` UInt64,
+
+    `pod` String,
+
+    `namespace` String,
+
+    `preempted_pod` String,
+
+    `preempted_namespace` String,
+
+    `cpu` UInt32,
+
+    `prev_cpu` Int32,
+
+    `prio` Int32,
+
+    `nice` Int32,
+
+    `policy` UInt32,
+
+    `sched_class` LowCardinality(String),
+
+    `stack` String,
+
+    `preempted_stack` String,
+
+    `latency_type` LowCardinality(String),
+
+    `waker_pid` UInt32,
+
+    `waker_tid` UInt32,
+
+    `waker_comm` String,
+
+    `waker_cpu` Int32,
+
+    `waker_context` LowCardinality(String),
+
+    `idle_cpus` UInt32,
+
+    `migrations` UInt32,
+
+    `hardirq_ns` UInt64,
+
+    `softirq_ns` UInt64,
+
     `datetime` DateTime64(9) DEFAULT now64(9)
 )
 ENGINE = MergeTree
 ORDER BY (date,
  ts)
-SETTINGS index_granularity = 8192;
\ No newline at end of file
+SETTINGS index_granularity = 8192;
+CREATE TABLE shepherd.noisy_neighbor_episodes
+(
+
+    `id` String,
+
+    `node` String,
+
+    `state` LowCardinality(String),
+
+    `victim_pid` UInt32,
+
+    `victim_comm` String,
+
+    `victim_cgroup_id` UInt64,
+
+    `victim_pod` String,
+
+    `victim_namespace` String,
+
+    `aggressor_pid` UInt32,
+
+    `aggressor_comm` String,
+
+    `aggressor_cgroup_id` UInt64,
+
+    `aggressor_pod` String,
+
+    `aggressor_namespace` String,
+
+    `started_at` DateTime64(3),
+
+    `ended_at` DateTime64(3),
+
+    `score` Float64,
+
+    `peak_score` Float64,
+
+    `preemptions` UInt64,
+
+    `delay_ns` UInt64,
+
+    `peak_delay_ns` UInt64,
+
+    `runtime_share` Float64,
+
+    `updated_at` DateTime64(3)
+)
+ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY (node,
+ id)
+SETTINGS index_granularity = 8192;
+
+CREATE TABLE shepherd.sched_latency_hist
+(
+
+    `time` DateTime64(3),
+
+    `node` String,
+
+    `mode` LowCardinality(String),
+
+    `id` UInt64,
+
+    `pod` String,
+
+    `namespace` String,
+
+    `container` String,
+
+    `comm` String,
+
+    `count` UInt64,
+
+    `sum_ns` UInt64,
+
+    `buckets` Array(UInt64),
+
+    `p50_ns` UInt64,
+
+    `p99_ns` UInt64,
+
+    `date` Date DEFAULT toDate(time)
+)
+ENGINE = MergeTree
+ORDER BY (date,
+ node,
+ id,
+ time)
+SETTINGS index_granularity = 8192;
+
+CREATE TABLE shepherd.cfs_throttle_episodes
+(
+
+    `id` String,
+
+    `node` String,
+
+    `state` LowCardinality(String),
+
+    `cgroup_id` UInt64,
+
+    `cgroup` String,
+
+    `pod` String,
+
+    `namespace` String,
+
+    `container` String,
+
+    `started_at` DateTime64(3),
+
+    `ended_at` DateTime64(3),
+
+    `periods` UInt64,
+
+    `throttled_ns` UInt64,
+
+    `latency_events` UInt64,
+
+    `latency_delay_ns` UInt64,
+
+    `updated_at` DateTime64(3)
+)
+ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY (node,
+ id)
+SETTINGS index_granularity = 8192;
@@ -0,0 +1,281 @@
+package aggregate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/binary"
+	"github.com/cen-ngc5139/shepherd/internal/bpf"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/enricher"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cen-ngc5139/shepherd/internal/output"
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultInterval = 10 * time.Second
+
+	// staleAfter 超过该时间没有新数据的序列不再导出
+	staleAfter = 10 * time.Minute
+)
+
+// series 单个 cgroup 或进程的累计直方图
+type series struct {
+	record     Record
+	lastUpdate time.Time
+}
+
+// Listener 接收每个聚合周期内全节点合并后的直方图增量，Record 中 ID 为 0
+type Listener interface {
+	OnHistogram(delta Record)
+}
+
+// Aggregator 周期读取并清零内核态直方图，导出为 Prometheus 直方图并写入聚合输出
+type Aggregator struct {
+	cfg      config.AggregationConfig
+	node     string
+	hists    *ebpf.Map
+	enricher enricher.Enricher
+	sink     *output.Sink[Record]
+	desc     *prometheus.Desc
+
+	// lookupAndDelete 内核不支持 hash map 原子读取并删除时回退为读取后删除
+	lookupAndDelete bool
+
+	mu        sync.Mutex
+	series    map[uint64]*series
+	listeners []Listener
+}
+
+func NewAggregator(cfg config.Configuration, coll *ebpf.Collection, e enricher.Enricher, nodeName string,
+	ctx context.Context) (*Aggregator, error) {
+	hists, ok := coll.Maps[bpf.LatencyHistsMap]
+	if !ok {
+		return nil, errors.Errorf("map %s not found", bpf.LatencyHistsMap)
+	}
+
+	aggCfg := cfg.Sched.Aggregation
+	if aggCfg.Interval <= 0 {
+		aggCfg.Interval = defaultInterval
+	}
+
+	var sink *output.Sink[Record]
+	if aggCfg.Sink.Type != "" {
+		var err error
+		sink, err = NewSink(cfg, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	a := &Aggregator{
+		cfg:      aggCfg,
+		node:     nodeName,
+		hists:    hists,
+		enricher: e,
+		sink:     sink,
+		desc: prometheus.NewDesc("sched_latency_seconds",
+			"unsampled task cpu scheduling latency aggregated in kernel",
+			[]string{"id", "pod", "namespace", "container", "comm"}, nil),
+		lookupAndDelete: true,
+		series:          make(map[uint64]*series),
+	}
+	if err := prometheus.Register(a); err != nil {
+		return nil, errors.Wrap(err, "failed to register latency histogram collector")
+	}
+
+	return a, nil
+}
+
+// AddListener 注册全节点直方图的监听者
+func (a *Aggregator) AddListener(l Listener) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.listeners = append(a.listeners, l)
+}
+
+// Start 周期聚合，阻塞直至 ctx 结束
+func (a *Aggregator) Start(ctx context.Context) error {
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+
+	log.Infof("latency histogram aggregation by %s started, interval %s", a.cfg.Mode, a.cfg.Interval)
+	for {
+		select {
+		case <-ctx.Done():
+			if a.sink != nil {
+				a.sink.Close()
+			}
+			return nil
+		case now := <-ticker.C:
+			if err := a.flush(now); err != nil {
+				log.Errorf("failed to flush latency histograms: %v", err)
+			}
+		}
+	}
+}
+
+func (a *Aggregator) flush(now time.Time) error {
+	deltas, err := a.drain()
+	if err != nil {
+		return err
+	}
+
+	records := make([]Record, 0, len(deltas))
+	node := Record{Time: now, Node: a.node, Mode: string(a.cfg.Mode)}
+	a.mu.Lock()
+	for id, hist := range deltas {
+		node.Count += hist.Count
+		node.SumNs += hist.SumNs
+		for i, c := range hist.Slots {
+			node.Buckets[i] += c
+		}
+
+		s, ok := a.series[id]
+		if !ok {
+			s = &series{record: a.newRecord(id)}
+			a.series[id] = s
+		} else if a.cfg.Mode == config.AggregationByCgroup && s.record.Pod == "" {
+			// Pod 元数据可能晚于首个直方图同步完成
+			fresh := a.newRecord(id)
+			s.record.Pod, s.record.Namespace, s.record.Container = fresh.Pod, fresh.Namespace, fresh.Container
+		}
+		s.lastUpdate = now
+
+		delta := s.record
+		delta.Time = now
+		delta.Count, delta.SumNs, delta.Buckets = hist.Count, hist.SumNs, hist.Slots
+		delta.P50Ns = quantile(hist.Slots, hist.Count, 0.5)
+		delta.P99Ns = quantile(hist.Slots, hist.Count, 0.99)
+		records = append(records, delta)
+
+		s.record.Count += hist.Count
+		s.record.SumNs += hist.SumNs
+		for i, c := range hist.Slots {
+			s.record.Buckets[i] += c
+		}
+	}
+
+	for id, s := range a.series {
+		if now.Sub(s.lastUpdate) > staleAfter {
+			delete(a.series, id)
+		}
+	}
+	listeners := a.listeners
+	a.mu.Unlock()
+
+	node.P50Ns = quantile(node.Buckets, node.Count, 0.5)
+	node.P99Ns = quantile(node.Buckets, node.Count, 0.99)
+	for _, l := range listeners {
+		l.OnHistogram(node)
+	}
+
+	if a.sink != nil && len(records) > 0 {
+		return a.sink.Write(records...)
+	}
+
+	return nil
+}
+
+// drain 读取并删除全部直方图，返回本周期的增量
+func (a *Aggregator) drain() (map[uint64]binary.ShepherdLatencyHistT, error) {
+	var (
+		key  uint64
+		hist binary.ShepherdLatencyHistT
+		keys []uint64
+	)
+
+	iter := a.hists.Iterate()
+	for iter.Next(&key, &hist) {
+		keys = append(keys, key)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to iterate %s", bpf.LatencyHistsMap)
+	}
+
+	deltas := make(map[uint64]binary.ShepherdLatencyHistT, len(keys))
+	for _, k := range keys {
+		if a.lookupAndDelete {
+			err := a.hists.LookupAndDelete(k, &hist)
+			if err == nil {
+				deltas[k] = hist
+				continue
+			}
+			if !errors.Is(err, ebpf.ErrNotSupported) {
+				if errors.Is(err, ebpf.ErrKeyNotExist) {
+					continue
+				}
+				return nil, errors.Wrapf(err, "failed to drain %s", bpf.LatencyHistsMap)
+			}
+			log.Warningf("lookup and delete of hash map not supported, fallback to lookup then delete")
+			a.lookupAndDelete = false
+		}
+
+		// 读取与删除之间的更新会丢失，仅在旧内核上发生
+		if err := a.hists.Lookup(k, &hist); err != nil {
+			continue
+		}
+		if err := a.hists.Delete(k); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return nil, errors.Wrapf(err, "failed to reset %s", bpf.LatencyHistsMap)
+		}
+		deltas[k] = hist
+	}
+
+	return deltas, nil
+}
+
+func (a *Aggregator) newRecord(id uint64) Record {
+	record := Record{Node: a.node, Mode: string(a.cfg.Mode), ID: id}
+
+	switch a.cfg.Mode {
+	case config.AggregationByCgroup:
+		if pod, ok := a.enricher.Lookup(id); ok {
+			record.Pod = pod.Name
+			record.Namespace = pod.Namespace
+			record.Container = pod.ContainerName
+		}
+	case config.AggregationByTgid:
+		raw, err := os.ReadFile(config.GetProcPath(fmt.Sprintf("%d/comm", id)))
+		if err == nil {
+			record.Comm = strings.TrimSpace(string(raw))
+		}
+	}
+
+	return record
+}
+
+func (a *Aggregator) Describe(ch chan<- *prometheus.Desc) {
+	ch <- a.desc
+}
+
+// Collect 以累计直方图导出，桶上界为 2^(i+1) 微秒
+func (a *Aggregator) Collect(ch chan<- prometheus.Metric) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, s := range a.series {
+		buckets := make(map[float64]uint64, HistSlots)
+		var cum uint64
+		for i, c := range s.record.Buckets {
+			cum += c
+			buckets[time.Duration(slotUpperNs(i)).Seconds()] = cum
+		}
+
+		r := s.record
+		metric, err := prometheus.NewConstHistogram(a.desc, r.Count, time.Duration(r.SumNs).Seconds(), buckets,
+			fmt.Sprintf("%d", r.ID), r.Pod, r.Namespace, r.Container, r.Comm)
+		if err != nil {
+			log.Errorf("failed to build latency histogram of %d: %v", r.ID, err)
+			continue
+		}
+		ch <- metric
+	}
+}
@@ -0,0 +1,58 @@
+package aggregate
+
+import (
+	"math"
+	"time"
+)
+
+// HistSlots 与 trace.c 中 HIST_SLOTS 一致，第 i 个槽位统计延迟位于 [2^i, 2^(i+1)) 微秒的次数
+const HistSlots = 32
+
+// Record 单个 cgroup 或进程在一个聚合周期内的调度延迟分布
+type Record struct {
+	Time      time.Time         `json:"time"`
+	Node      string            `json:"node"`
+	Mode      string            `json:"mode"`
+	ID        uint64            `json:"id"` // cgroup id 或 tgid
+	Pod       string            `json:"pod,omitempty"`
+	Namespace string            `json:"namespace,omitempty"`
+	Container string            `json:"container,omitempty"`
+	Comm      string            `json:"comm,omitempty"`
+	Count     uint64            `json:"count"`
+	SumNs     uint64            `json:"sum_ns"`
+	Buckets   [HistSlots]uint64 `json:"buckets"`
+	P50Ns     uint64            `json:"p50_ns"`
+	P99Ns     uint64            `json:"p99_ns"`
+}
+
+// slotUpperNs 返回槽位上界（纳秒）
+func slotUpperNs(slot int) uint64 {
+	return (uint64(1) << uint(slot+1)) * uint64(time.Microsecond)
+}
+
+// SlotBoundsNs 返回槽位的下界与上界（纳秒），不足 1 微秒的延迟同样计入第 0 个槽位
+func SlotBoundsNs(slot int) (lower, upper uint64) {
+	if slot > 0 {
+		lower = slotUpperNs(slot - 1)
+	}
+
+	return lower, slotUpperNs(slot)
+}
+
+// quantile 返回分位数所在槽位的上界（纳秒）
+func quantile(buckets [HistSlots]uint64, count uint64, q float64) uint64 {
+	if count == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(q * float64(count)))
+	var cum uint64
+	for i, c := range buckets {
+		cum += c
+		if cum >= rank {
+			return slotUpperNs(i)
+		}
+	}
+
+	return slotUpperNs(HistSlots - 1)
+}
@@ -0,0 +1,33 @@
+package aggregate
+
+import (
+	"context"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/output"
+)
+
+// histSinkSpec 每个聚合周期的直方图增量，ClickHouse 输出每个周期一个批次
+var histSinkSpec = output.SinkSpec[Record]{
+	Name:         "latency histogram",
+	DefaultTopic: "shepherd-latency-hists",
+	InsertSQL: `
+	INSERT INTO sched_latency_hist (
+		time, node, mode, id,
+		pod, namespace, container, comm,
+		count, sum_ns, buckets, p50_ns, p99_ns
+	)
+`,
+	Columns: func(r Record) []any {
+		return []any{
+			r.Time, r.Node, r.Mode, r.ID,
+			r.Pod, r.Namespace, r.Container, r.Comm,
+			r.Count, r.SumNs, r.Buckets[:], r.P50Ns, r.P99Ns,
+		}
+	},
+}
+
+// NewSink 创建聚合输出
+func NewSink(cfg config.Configuration, ctx context.Context) (*output.Sink[Record], error) {
+	return output.NewSink(cfg, cfg.Sched.Aggregation.Sink, histSinkSpec, ctx)
+}
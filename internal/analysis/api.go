@@ -0,0 +1,56 @@
+package analysis
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes 注册干扰分数与干扰事件查询接口
+func (e *Engine) RegisterRoutes(r gin.IRouter) {
+	g := r.Group("/noisy-neighbors")
+	g.GET("/scores", e.listScores)
+	g.GET("/episodes", e.listEpisodes)
+}
+
+// listScores GET /noisy-neighbors/scores?limit=20&min_score=0
+func (e *Engine) listScores(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+
+	minScore, err := strconv.ParseFloat(c.DefaultQuery("min_score", "0"), 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid min_score"})
+		return
+	}
+
+	scores := make([]Score, 0, limit)
+	for _, s := range e.Scores() {
+		if len(scores) >= limit || s.Score < minScore {
+			break
+		}
+		scores = append(scores, s)
+	}
+
+	c.JSON(http.StatusOK, scores)
+}
+
+// listEpisodes GET /noisy-neighbors/episodes?state=active|resolved
+func (e *Engine) listEpisodes(c *gin.Context) {
+	state := EpisodeState(c.Query("state"))
+	if state != "" && state != EpisodeActive && state != EpisodeResolved {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid state"})
+		return
+	}
+
+	episodes := e.Episodes(state)
+	if episodes == nil {
+		episodes = []Episode{}
+	}
+
+	c.JSON(http.StatusOK, episodes)
+}
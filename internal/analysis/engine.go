@@ -0,0 +1,325 @@
+package analysis
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cen-ngc5139/shepherd/internal/metadata"
+)
+
+const (
+	defaultWindow       = time.Minute
+	defaultEvalInterval = 5 * time.Second
+	defaultThreshold    = 60
+	defaultCountRef     = 100
+	defaultDelayRef     = 100 * time.Millisecond
+
+	// resolveRatio 分数低于阈值的该比例时才结束事件，避免在阈值附近反复开启
+	resolveRatio = 0.8
+	// maxRecentEpisodes 保留的已结束事件数量
+	maxRecentEpisodes = 256
+)
+
+var defaultWeights = config.AnalysisWeights{Count: 0.3, Delay: 0.4, Share: 0.3}
+
+type pairKey struct {
+	victim    string
+	aggressor string
+}
+
+// pairStats 受害者/抢占者对在窗口内的抢占统计
+type pairStats struct {
+	victim    Entity
+	aggressor Entity
+	window    *slidingWindow
+	// victimPids 窗口内出现过的受害进程，Pod 粒度时可能有多个
+	victimPids map[uint32]time.Time
+}
+
+// Engine 基于滑动窗口计算吵闹邻居干扰分数，并识别持续的干扰事件
+type Engine struct {
+	cfg     config.AnalysisConfig
+	node    string
+	metrics *metrics
+
+	mu        sync.Mutex
+	pairs     map[pairKey]*pairStats
+	runtime   *runtimeSampler
+	scores    []Score
+	active    map[pairKey]*Episode
+	recent    []Episode
+	listeners []Listener
+}
+
+func NewEngine(cfg config.AnalysisConfig, nodeName string) *Engine {
+	if cfg.Window <= 0 {
+		cfg.Window = defaultWindow
+	}
+	if cfg.EvalInterval <= 0 {
+		cfg.EvalInterval = defaultEvalInterval
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = defaultThreshold
+	}
+	if cfg.CountRef == 0 {
+		cfg.CountRef = defaultCountRef
+	}
+	if cfg.DelayRef <= 0 {
+		cfg.DelayRef = defaultDelayRef
+	}
+	if cfg.Weights == (config.AnalysisWeights{}) {
+		cfg.Weights = defaultWeights
+	}
+
+	e := &Engine{
+		cfg:     cfg,
+		node:    nodeName,
+		metrics: newMetrics(),
+		pairs:   make(map[pairKey]*pairStats),
+		runtime: newRuntimeSampler(cfg.Window),
+		active:  make(map[pairKey]*Episode),
+	}
+	e.listeners = append(e.listeners, e.metrics)
+
+	return e
+}
+
+// AddListener 注册干扰事件监听者，需在 Start 之前调用
+func (e *Engine) AddListener(l Listener) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.listeners = append(e.listeners, l)
+}
+
+// Handle 记录一次抢占
+func (e *Engine) Handle(event metadata.SchedEvent) {
+	if event.IsPreempt != 1 || event.PreemptedPid == 0 {
+		return
+	}
+
+	victim := Entity{
+		Pid:       event.Pid,
+		Comm:      metadata.CommString(event.Comm[:]),
+		CgroupID:  event.CgroupId,
+		Pod:       event.Pod.GetName(),
+		Namespace: event.Pod.GetNamespace(),
+	}
+	if event.Pod != nil {
+		victim.PodUID = event.Pod.UID
+	}
+
+	aggressor := Entity{
+		Pid:       event.PreemptedPid,
+		Comm:      metadata.CommString(event.PreemptedComm[:]),
+		CgroupID:  event.PreemptedCgroupId,
+		Pod:       event.PreemptedPod.GetName(),
+		Namespace: event.PreemptedPod.GetNamespace(),
+	}
+	if event.PreemptedPod != nil {
+		aggressor.PodUID = event.PreemptedPod.UID
+	}
+
+	key := pairKey{victim: victim.key(), aggressor: aggressor.key()}
+	if key.victim == key.aggressor {
+		return
+	}
+
+	now := time.Now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	stats, ok := e.pairs[key]
+	if !ok {
+		stats = &pairStats{
+			victim:     victim,
+			aggressor:  aggressor,
+			window:     newSlidingWindow(e.cfg.Window),
+			victimPids: make(map[uint32]time.Time),
+		}
+		e.pairs[key] = stats
+	}
+
+	// 保留最近一次观测到的进程信息
+	stats.victim, stats.aggressor = victim, aggressor
+	stats.victimPids[event.Pid] = now
+	stats.window.add(now, event.DelayNs)
+}
+
+// Start 周期评分，阻塞直至 ctx 结束
+func (e *Engine) Start(ctx context.Context) error {
+	ticker := time.NewTicker(e.cfg.EvalInterval)
+	defer ticker.Stop()
+
+	log.Infof("noisy neighbor engine started, window %s, threshold %.1f", e.cfg.Window, e.cfg.Threshold)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			e.evaluate(now)
+		}
+	}
+}
+
+func (e *Engine) evaluate(now time.Time) {
+	e.mu.Lock()
+
+	pids := make(map[uint32]struct{})
+	for key, stats := range e.pairs {
+		for pid, seen := range stats.victimPids {
+			if now.Sub(seen) > e.cfg.Window {
+				delete(stats.victimPids, pid)
+				continue
+			}
+			pids[pid] = struct{}{}
+		}
+		stats.window.prune(now)
+		if stats.window.empty() {
+			delete(e.pairs, key)
+		}
+	}
+	e.runtime.sample(now, pids)
+
+	scores := make([]Score, 0, len(e.pairs))
+	var changed []Episode
+	for key, stats := range e.pairs {
+		score := e.score(stats, now)
+		scores = append(scores, score)
+
+		episode, ok := e.active[key]
+		switch {
+		case !ok && score.Score >= e.cfg.Threshold:
+			episode = newEpisode(e.node, score, now)
+			episode.update(score)
+			e.active[key] = episode
+			changed = append(changed, *episode)
+		case ok && score.Score < e.cfg.Threshold*resolveRatio:
+			episode.update(score)
+			changed = append(changed, e.resolve(key, now))
+		case ok:
+			episode.update(score)
+		}
+	}
+
+	// 窗口内已无抢占的事件直接结束
+	for key := range e.active {
+		if _, ok := e.pairs[key]; !ok {
+			changed = append(changed, e.resolve(key, now))
+		}
+	}
+
+	sort.Slice(scores, func(i, j int) bool { return scores[i].Score > scores[j].Score })
+	e.scores = scores
+	active := make([]Episode, 0, len(e.active))
+	for _, episode := range e.active {
+		active = append(active, *episode)
+	}
+	listeners := e.listeners
+
+	e.mu.Unlock()
+
+	e.metrics.observe(active)
+	for _, episode := range changed {
+		log.Infof("noisy neighbor episode %s: victim %s, aggressor %s, score %.1f",
+			episode.State, episode.Victim, episode.Aggressor, episode.Score)
+		for _, l := range listeners {
+			l.OnEpisode(episode)
+		}
+	}
+}
+
+// score 综合抢占次数、累计延迟与运行时间占比计算 0-100 的干扰分数
+func (e *Engine) score(stats *pairStats, now time.Time) Score {
+	count, delayNs := stats.window.sum(now)
+
+	var runtimeNs uint64
+	var known bool
+	for pid := range stats.victimPids {
+		if ns, ok := e.runtime.runtime(pid); ok {
+			runtimeNs += ns
+			known = true
+		}
+	}
+
+	// 无法读取受害进程运行时间时，以窗口长度作为分母
+	share := float64(delayNs) / float64(e.cfg.Window)
+	if known && runtimeNs+delayNs > 0 {
+		share = float64(delayNs) / float64(runtimeNs+delayNs)
+	}
+	share = clamp(share)
+
+	w := e.cfg.Weights
+	total := w.Count + w.Delay + w.Share
+	value := w.Count*clamp(float64(count)/float64(e.cfg.CountRef)) +
+		w.Delay*clamp(float64(delayNs)/float64(e.cfg.DelayRef)) +
+		w.Share*share
+
+	return Score{
+		Victim:       stats.victim,
+		Aggressor:    stats.aggressor,
+		Preemptions:  count,
+		DelayNs:      delayNs,
+		RuntimeShare: share,
+		Score:        100 * value / total,
+	}
+}
+
+// resolve 结束事件并移入最近事件列表，调用方需持有锁
+func (e *Engine) resolve(key pairKey, now time.Time) Episode {
+	episode := e.active[key]
+	delete(e.active, key)
+
+	episode.State = EpisodeResolved
+	episode.EndedAt = now
+
+	if len(e.recent) >= maxRecentEpisodes {
+		e.recent = e.recent[1:]
+	}
+	e.recent = append(e.recent, *episode)
+
+	return *episode
+}
+
+// Scores 返回最近一次评分结果，按分数从高到低排列
+func (e *Engine) Scores() []Score {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]Score{}, e.scores...)
+}
+
+// Episodes 返回指定状态的干扰事件，state 为空时返回全部
+func (e *Engine) Episodes(state EpisodeState) []Episode {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var episodes []Episode
+	if state == "" || state == EpisodeActive {
+		for _, episode := range e.active {
+			episodes = append(episodes, *episode)
+		}
+	}
+	if state == "" || state == EpisodeResolved {
+		episodes = append(episodes, e.recent...)
+	}
+
+	sort.Slice(episodes, func(i, j int) bool { return episodes[i].StartedAt.After(episodes[j].StartedAt) })
+	return episodes
+}
+
+func clamp(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+
+	return v
+}
@@ -0,0 +1,103 @@
+package analysis
+
+import (
+	"fmt"
+	"time"
+)
+
+type EpisodeState string
+
+const (
+	EpisodeActive   EpisodeState = "active"
+	EpisodeResolved EpisodeState = "resolved"
+)
+
+// Entity 干扰关系中的一方，能解析到 Pod 时以 Pod 为粒度，否则以进程为粒度
+type Entity struct {
+	Pid       uint32 `json:"pid"`
+	Comm      string `json:"comm"`
+	CgroupID  uint64 `json:"cgroup_id"`
+	Pod       string `json:"pod,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	PodUID    string `json:"pod_uid,omitempty"`
+}
+
+func (e Entity) key() string {
+	if e.PodUID != "" {
+		return "pod/" + e.PodUID
+	}
+
+	return fmt.Sprintf("pid/%d", e.Pid)
+}
+
+func (e Entity) String() string {
+	if e.Pod != "" {
+		return e.Namespace + "/" + e.Pod
+	}
+
+	return fmt.Sprintf("%s(%d)", e.Comm, e.Pid)
+}
+
+// Score 单个受害者/抢占者对在当前窗口内的干扰评分
+type Score struct {
+	Victim       Entity  `json:"victim"`
+	Aggressor    Entity  `json:"aggressor"`
+	Preemptions  uint64  `json:"preemptions"`
+	DelayNs      uint64  `json:"delay_ns"`
+	RuntimeShare float64 `json:"runtime_share"` // 抢占者造成的等待占受害者(运行+该等待)时间的比例
+	Score        float64 `json:"score"`         // 0-100
+}
+
+// Episode 一次持续超过阈值的干扰事件
+type Episode struct {
+	ID           string       `json:"id"`
+	Node         string       `json:"node"`
+	State        EpisodeState `json:"state"`
+	Victim       Entity       `json:"victim"`
+	Aggressor    Entity       `json:"aggressor"`
+	StartedAt    time.Time    `json:"started_at"`
+	EndedAt      time.Time    `json:"ended_at,omitempty"`
+	Score        float64      `json:"score"`
+	PeakScore    float64      `json:"peak_score"`
+	Preemptions  uint64       `json:"preemptions"`   // 最近一次评分时窗口内的抢占次数
+	DelayNs      uint64       `json:"delay_ns"`      // 最近一次评分时窗口内的累计延迟
+	RuntimeShare float64      `json:"runtime_share"` // 最近一次评分时的运行时间占比
+	PeakDelayNs  uint64       `json:"peak_delay_ns"` // 事件期间窗口累计延迟的最大值
+}
+
+func newEpisode(node string, s Score, now time.Time) *Episode {
+	return &Episode{
+		ID:        fmt.Sprintf("%s-%s-%s-%d", node, s.Victim.key(), s.Aggressor.key(), now.UnixNano()),
+		Node:      node,
+		State:     EpisodeActive,
+		Victim:    s.Victim,
+		Aggressor: s.Aggressor,
+		StartedAt: now,
+	}
+}
+
+func (e *Episode) update(s Score) {
+	e.Score = s.Score
+	e.Preemptions = s.Preemptions
+	e.DelayNs = s.DelayNs
+	e.RuntimeShare = s.RuntimeShare
+	if s.Score > e.PeakScore {
+		e.PeakScore = s.Score
+	}
+	if s.DelayNs > e.PeakDelayNs {
+		e.PeakDelayNs = s.DelayNs
+	}
+}
+
+func (e *Episode) Duration() time.Duration {
+	if e.EndedAt.IsZero() {
+		return time.Since(e.StartedAt)
+	}
+
+	return e.EndedAt.Sub(e.StartedAt)
+}
+
+// Listener 接收干扰事件的开始与结束
+type Listener interface {
+	OnEpisode(episode Episode)
+}
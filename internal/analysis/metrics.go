@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	NoisyNeighborScore    = "noisy_neighbor_interference_score"
+	NoisyNeighborDelay    = "noisy_neighbor_interference_delay_ns"
+	NoisyNeighborEpisodes = "noisy_neighbor_episodes_total"
+	NoisyNeighborActive   = "noisy_neighbor_active_episodes"
+)
+
+var pairLabels = []string{"victim", "victim_namespace", "aggressor", "aggressor_namespace"}
+
+// metrics 仅为进行中的干扰事件导出分数，避免 Pod 对数量导致标签基数膨胀
+type metrics struct {
+	score    *prometheus.GaugeVec
+	delay    *prometheus.GaugeVec
+	episodes *prometheus.CounterVec
+	active   prometheus.Gauge
+
+	mu       sync.Mutex
+	exported map[string][]string
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		score: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Name: NoisyNeighborScore,
+			Help: "noisy neighbor interference score (0-100) of active episodes",
+		}, pairLabels),
+		delay: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Name: NoisyNeighborDelay,
+			Help: "scheduling delay caused by the aggressor within the window",
+		}, pairLabels),
+		episodes: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: NoisyNeighborEpisodes,
+			Help: "noisy neighbor episodes detected",
+		}, []string{"victim_namespace", "aggressor_namespace"}),
+		active: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: NoisyNeighborActive,
+			Help: "noisy neighbor episodes in progress",
+		}),
+		exported: make(map[string][]string),
+	}
+}
+
+func episodeLabels(episode Episode) []string {
+	return []string{
+		episode.Victim.String(), episode.Victim.Namespace,
+		episode.Aggressor.String(), episode.Aggressor.Namespace,
+	}
+}
+
+func (m *metrics) OnEpisode(episode Episode) {
+	if episode.State == EpisodeActive {
+		m.episodes.WithLabelValues(episode.Victim.Namespace, episode.Aggressor.Namespace).Inc()
+	}
+}
+
+// observe 更新进行中事件的分数，并删除已结束事件的序列
+func (m *metrics) observe(active []Episode) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current := make(map[string][]string, len(active))
+	for _, episode := range active {
+		labels := episodeLabels(episode)
+		current[episode.ID] = labels
+		m.score.WithLabelValues(labels...).Set(episode.Score)
+		m.delay.WithLabelValues(labels...).Set(float64(episode.DelayNs))
+	}
+
+	for id, labels := range m.exported {
+		if _, ok := current[id]; !ok {
+			m.score.DeleteLabelValues(labels...)
+			m.delay.DeleteLabelValues(labels...)
+		}
+	}
+
+	m.exported = current
+	m.active.Set(float64(len(active)))
+}
@@ -0,0 +1,107 @@
+package analysis
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+)
+
+// clockTicks 内核 USER_HZ，Linux 各主流架构均为 100
+const clockTicks = 100
+
+type cpuSample struct {
+	at    time.Time
+	cpuNs uint64
+}
+
+// runtimeSampler 周期采样进程 CPU 时间，用于计算受害进程在窗口内的实际运行时间
+type runtimeSampler struct {
+	window  time.Duration
+	samples map[uint32][]cpuSample
+}
+
+func newRuntimeSampler(window time.Duration) *runtimeSampler {
+	return &runtimeSampler{window: window, samples: make(map[uint32][]cpuSample)}
+}
+
+// sample 采样给定进程，并清理不再关注或已退出的进程
+func (r *runtimeSampler) sample(now time.Time, pids map[uint32]struct{}) {
+	for pid := range r.samples {
+		if _, ok := pids[pid]; !ok {
+			delete(r.samples, pid)
+		}
+	}
+
+	for pid := range pids {
+		cpuNs, err := readProcessCPUTime(pid)
+		if err != nil {
+			delete(r.samples, pid)
+			continue
+		}
+
+		samples := r.samples[pid]
+		i := 0
+		for i < len(samples)-1 && now.Sub(samples[i+1].at) >= r.window {
+			i++
+		}
+		r.samples[pid] = append(samples[i:], cpuSample{at: now, cpuNs: cpuNs})
+	}
+}
+
+// runtime 返回进程在窗口内的运行时间，样本不足时 ok 为 false
+func (r *runtimeSampler) runtime(pid uint32) (ns uint64, ok bool) {
+	samples := r.samples[pid]
+	if len(samples) < 2 {
+		return 0, false
+	}
+
+	first, last := samples[0], samples[len(samples)-1]
+	if last.cpuNs < first.cpuNs {
+		return 0, false
+	}
+
+	// 样本跨度与窗口不一致时按比例折算
+	span := last.at.Sub(first.at)
+	ns = last.cpuNs - first.cpuNs
+	if span > 0 && span != r.window {
+		ns = uint64(float64(ns) * float64(r.window) / float64(span))
+	}
+
+	return ns, true
+}
+
+// readProcessCPUTime 读取 /proc/<pid>/stat 中的 utime+stime，覆盖进程内所有线程
+func readProcessCPUTime(pid uint32) (uint64, error) {
+	raw, err := os.ReadFile(config.GetProcPath(fmt.Sprintf("%d/stat", pid)))
+	if err != nil {
+		return 0, err
+	}
+
+	// comm 可能包含空格，从最后一个右括号之后开始解析
+	stat := string(raw)
+	idx := strings.LastIndexByte(stat, ')')
+	if idx < 0 {
+		return 0, fmt.Errorf("malformed stat of pid %d", pid)
+	}
+
+	// 右括号后第一个字段为 state(3)，utime 与 stime 分别为第 14、15 个字段
+	fields := strings.Fields(stat[idx+1:])
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("malformed stat of pid %d", pid)
+	}
+
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return (utime + stime) * uint64(time.Second) / clockTicks, nil
+}
@@ -0,0 +1,61 @@
+package analysis
+
+import (
+	"context"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cen-ngc5139/shepherd/internal/output"
+)
+
+// episodeSinkSpec 同一事件的开始与结束由 ClickHouse 的 ReplacingMergeTree 合并
+var episodeSinkSpec = output.SinkSpec[Episode]{
+	Name:         "noisy neighbor episode",
+	DefaultTopic: "shepherd-episodes",
+	InsertSQL: `
+	INSERT INTO noisy_neighbor_episodes (
+		id, node, state,
+		victim_pid, victim_comm, victim_cgroup_id, victim_pod, victim_namespace,
+		aggressor_pid, aggressor_comm, aggressor_cgroup_id, aggressor_pod, aggressor_namespace,
+		started_at, ended_at,
+		score, peak_score, preemptions, delay_ns, peak_delay_ns, runtime_share,
+		updated_at
+	)
+`,
+	Columns: func(e Episode) []any {
+		return []any{
+			e.ID, e.Node, string(e.State),
+			e.Victim.Pid, e.Victim.Comm, e.Victim.CgroupID, e.Victim.Pod, e.Victim.Namespace,
+			e.Aggressor.Pid, e.Aggressor.Comm, e.Aggressor.CgroupID, e.Aggressor.Pod, e.Aggressor.Namespace,
+			e.StartedAt, e.EndedAt,
+			e.Score, e.PeakScore, e.Preemptions, e.DelayNs, e.PeakDelayNs, e.RuntimeShare,
+			time.Now(),
+		}
+	},
+}
+
+// Sink 将干扰事件的开始与结束写入外部存储
+type Sink struct {
+	*output.Sink[Episode]
+}
+
+func NewSink(cfg config.Configuration, ctx context.Context) (*Sink, error) {
+	sinkCfg := cfg.Analysis.Sink
+	if sinkCfg.Type == "" {
+		sinkCfg.Type = config.OutputTypeFile
+	}
+
+	s, err := output.NewSink(cfg, sinkCfg, episodeSinkSpec, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sink{Sink: s}, nil
+}
+
+func (s *Sink) OnEpisode(episode Episode) {
+	if err := s.Write(episode); err != nil {
+		log.Errorf("failed to write noisy neighbor episode %s: %v", episode.ID, err)
+	}
+}
@@ -0,0 +1,64 @@
+package analysis
+
+import "time"
+
+// bucketsPerWindow 滑动窗口切分的桶数，桶越多窗口边界越平滑
+const bucketsPerWindow = 12
+
+type bucket struct {
+	start   time.Time
+	count   uint64
+	delayNs uint64
+}
+
+// slidingWindow 按时间分桶累计抢占次数与延迟，内存占用与事件数量无关
+type slidingWindow struct {
+	size    time.Duration
+	step    time.Duration
+	buckets []bucket
+}
+
+func newSlidingWindow(size time.Duration) *slidingWindow {
+	step := size / bucketsPerWindow
+	if step <= 0 {
+		step = size
+	}
+
+	return &slidingWindow{size: size, step: step}
+}
+
+func (w *slidingWindow) add(now time.Time, delayNs uint64) {
+	start := now.Truncate(w.step)
+	if n := len(w.buckets); n > 0 && w.buckets[n-1].start.Equal(start) {
+		w.buckets[n-1].count++
+		w.buckets[n-1].delayNs += delayNs
+		return
+	}
+
+	w.prune(now)
+	w.buckets = append(w.buckets, bucket{start: start, count: 1, delayNs: delayNs})
+}
+
+// prune 丢弃完全落在窗口外的桶
+func (w *slidingWindow) prune(now time.Time) {
+	i := 0
+	for i < len(w.buckets) && now.Sub(w.buckets[i].start) > w.size+w.step {
+		i++
+	}
+	w.buckets = w.buckets[i:]
+}
+
+// sum 返回窗口内的次数与累计延迟
+func (w *slidingWindow) sum(now time.Time) (count, delayNs uint64) {
+	w.prune(now)
+	for _, b := range w.buckets {
+		count += b.count
+		delayNs += b.delayNs
+	}
+
+	return count, delayNs
+}
+
+func (w *slidingWindow) empty() bool {
+	return len(w.buckets) == 0
+}
@@ -5,14 +5,41 @@ import (
 )
 
 var (
+	// 程序名 -> tracepoint 名，_tp 后缀为经典 tracepoint 变体，加载前只保留其中一种
 	SchedTracepointTargetProgs = map[string]string{
-		"sched_wakeup":     "sched_wakeup",
-		"sched_wakeup_new": "sched_wakeup_new",
-		"sched_switch":     "sched_switch",
+		"sched_wakeup":          "sched_wakeup",
+		"sched_wakeup_new":      "sched_wakeup_new",
+		"sched_switch":          "sched_switch",
+		"sched_migrate_task":    "sched_migrate_task",
+		"sched_wakeup_tp":       "sched_wakeup",
+		"sched_wakeup_new_tp":   "sched_wakeup_new",
+		"sched_switch_tp":       "sched_switch",
+		"sched_migrate_task_tp": "sched_migrate_task",
+		// 仅经典 tracepoint 变体需要，用于记录新线程的 cgroup 与清理 tid -> tgid 缓存
+		"sched_process_fork_tp": "sched_process_fork",
+		"sched_process_exit_tp": "sched_process_exit",
+	}
+
+	IrqTracepointTargetProgs = map[string]string{
+		"irq_handler_entry":    "irq_handler_entry",
+		"irq_handler_exit":     "irq_handler_exit",
+		"softirq_entry":        "softirq_entry",
+		"softirq_exit":         "softirq_exit",
+		"irq_handler_entry_tp": "irq_handler_entry",
+		"irq_handler_exit_tp":  "irq_handler_exit",
+		"softirq_entry_tp":     "softirq_entry",
+		"softirq_exit_tp":      "softirq_exit",
+	}
+
+	// CfsThrottleKprobes CFS 带宽限流与解除限流，程序名 -> 内核函数
+	CfsThrottleKprobes = map[string]string{
+		"kprobe_throttle_cfs_rq":   "throttle_cfs_rq",
+		"kprobe_unthrottle_cfs_rq": "unthrottle_cfs_rq",
 	}
 )
 
-func AttachTracepointProgs(coll *ebpf.Collection, target map[string]string, group string) (*tracing, error) {
+// AttachTracepointProgs 挂载 target 中的程序，pinDir 非空时将链接固定到该目录
+func AttachTracepointProgs(coll *ebpf.Collection, target map[string]string, group, pinDir string) (*tracing, error) {
 	btfTracepointProgs := map[string]*ebpf.Program{}
 	tracepointProgs := map[string]*ebpf.Program{}
 	for name, prog := range coll.Programs {
@@ -29,7 +56,7 @@ func AttachTracepointProgs(coll *ebpf.Collection, target map[string]string, grou
 		}
 	}
 
-	t := &tracing{}
+	t := &tracing{pinDir: pinDir}
 	if err := t.Tracepoint(group, tracepointProgs); err != nil {
 		return nil, err
 	}
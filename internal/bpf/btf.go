@@ -0,0 +1,195 @@
+package bpf
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cilium/ebpf/btf"
+	"github.com/pkg/errors"
+	"github.com/ulikunitz/xz"
+	"golang.org/x/sys/unix"
+)
+
+const kernelBTFPath = "/sys/kernel/btf/vmlinux"
+
+// btfSuffixes model_dir 中支持的 BTF 文件后缀，BTFHub 归档为 .btf.tar.xz
+var btfSuffixes = []string{".btf", ".btf.gz", ".btf.xz", ".btf.tar.xz", ".btf.tar.gz"}
+
+// LoadKernelBTF 加载内核 BTF，依次尝试 btf.kernel、/sys/kernel/btf/vmlinux 与 btf.model_dir，返回 BTF 来源
+func LoadKernelBTF(cfg config.BTFConfig) (*btf.Spec, string, error) {
+	if cfg.Kernel != "" {
+		spec, err := loadBTFFile(cfg.Kernel)
+		if err != nil {
+			return nil, "", err
+		}
+		return spec, cfg.Kernel, nil
+	}
+
+	tried := []string{kernelBTFPath}
+	if _, err := os.Stat(kernelBTFPath); err == nil {
+		spec, err := btf.LoadKernelSpec()
+		if err != nil {
+			return nil, "", errors.Wrap(err, "failed to load kernel btf")
+		}
+		return spec, kernelBTFPath, nil
+	}
+
+	if cfg.ModelDir != "" {
+		candidates, err := btfCandidates(cfg.ModelDir)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, path := range candidates {
+			tried = append(tried, path)
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+
+			spec, err := loadBTFFile(path)
+			if err != nil {
+				return nil, "", err
+			}
+			return spec, path, nil
+		}
+	}
+
+	return nil, "", errors.Errorf("no btf found for this kernel, tried:\n  %s", strings.Join(tried, "\n  "))
+}
+
+// btfCandidates 按 BTFHub 目录结构 <distro>/<release>/<arch>/<kernel>.btf 生成候选路径，并兼容直接放在 model_dir 下的文件
+func btfCandidates(dir string) ([]string, error) {
+	var uname unix.Utsname
+	if err := unix.Uname(&uname); err != nil {
+		return nil, errors.Wrap(err, "failed to get kernel release")
+	}
+	release := unix.ByteSliceToString(uname.Release[:])
+	arch := unix.ByteSliceToString(uname.Machine[:])
+	// BTFHub 中 arm64 目录名为 arm64 而非 aarch64
+	if arch == "aarch64" {
+		arch = "arm64"
+	}
+
+	var dirs []string
+	if id, version := osRelease(); id != "" && version != "" {
+		dirs = append(dirs, filepath.Join(dir, id, version, arch))
+	}
+	dirs = append(dirs, dir)
+
+	var candidates []string
+	for _, d := range dirs {
+		for _, suffix := range btfSuffixes {
+			candidates = append(candidates, filepath.Join(d, release+suffix))
+		}
+	}
+
+	return candidates, nil
+}
+
+// osRelease 读取宿主机的发行版 ID 与版本号，容器内优先通过 /proc/1/root 访问宿主机文件
+func osRelease() (string, string) {
+	paths := []string{
+		config.GetProcPath("1/root/etc/os-release"),
+		"/etc/os-release",
+		"/usr/lib/os-release",
+	}
+
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+
+		var id, version string
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			// ID="centos"
+			key, value, ok := strings.Cut(scanner.Text(), "=")
+			if !ok {
+				continue
+			}
+			value = strings.Trim(value, `"'`)
+			switch key {
+			case "ID":
+				id = value
+			case "VERSION_ID":
+				version = value
+			}
+		}
+		f.Close()
+
+		if id != "" {
+			return id, version
+		}
+	}
+
+	return "", ""
+}
+
+// loadBTFFile 按后缀解压并加载 BTF，支持原始 BTF、ELF 以及 gzip/xz 压缩与 tar 归档
+func loadBTFFile(path string) (*btf.Spec, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open btf %s", path)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	name := path
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decompress btf %s", path)
+		}
+		defer gr.Close()
+		r, name = gr, strings.TrimSuffix(name, ".gz")
+	case strings.HasSuffix(name, ".xz"):
+		xr, err := xz.NewReader(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decompress btf %s", path)
+		}
+		r, name = xr, strings.TrimSuffix(name, ".xz")
+	}
+
+	if strings.HasSuffix(name, ".tar") {
+		if r, err = btfFromTar(r); err != nil {
+			return nil, errors.Wrapf(err, "failed to extract btf %s", path)
+		}
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read btf %s", path)
+	}
+
+	spec, err := btf.LoadSpecFromReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse btf %s", path)
+	}
+
+	return spec, nil
+}
+
+// btfFromTar 返回归档中第一个 .btf 文件
+func btfFromTar(r io.Reader) (io.Reader, error) {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.New("no .btf file in archive")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag == tar.TypeReg && strings.HasSuffix(hdr.Name, ".btf") {
+			return tr, nil
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package bpf
+
+import (
+	"github.com/cen-ngc5139/shepherd/internal/binary"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+)
+
+const (
+	SchedConfigMap    = "sched_config"
+	CgroupPoliciesMap = "cgroup_policies"
+	LatencyHistsMap   = "latency_hists"
+	StackTracesMap    = "stack_traces"
+	StackErrorsMap    = "stack_errors"
+	RqStatsMap        = "rq_stats"
+	CPUIrqTimeMap     = "cpu_irq_time"
+	IrqVecTimeMap     = "irq_vec_time"
+	CfsThrottleStats  = "cfs_throttle_stats"
+	ProbeFuncStatsMap = "probe_func_stats"
+)
+
+// 与 trace.c 中 AGGREGATE_* 一致
+const (
+	aggregateCgroup uint32 = 1
+	aggregateTgid   uint32 = 2
+)
+
+// NodeSchedPolicy 根据配置生成节点默认采集策略
+func NodeSchedPolicy(cfg config.SchedConfig) binary.ShepherdSchedPolicyT {
+	policy := binary.ShepherdSchedPolicyT{
+		ThresholdNs:   cfg.ThresholdNs,
+		SamplingRatio: cfg.SamplingRatio,
+	}
+
+	switch cfg.Aggregation.Mode {
+	case config.AggregationByCgroup:
+		policy.Aggregate = aggregateCgroup
+	case config.AggregationByTgid:
+		policy.Aggregate = aggregateTgid
+	}
+	if cfg.Aggregation.Mode != "" && cfg.Aggregation.DisableRawEvents {
+		policy.DisableEvents = 1
+	}
+	if cfg.Stacks.Enable {
+		policy.CaptureStacks = 1
+	}
+	if cfg.RunQueue.Enable {
+		policy.RunqueueStats = 1
+	}
+
+	return policy
+}
+
+// SetSchedConfig 更新节点默认采集策略
+func SetSchedConfig(coll *ebpf.Collection, policy binary.ShepherdSchedPolicyT) error {
+	m, ok := coll.Maps[SchedConfigMap]
+	if !ok {
+		return errors.Errorf("map %s not found", SchedConfigMap)
+	}
+
+	var key uint32
+	return errors.Wrapf(m.Put(key, policy), "failed to update %s", SchedConfigMap)
+}
@@ -0,0 +1,165 @@
+package bpf
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/features"
+	"github.com/cilium/ebpf/link"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	SchedEventsMap        = "sched_events"
+	SchedEventsRingbufMap = "sched_events_rb"
+
+	useRingbufVar = "use_ringbuf"
+
+	// classicSuffix 经典 tracepoint 变体的程序名后缀，与 tp_btf 变体成对出现
+	classicSuffix = "_tp"
+
+	VariantTpBTF      = "tp_btf"
+	VariantTracepoint = "tracepoint"
+
+	TransportRingbuf = "ringbuf"
+	TransportPerf    = "perf"
+)
+
+var tracefsDirs = []string{"/sys/kernel/tracing", "/sys/kernel/debug/tracing"}
+
+// Features 运行时探测到的内核特性及据此选择的程序变体
+type Features struct {
+	KernelRelease string `json:"kernel_release"`
+	KernelBTF     bool   `json:"kernel_btf"` // 内核内置 BTF，tp_btf 与 fentry 依赖内核自身的 BTF
+	BTFSource     string `json:"btf_source"`
+	TpBTF         bool   `json:"tp_btf"`
+	Fentry        bool   `json:"fentry"`
+	Ringbuf       bool   `json:"ringbuf"`
+	KprobeMulti   bool   `json:"kprobe_multi"`
+	Tracefs       string `json:"tracefs"` // 为空时 tracefs 与 debugfs 均未挂载
+
+	Variant   string `json:"variant"`
+	Transport string `json:"transport"`
+}
+
+// ProbeFeatures 探测内核特性，btfSource 为 LoadKernelBTF 返回的 BTF 来源
+func ProbeFeatures(btfSource string) *Features {
+	var uname unix.Utsname
+	_ = unix.Uname(&uname)
+
+	f := &Features{
+		KernelRelease: unix.ByteSliceToString(uname.Release[:]),
+		BTFSource:     btfSource,
+		Ringbuf:       features.HaveMapType(ebpf.RingBuf) == nil,
+		KprobeMulti:   HaveKprobeMulti(),
+		Tracefs:       TracefsDir(),
+	}
+	if _, err := os.Stat(kernelBTFPath); err == nil {
+		f.KernelBTF = true
+		f.TpBTF = haveTracing(ebpf.AttachTraceRawTp, "sched_switch")
+		f.Fentry = haveTracing(ebpf.AttachTraceFEntry, "vprintk")
+	}
+
+	f.Variant = VariantTracepoint
+	if f.TpBTF {
+		f.Variant = VariantTpBTF
+	}
+	// 经典 tracepoint 变体固定使用 perf event
+	f.Transport = TransportPerf
+	if f.TpBTF && f.Ringbuf {
+		f.Transport = TransportRingbuf
+	}
+
+	return f
+}
+
+// haveTracing 加载并挂载一个空的 tracing 程序，部分架构只支持加载而不支持挂载 trampoline
+func haveTracing(attachType ebpf.AttachType, target string) bool {
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Name:       "probe_tracing",
+		Type:       ebpf.Tracing,
+		AttachType: attachType,
+		AttachTo:   target,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		License: "GPL",
+	})
+	if err != nil {
+		return false
+	}
+	defer prog.Close()
+
+	l, err := link.AttachTracing(link.TracingOptions{Program: prog})
+	if err != nil {
+		return false
+	}
+	_ = l.Close()
+
+	return true
+}
+
+// TracefsDir 返回 tracefs 挂载点，优先 /sys/kernel/tracing，其次 debugfs 下的 tracing
+func TracefsDir() string {
+	for _, dir := range tracefsDirs {
+		if _, err := os.Stat(filepath.Join(dir, "events")); err == nil {
+			return dir
+		}
+	}
+
+	return ""
+}
+
+// SelectProgramVariants 按探测结果从 spec 中保留一种 tracepoint 变体，并设置事件传输方式
+func SelectProgramVariants(spec *ebpf.CollectionSpec, f *Features) error {
+	for name, ps := range spec.Programs {
+		classic := ps.Type == ebpf.TracePoint && strings.HasSuffix(name, classicSuffix)
+		btf := ps.Type == ebpf.Tracing && ps.AttachType == ebpf.AttachTraceRawTp
+		if (f.TpBTF && classic) || (!f.TpBTF && btf) {
+			delete(spec.Programs, name)
+		}
+	}
+
+	if f.Transport == TransportRingbuf {
+		v, ok := spec.Variables[useRingbufVar]
+		if !ok {
+			return errors.Errorf("variable %s not found", useRingbufVar)
+		}
+		return errors.Wrapf(v.Set(uint32(1)), "failed to set %s", useRingbufVar)
+	}
+
+	// 不使用 ring buffer 时替换为占位的数组，不支持的内核无法创建该 map，引用它的分支也不会被执行
+	if _, ok := spec.Maps[SchedEventsRingbufMap]; ok {
+		spec.Maps[SchedEventsRingbufMap] = &ebpf.MapSpec{
+			Name:       SchedEventsRingbufMap,
+			Type:       ebpf.Array,
+			KeySize:    4,
+			ValueSize:  4,
+			MaxEntries: 1,
+		}
+	}
+
+	return nil
+}
+
+// Log 在启动时输出特性报告
+func (f *Features) Log() {
+	log.Infof("kernel %s, btf: kernel %t source %s, tp_btf: %t, fentry: %t, ringbuf: %t, kprobe.multi: %t, tracefs: %q",
+		f.KernelRelease, f.KernelBTF, f.BTFSource, f.TpBTF, f.Fentry, f.Ringbuf, f.KprobeMulti, f.Tracefs)
+	log.Infof("using %s programs, events via %s", f.Variant, f.Transport)
+}
+
+// RegisterRoutes 注册特性报告查询接口
+func (f *Features) RegisterRoutes(r gin.IRouter) {
+	r.GET("/features", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, f)
+	})
+}
@@ -4,11 +4,14 @@
 package bpf
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 
@@ -20,6 +23,7 @@ import (
 
 type kprober struct {
 	links []link.Link
+	progs []*ebpf.Program
 
 	kprobeMulti bool
 	kprobeBatch uint
@@ -29,6 +33,7 @@ type Kprobe struct {
 	hookFunc  string // internal use
 	HookFuncs []string
 	Prog      *ebpf.Program
+	Retprobe  bool
 }
 
 func attachKprobes(ctx context.Context, bar *pb.ProgressBar, kprobes []Kprobe) (links []link.Link, ignored int, err error) {
@@ -42,7 +47,11 @@ func attachKprobes(ctx context.Context, bar *pb.ProgressBar, kprobes []Kprobe) (
 		}
 
 		var kp link.Link
-		kp, err = link.Kprobe(kprobe.hookFunc, kprobe.Prog, nil)
+		if kprobe.Retprobe {
+			kp, err = link.Kretprobe(kprobe.hookFunc, kprobe.Prog, nil)
+		} else {
+			kp, err = link.Kprobe(kprobe.hookFunc, kprobe.Prog, nil)
+		}
 		if err != nil {
 			if !errors.Is(err, os.ErrNotExist) && !errors.Is(err, syscall.EADDRNOTAVAIL) {
 				err = fmt.Errorf("opening kprobe %s: %w", kprobe.hookFunc, err)
@@ -73,6 +82,7 @@ func AttachKprobes(ctx context.Context, bar *pb.ProgressBar, kps []Kprobe, batch
 			kprobes = append(kprobes, Kprobe{
 				hookFunc: fn,
 				Prog:     kp.Prog,
+				Retprobe: kp.Retprobe,
 			})
 		}
 	}
@@ -128,6 +138,7 @@ func (k *kprober) DetachKprobes() {
 	links := k.links
 	bar := pb.StartNew(len(links))
 	defer bar.Finish()
+	defer k.closeProgs()
 
 	batch := k.kprobeBatch
 	if k.kprobeMulti || batch >= uint(len(links)) {
@@ -159,6 +170,52 @@ func (k *kprober) DetachKprobes() {
 	_ = errg.Wait()
 }
 
+func (k *kprober) closeProgs() {
+	for _, p := range k.progs {
+		_ = p.Close()
+	}
+	k.progs = nil
+}
+
+// KernelFuncsExist 检查函数是否全部位于 kallsyms 中，被内联或被编译器重命名(如 .isra.0)的函数无法挂载 kprobe
+func KernelFuncsExist(funcs ...string) bool {
+	return len(KernelFuncAddrs(funcs...)) == len(funcs)
+}
+
+// KernelFuncAddrs 返回函数在 kallsyms 中的名称 -> 地址，未找到的函数不包含在结果中
+func KernelFuncAddrs(funcs ...string) map[string]uint64 {
+	addrs := make(map[string]uint64, len(funcs))
+	f, err := os.Open("/proc/kallsyms")
+	if err != nil {
+		return addrs
+	}
+	defer f.Close()
+
+	wanted := make(map[string]struct{}, len(funcs))
+	for _, fn := range funcs {
+		wanted[fn] = struct{}{}
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() && len(addrs) < len(wanted) {
+		// ffffffff810c3a40 t throttle_cfs_rq
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || (fields[1] != "t" && fields[1] != "T") {
+			continue
+		}
+		if _, ok := wanted[fields[2]]; !ok {
+			continue
+		}
+		if _, ok := addrs[fields[2]]; ok {
+			continue
+		}
+		addr, _ := strconv.ParseUint(fields[0], 16, 64)
+		addrs[fields[2]] = addr
+	}
+
+	return addrs
+}
+
 func NewCustomFuncsKprober(manifest map[string]string, coll *ebpf.Collection) *kprober {
 	var k kprober
 	k.kprobeBatch = uint(len(manifest))
@@ -0,0 +1,93 @@
+package bpf
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+)
+
+// verifierStatsRe 校验器统计行，如 processed 1234 insns (limit 1000000) max_states_per_insn 4 total_states 56 peak_states 56 mark_read 12
+var verifierStatsRe = regexp.MustCompile(`processed (\d+) insns \(limit \d+\) max_states_per_insn (\d+) total_states (\d+) peak_states (\d+)`)
+
+// VerifierStats 校验器统计的程序复杂度
+type VerifierStats struct {
+	ProcessedInsns   int `json:"processed_insns"`
+	MaxStatesPerInsn int `json:"max_states_per_insn"`
+	TotalStates      int `json:"total_states"`
+	PeakStates       int `json:"peak_states"`
+}
+
+// LoadProgram 单独加载程序，引用的 map 使用 coll 中已创建的 map，ps 需为副本
+func LoadProgram(ps *ebpf.ProgramSpec, coll *ebpf.Collection, opts ebpf.ProgramOptions) (*ebpf.Program, error) {
+	for i := range ps.Instructions {
+		ins := &ps.Instructions[i]
+		if !ins.IsLoadFromMap() {
+			continue
+		}
+
+		m, ok := coll.Maps[ins.Reference()]
+		if !ok {
+			return nil, errors.Errorf("map %s of program %s not found", ins.Reference(), ps.Name)
+		}
+		if err := ins.AssociateMap(m); err != nil {
+			return nil, errors.Wrapf(err, "failed to associate map %s", ins.Reference())
+		}
+	}
+
+	prog, err := ebpf.NewProgramWithOptions(ps, opts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load program %s", ps.Name)
+	}
+
+	return prog, nil
+}
+
+// VerifierLogTail 返回加载错误中校验器日志的最后 n 行，非校验器错误返回空
+func VerifierLogTail(err error, n int) []string {
+	var ve *ebpf.VerifierError
+	if !errors.As(err, &ve) {
+		return nil
+	}
+
+	lines := ve.Log
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// ParseVerifierStats 从 LogLevelStats 级别的校验器日志中解析统计行
+func ParseVerifierStats(log string) (VerifierStats, bool) {
+	match := verifierStatsRe.FindStringSubmatch(log)
+	if match == nil {
+		return VerifierStats{}, false
+	}
+
+	values := make([]int, len(match)-1)
+	for i, s := range match[1:] {
+		values[i], _ = strconv.Atoi(s)
+	}
+
+	return VerifierStats{
+		ProcessedInsns:   values[0],
+		MaxStatesPerInsn: values[1],
+		TotalStates:      values[2],
+		PeakStates:       values[3],
+	}, true
+}
+
+// FormatLoadError 加载失败时只输出错误与校验器日志的末尾，完整的逐程序报告使用 shepherd diagnose
+func FormatLoadError(err error, n int) string {
+	var b strings.Builder
+	b.WriteString(err.Error())
+	if tail := VerifierLogTail(err, n); len(tail) > 0 {
+		b.WriteString("\nverifier log tail:\n  ")
+		b.WriteString(strings.Join(tail, "\n  "))
+	}
+	b.WriteString("\nrun `shepherd diagnose` to load each program individually and collect a support bundle")
+
+	return b.String()
+}
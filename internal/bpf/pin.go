@@ -0,0 +1,151 @@
+package bpf
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	DefaultPinPath = "/sys/fs/bpf/shepherd"
+
+	// pinLinksDir 挂载链接固定在 <pin path>/links/<group>_<tracepoint>
+	pinLinksDir = "links"
+)
+
+// PinnedMaps 需要跨重启保留的内核态状态，配置类 map 启动时会整体重写，不做固定
+var PinnedMaps = []string{
+	"wakeup_times",
+	"task_last_cpu",
+	"task_stacks",
+	"stack_traces",
+	"task_tgids",
+	"task_cgroups",
+	"cpu_idle_since",
+	"cpu_irq_time",
+	"irq_state",
+	"cfs_throttle_start",
+}
+
+// PinPath 返回固定目录，未开启时返回空
+func PinPath(cfg config.PinConfig) string {
+	if !cfg.Enable {
+		return ""
+	}
+	if cfg.Path == "" {
+		return DefaultPinPath
+	}
+
+	return filepath.Clean(cfg.Path)
+}
+
+// PinMaps 将 PinnedMaps 设置为按名称固定，已有的固定 map 兼容时复用，不兼容时删除后重新创建
+// 加载时需要将 CollectionOptions.Maps.PinPath 设置为 dir
+func PinMaps(spec *ebpf.CollectionSpec, dir string) error {
+	if err := checkBPFFS(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, pinLinksDir), 0o700); err != nil {
+		return errors.Wrapf(err, "failed to create pin path %s", dir)
+	}
+
+	for _, name := range PinnedMaps {
+		ms, ok := spec.Maps[name]
+		if !ok {
+			continue
+		}
+		ms.Pinning = ebpf.PinByName
+
+		path := filepath.Join(dir, name)
+		m, err := ebpf.LoadPinnedMap(path, nil)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err == nil {
+			err = ms.Compatible(m)
+			m.Close()
+		}
+		if err == nil {
+			log.Infof("reusing pinned map %s", path)
+			continue
+		}
+
+		log.Warningf("pinned map %s is incompatible, replaced: %v", path, err)
+		if err := os.Remove(path); err != nil {
+			return errors.Wrapf(err, "failed to remove pinned map %s", path)
+		}
+	}
+
+	return nil
+}
+
+// attachPinned 以新程序替换固定的挂载链接：链接支持更新程序时原地更新，
+// 否则先挂载新链接再以 rename 原子替换固定路径，替换期间新旧程序同时挂载，不会出现空窗
+func (t *tracing) attachPinned(name string, prog *ebpf.Program, attach func() (link.Link, error)) error {
+	path := filepath.Join(t.pinDir, pinLinksDir, name)
+
+	old, err := link.LoadPinnedLink(path, nil)
+	if err == nil {
+		if err := old.Update(prog); err == nil {
+			t.addLink(old)
+			return nil
+		}
+		defer old.Close()
+	}
+
+	l, err := attach()
+	if err != nil {
+		return err
+	}
+	t.addLink(l)
+
+	// 旧内核中基于 perf event 的 tracepoint 链接不支持固定，agent 退出时随之卸载
+	tmp := path + ".new"
+	_ = os.Remove(tmp)
+	if err := l.Pin(tmp); err != nil {
+		log.Warningf("failed to pin link %s: %v", name, err)
+		return nil
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = l.Unpin()
+		return errors.Wrapf(err, "failed to replace pinned link %s", path)
+	}
+
+	return nil
+}
+
+// RemovePins 删除固定目录，固定的链接随之释放，程序从内核中卸载
+func RemovePins(dir string) error {
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err := checkBPFFS(dir); err != nil {
+		return err
+	}
+
+	return errors.Wrapf(os.RemoveAll(dir), "failed to remove pin path %s", dir)
+}
+
+// checkBPFFS 确认 dir 或其最近的已存在上级目录位于 bpffs，避免误删其他文件系统中的目录
+func checkBPFFS(dir string) error {
+	for path := dir; ; path = filepath.Dir(path) {
+		var st unix.Statfs_t
+		err := unix.Statfs(path, &st)
+		if errors.Is(err, unix.ENOENT) && path != filepath.Dir(path) {
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to stat %s", path)
+		}
+		if st.Type != unix.BPF_FS_MAGIC {
+			return errors.Errorf("%s is not on a bpf filesystem", dir)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,213 @@
+package bpf
+
+import (
+	"context"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	pb "github.com/cheggaaa/pb/v3"
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/link"
+	"github.com/pkg/errors"
+)
+
+// defaultKprobeBatch 逐个挂载 kprobe 时每批的数量
+const defaultKprobeBatch = 16
+
+// OptionalProbe 可按配置挂载到任意内核函数的内置探针
+type OptionalProbe struct {
+	Entry   string // kprobe 或 fentry 程序
+	Exit    string // kretprobe 或 fexit 程序，为空时只挂载入口
+	Tracing bool   // 使用 fentry/fexit，每个目标函数单独加载一份程序
+}
+
+var OptionalProbes = map[string]OptionalProbe{
+	"func_count":          {Entry: "kprobe_func_count"},
+	"func_latency":        {Entry: "kprobe_func_entry", Exit: "kretprobe_func_exit"},
+	"fentry_func_count":   {Entry: "fentry_func_count", Tracing: true},
+	"fentry_func_latency": {Entry: "fentry_func_entry", Exit: "fexit_func_exit", Tracing: true},
+}
+
+// TakeOptionalProbes 从 spec 中移出全部可选探针程序，未配置的探针不随主程序集加载
+func TakeOptionalProbes(spec *ebpf.CollectionSpec) map[string]*ebpf.ProgramSpec {
+	progs := make(map[string]*ebpf.ProgramSpec)
+	for _, probe := range OptionalProbes {
+		for _, name := range []string{probe.Entry, probe.Exit} {
+			if ps, ok := spec.Programs[name]; ok {
+				progs[name] = ps
+				delete(spec.Programs, name)
+			}
+		}
+	}
+
+	return progs
+}
+
+// HaveKprobeMulti 检查内核是否支持 kprobe.multi，需要 5.18+ 且开启 CONFIG_FPROBE
+func HaveKprobeMulti() bool {
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Name: "probe_kpm_link",
+		Type: ebpf.Kprobe,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		},
+		AttachType: ebpf.AttachTraceKprobeMulti,
+		License:    "GPL",
+	})
+	if err != nil {
+		return false
+	}
+	defer prog.Close()
+
+	l, err := link.KprobeMulti(prog, link.KprobeMultiOptions{Symbols: []string{"vprintk"}})
+	if err != nil {
+		return false
+	}
+	_ = l.Close()
+
+	return true
+}
+
+// AttachOptionalProbes 加载并挂载配置的可选探针，kprobe 在内核支持时使用 kprobe.multi，否则分批并发逐个挂载
+func AttachOptionalProbes(ctx context.Context, cfg config.ProbesConfig, f *Features, progs map[string]*ebpf.ProgramSpec,
+	coll *ebpf.Collection, opts ebpf.ProgramOptions) (*kprober, error) {
+	k := &kprober{
+		kprobeMulti: !cfg.DisableMulti && f.KprobeMulti,
+		kprobeBatch: cfg.Batch,
+	}
+	if k.kprobeBatch == 0 {
+		k.kprobeBatch = defaultKprobeBatch
+	}
+
+	kprobes, err := k.attachOptionalProbes(cfg.Items, f, progs, coll, opts)
+	if err != nil {
+		k.DetachKprobes()
+		return nil, err
+	}
+
+	if len(kprobes) > 0 {
+		var total int
+		for _, kp := range kprobes {
+			total += len(kp.HookFuncs)
+		}
+
+		bar := pb.StartNew(total)
+		links, ignored := AttachKprobes(ctx, bar, kprobes, k.kprobeBatch)
+		bar.Finish()
+		k.links = append(k.links, links...)
+		if ignored > 0 {
+			log.Warningf("%d optional kprobes ignored, kernel functions not found", ignored)
+		}
+	}
+
+	log.Infof("optional probes attached, %d links, kprobe.multi %t", len(k.links), k.kprobeMulti)
+	return k, nil
+}
+
+// attachOptionalProbes 挂载 kprobe.multi 与 fentry/fexit，返回需要逐个挂载的 kprobe
+func (k *kprober) attachOptionalProbes(items []config.ProbeConfig, f *Features, progs map[string]*ebpf.ProgramSpec,
+	coll *ebpf.Collection, opts ebpf.ProgramOptions) ([]Kprobe, error) {
+	var kprobes []Kprobe
+	for _, item := range items {
+		probe, ok := OptionalProbes[item.Name]
+		if !ok {
+			return nil, errors.Errorf("unknown probe %q", item.Name)
+		}
+		if probe.Tracing && !f.Fentry {
+			return nil, errors.Errorf("probe %s requires fentry, which is not supported by this kernel", item.Name)
+		}
+
+		// kprobe.multi 中任一函数不存在都会导致整体挂载失败，提前过滤
+		addrs := KernelFuncAddrs(item.Funcs...)
+		var funcs []string
+		for _, fn := range item.Funcs {
+			if _, ok := addrs[fn]; ok {
+				funcs = append(funcs, fn)
+			} else {
+				log.Warningf("probe %s: kernel function %s not found in kallsyms, skipped", item.Name, fn)
+			}
+		}
+		if len(funcs) == 0 {
+			continue
+		}
+
+		for _, name := range []string{probe.Entry, probe.Exit} {
+			if name == "" {
+				continue
+			}
+			ps, ok := progs[name]
+			if !ok {
+				return nil, errors.Errorf("program %s not found", name)
+			}
+			retprobe := name == probe.Exit
+
+			if probe.Tracing {
+				for _, fn := range funcs {
+					if err := k.attachTracing(ps, fn, coll, opts); err != nil {
+						return nil, err
+					}
+				}
+				continue
+			}
+
+			ps = ps.Copy()
+			if k.kprobeMulti {
+				ps.AttachType = ebpf.AttachTraceKprobeMulti
+			}
+			prog, err := k.loadProbe(ps, coll, opts)
+			if err != nil {
+				return nil, err
+			}
+
+			if !k.kprobeMulti {
+				kprobes = append(kprobes, Kprobe{HookFuncs: funcs, Prog: prog, Retprobe: retprobe})
+				continue
+			}
+
+			multiOpts := link.KprobeMultiOptions{Symbols: funcs}
+			var l link.Link
+			if retprobe {
+				l, err = link.KretprobeMulti(prog, multiOpts)
+			} else {
+				l, err = link.KprobeMulti(prog, multiOpts)
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to attach %s to %v", name, funcs)
+			}
+			k.links = append(k.links, l)
+		}
+	}
+
+	return kprobes, nil
+}
+
+// attachTracing fentry/fexit 的目标函数在加载时确定，每个函数单独加载
+func (k *kprober) attachTracing(ps *ebpf.ProgramSpec, fn string, coll *ebpf.Collection, opts ebpf.ProgramOptions) error {
+	ps = ps.Copy()
+	ps.AttachTo = fn
+	prog, err := k.loadProbe(ps, coll, opts)
+	if err != nil {
+		return err
+	}
+
+	l, err := link.AttachTracing(link.TracingOptions{Program: prog})
+	if err != nil {
+		return errors.Wrapf(err, "failed to attach %s to %s", ps.Name, fn)
+	}
+	k.links = append(k.links, l)
+
+	return nil
+}
+
+// loadProbe 单独加载探针程序并在解除挂载时关闭，ps 需为副本
+func (k *kprober) loadProbe(ps *ebpf.ProgramSpec, coll *ebpf.Collection, opts ebpf.ProgramOptions) (*ebpf.Program, error) {
+	prog, err := LoadProgram(ps, coll, opts)
+	if err != nil {
+		return nil, err
+	}
+	k.progs = append(k.progs, prog)
+
+	return prog, nil
+}
@@ -19,6 +19,8 @@ type tracing struct {
 	sync.Mutex
 	links []link.Link
 	progs []*ebpf.Program
+
+	pinDir string // 非空时挂载链接固定到该目录
 }
 
 func (t *tracing) HaveTracing() bool {
@@ -71,14 +73,29 @@ func (t *tracing) Merge(adds ...*tracing) {
 	}
 }
 
+// attach 挂载并记录链接，开启固定时替换已固定的同名链接
+func (t *tracing) attach(name string, prog *ebpf.Program, attach func() (link.Link, error)) error {
+	if t.pinDir != "" {
+		return t.attachPinned(name, prog, attach)
+	}
+
+	l, err := attach()
+	if err != nil {
+		return err
+	}
+	t.addLink(l)
+
+	return nil
+}
+
 func (t *tracing) trace(group, tracingName string, prog *ebpf.Program) error {
-	tracing, err := link.Tracepoint(group, tracingName, prog, nil)
+	err := t.attach(group+"_"+tracingName, prog, func() (link.Link, error) {
+		return link.Tracepoint(group, tracingName, prog, nil)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to attach tracing: %w", err)
 	}
 
-	t.addLink(tracing)
-
 	return nil
 }
 
@@ -97,20 +114,25 @@ func (t *tracing) Tracepoint(group string, progs map[string]*ebpf.Program) error
 func (t *tracing) Tracing(group string, progs map[string]*ebpf.Program) error {
 	log.Printf("正在附加 %s 组的 tp_btf 程序...\n", group)
 
-	for _, prog := range progs {
-		tracing, err := link.AttachTracing(link.TracingOptions{Program: prog})
+	for tracepointName, prog := range progs {
+		err := t.attach(group+"_"+tracepointName, prog, func() (link.Link, error) {
+			return link.AttachTracing(link.TracingOptions{Program: prog})
+		})
 		if err != nil {
 			return fmt.Errorf("附加 %s 组的 TracePoint 程序失败: %v", group, err)
 		}
-
-		t.addLink(tracing)
 	}
 
 	return nil
 }
 
 func IsTracepointExist(group, tracepointName string) bool {
-	tracepointPath := filepath.Join("/sys/kernel/debug/tracing/events", group, tracepointName, "enable")
+	dir := TracefsDir()
+	if dir == "" {
+		return false
+	}
+
+	tracepointPath := filepath.Join(dir, "events", group, tracepointName, "enable")
 	_, err := os.Stat(tracepointPath)
 	return err == nil
 }
@@ -12,7 +12,25 @@ var SchedMetricsMap *sync.Map
 // value: metadata.SchedPreempted
 var SchedPreemptedMap *sync.Map
 
+// SchedCPUMap 按 CPU 与调度类汇总的调度延迟
+// key: metadata.SchedCPUKey
+// value: metadata.SchedCPUMetrics
+var SchedCPUMap *sync.Map
+
+// SchedWakerMap 按唤醒方进程名与唤醒上下文汇总的调度延迟
+// key: metadata.SchedWakerKey
+// value: metadata.SchedWakerMetrics
+var SchedWakerMap *sync.Map
+
+// SchedImbalanceMap 按 CPU 汇总的负载不均衡调度延迟
+// key: cpu
+// value: metadata.SchedImbalanceMetrics
+var SchedImbalanceMap *sync.Map
+
 func init() {
 	SchedMetricsMap = new(sync.Map)
 	SchedPreemptedMap = new(sync.Map)
+	SchedCPUMap = new(sync.Map)
+	SchedWakerMap = new(sync.Map)
+	SchedImbalanceMap = new(sync.Map)
 }
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Validate 检查过滤模式是否合法
+func (r FilterRule) Validate() error {
+	switch r.Mode {
+	case "", FilterAllow, FilterDeny:
+		return nil
+	default:
+		return errors.Errorf("invalid filter mode %q, must be allow or deny", r.Mode)
+	}
+}
+
+// ParseFilterRule 解析命令行过滤规则，如 allow:1234,5678
+func ParseFilterRule(arg string) (FilterRule, error) {
+	mode, values, ok := strings.Cut(arg, ":")
+	if !ok {
+		return FilterRule{}, errors.Errorf("invalid filter %q, expected <allow|deny>:<value>[,<value>...]", arg)
+	}
+
+	rule := FilterRule{Mode: FilterMode(mode)}
+	for _, v := range strings.Split(values, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			rule.Values = append(rule.Values, v)
+		}
+	}
+
+	return rule, rule.Validate()
+}
+
+// ApplyFilterArgs 使用命令行指定的过滤规则覆盖配置文件
+func (c *Configuration) ApplyFilterArgs() error {
+	args := []struct {
+		arg  string
+		rule *FilterRule
+	}{
+		{c.FilterArgs.Tgid, &c.Sched.Filter.Tgid},
+		{c.FilterArgs.Comm, &c.Sched.Filter.Comm},
+		{c.FilterArgs.Cgroup, &c.Sched.Filter.Cgroup},
+	}
+
+	for _, a := range args {
+		if a.arg == "" {
+			continue
+		}
+		rule, err := ParseFilterRule(a.arg)
+		if err != nil {
+			return err
+		}
+		*a.rule = rule
+	}
+
+	return nil
+}
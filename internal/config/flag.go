@@ -10,8 +10,26 @@ func SetFlags(pflag *pflag.FlagSet) {
 	pflag.AddGoFlagSet(flag.CommandLine)
 
 	pflag.StringVar(&Config.ConfigPath, "config-path", "", "specify config file path")
+	pflag.StringVar(&Config.FilterArgs.Tgid, "filter-tgid", "", "filter traced processes by tgid, e.g. allow:1234,5678")
+	pflag.StringVar(&Config.FilterArgs.Comm, "filter-comm", "", "filter traced processes by comm prefix, e.g. deny:kworker,ksoftirqd")
+	pflag.StringVar(&Config.FilterArgs.Cgroup, "filter-cgroup", "", "filter traced processes by cgroup id or path, e.g. allow:/kubepods.slice")
+
+	pflag.StringVar(&Config.PinPathArg, "pin-path", "", "bpffs directory for pinned maps and links, overrides pin.path")
 
 	pflag.Set("logtostderr", "false")
 	pflag.Set("alsologtostderr", "false")
 	pflag.Set("log_file", "")
 }
+
+// SetCleanupFlags cleanup 子命令只需要定位固定目录
+func SetCleanupFlags(pflag *pflag.FlagSet) {
+	pflag.StringVar(&Config.ConfigPath, "config-path", "", "specify config file path")
+	pflag.StringVar(&Config.PinPathArg, "pin-path", "", "bpffs directory for pinned maps and links, overrides pin.path")
+}
+
+// SetDiagnoseFlags diagnose 子命令按配置文件中的 btf 设置加载程序
+func SetDiagnoseFlags(pflag *pflag.FlagSet) {
+	pflag.StringVar(&Config.ConfigPath, "config-path", "", "specify config file path")
+	pflag.StringVarP(&Config.DiagnoseArgs.Output, "output", "o", "", "support bundle path, defaults to shepherd-diagnose-<host>-<time>.tar.gz")
+	pflag.IntVar(&Config.DiagnoseArgs.LogLines, "log-lines", 20, "verifier log lines to keep for each failed program")
+}
@@ -1,11 +1,105 @@
 package config
 
+import "time"
+
 type Configuration struct {
-	Pprof      PprofConfig   `yaml:"pprof"`
-	BTF        BTFConfig     `yaml:"btf"`
-	Output     OutputConfig  `yaml:"output"`
-	Logging    LoggingConfig `yaml:"logging"`
-	ConfigPath string        `yaml:"-"`
+	Pprof        PprofConfig      `yaml:"pprof"`
+	BTF          BTFConfig        `yaml:"btf"`
+	Output       OutputConfig     `yaml:"output"`
+	Logging      LoggingConfig    `yaml:"logging"`
+	Sched        SchedConfig      `yaml:"sched"`
+	Kubernetes   KubernetesConfig `yaml:"kubernetes"`
+	Analysis     AnalysisConfig   `yaml:"analysis"`
+	Mitigation   MitigationConfig `yaml:"mitigation"`
+	Throttle     ThrottleConfig   `yaml:"throttle"`
+	Probes       ProbesConfig     `yaml:"probes"`
+	Pin          PinConfig        `yaml:"pin"`
+	Debug        DebugConfig      `yaml:"debug"`
+	ConfigPath   string           `yaml:"-"`
+	FilterArgs   FilterArgs       `yaml:"-"`
+	PinPathArg   string           `yaml:"-"` // 命令行指定的固定目录，优先于 pin.path
+	DiagnoseArgs DiagnoseArgs     `yaml:"-"`
+}
+
+// SchedConfig 节点默认的调度延迟采集策略，0 表示使用内置默认值
+type SchedConfig struct {
+	ThresholdNs   uint64            `yaml:"threshold_ns"`   // 延迟阈值，默认 1ms
+	SamplingRatio uint64            `yaml:"sampling_ratio"` // 高频事件采样率 1/N，默认 100
+	Aggregation   AggregationConfig `yaml:"aggregation"`
+	Stacks        StacksConfig      `yaml:"stacks"`
+	RunQueue      RunQueueConfig    `yaml:"runqueue"`
+	IRQ           IRQConfig         `yaml:"irq"`
+	Filter        FilterConfig      `yaml:"filter"`
+}
+
+// FilterConfig 在内核态按 tgid、进程名前缀与 cgroup 过滤被跟踪的进程，各维度同时满足才保留
+type FilterConfig struct {
+	Tgid   FilterRule `yaml:"tgid" json:"tgid"`
+	Comm   FilterRule `yaml:"comm" json:"comm"`     // 按进程名前缀匹配，最长 15 字节
+	Cgroup FilterRule `yaml:"cgroup" json:"cgroup"` // cgroup id 或相对 cgroup 根目录的路径，路径包含其下全部子 cgroup
+}
+
+// FilterRule 单个维度的过滤规则
+type FilterRule struct {
+	Mode   FilterMode `yaml:"mode" json:"mode"` // 为空时不过滤
+	Values []string   `yaml:"values" json:"values"`
+}
+
+type FilterMode string
+
+const (
+	FilterAllow FilterMode = "allow"
+	FilterDeny  FilterMode = "deny"
+)
+
+// DiagnoseArgs diagnose 子命令的参数
+type DiagnoseArgs struct {
+	Output   string // 支持包路径，为空时写入当前目录
+	LogLines int    // 加载失败时保留的校验器日志行数
+}
+
+// FilterArgs 命令行指定的过滤规则，格式为 <allow|deny>:<value>[,<value>...]，优先于配置文件
+type FilterArgs struct {
+	Tgid   string
+	Comm   string
+	Cgroup string
+}
+
+// IRQConfig 跟踪硬中断与软中断，统计等待期间所在 CPU 的中断处理时间
+type IRQConfig struct {
+	Enable bool `yaml:"enable"`
+}
+
+// RunQueueConfig 在上下文切换与唤醒时采样各 CPU 的运行队列长度
+type RunQueueConfig struct {
+	Enable bool `yaml:"enable"`
+}
+
+// StacksConfig 采集受害者与抢占者的内核/用户调用栈
+type StacksConfig struct {
+	Enable bool `yaml:"enable"` // 每次上下文切换都会采集调用栈，开销较大
+	User   bool `yaml:"user"`   // 符号化用户栈，需要读取进程的 ELF 文件
+}
+
+// AggregationConfig 内核态调度延迟直方图聚合
+type AggregationConfig struct {
+	Mode             AggregationMode `yaml:"mode"`               // 为空时关闭聚合
+	Interval         time.Duration   `yaml:"interval"`           // 读取并清零直方图的周期，默认 10s
+	DisableRawEvents bool            `yaml:"disable_raw_events"` // 关闭原始事件输出，仅保留聚合结果
+	Sink             SinkConfig      `yaml:"sink"`               // 类型为空时仅导出 Prometheus 指标，kafka 默认 topic 为 shepherd-latency-hists
+}
+
+type AggregationMode string
+
+const (
+	AggregationByCgroup AggregationMode = "cgroup"
+	AggregationByTgid   AggregationMode = "tgid"
+)
+
+// SinkConfig 聚合结果、干扰事件、限流事件等附加数据的输出，连接参数沿用 output 配置
+type SinkConfig struct {
+	Type  OutputType `yaml:"type"`
+	Topic string     `yaml:"topic"` // kafka 输出使用的 topic，为空时使用各类数据的默认 topic
 }
 
 type PprofConfig struct {
@@ -17,6 +111,18 @@ type BTFConfig struct {
 	ModelDir string `yaml:"model_dir"`
 }
 
+// PinConfig 将有状态的 map 与挂载链接固定到 bpffs，agent 重启期间程序保持挂载、状态不丢失
+type PinConfig struct {
+	Enable bool   `yaml:"enable"`
+	Path   string `yaml:"path"` // bpffs 下的目录，默认 /sys/fs/bpf/shepherd
+}
+
+// DebugConfig BPF 程序调试输出，开启后读取 trace_pipe 并转发到 agent 日志，运行时可通过 API 开关
+type DebugConfig struct {
+	Enable    bool `yaml:"enable"`
+	RateLimit int  `yaml:"rate_limit"` // 每秒最多转发的行数，默认 50
+}
+
 type OutputConfig struct {
 	Type       OutputType             `yaml:"type"`
 	File       FileOutputConfig       `yaml:"file"`
@@ -56,3 +162,154 @@ type LoggingConfig struct {
 	AlsoToStderr bool   `yaml:"also_to_stderr"`
 	File         string `yaml:"file"`
 }
+
+type KubernetesConfig struct {
+	Enable      bool             `yaml:"enable"`
+	Enricher    EnricherType     `yaml:"enricher"`     // Pod 元数据来源，默认 apiserver
+	CRIEndpoint string           `yaml:"cri_endpoint"` // CRI socket 地址，为空时自动探测 containerd/CRI-O
+	CgroupRoot  string           `yaml:"cgroup_root"`  // cgroup v2 挂载点，默认 /sys/fs/cgroup
+	Scope       ScopeConfig      `yaml:"scope"`
+	Policy      PolicyConfig     `yaml:"policy"`
+	Events      EventsConfig     `yaml:"events"`
+	NodeStatus  NodeStatusConfig `yaml:"node_status"`
+}
+
+// EventsConfig 受害 Pod 在窗口内被同一 Pod 持续抢占时，在受害 Pod 上创建 Kubernetes Event
+type EventsConfig struct {
+	Enable             bool          `yaml:"enable"`
+	Window             time.Duration `yaml:"window"`                // 统计窗口，默认 1m，最短 10s
+	MinPreemptions     int           `yaml:"min_preemptions"`       // 窗口内抢占次数达到该值即告警，0 表示不按次数判断
+	LatencyBudget      time.Duration `yaml:"latency_budget"`        // 窗口内累计调度延迟超过该值即告警，0 表示不按延迟判断
+	Cooldown           time.Duration `yaml:"cooldown"`              // 同一对 Pod 两次告警的最小间隔，默认 10m
+	MaxEventsPerMinute int           `yaml:"max_events_per_minute"` // 节点级 Event 创建速率上限，默认 30
+}
+
+// NodeStatusConfig 节点调度延迟 p99 持续超过阈值时标记节点，恢复后移除标记
+type NodeStatusConfig struct {
+	Enable           bool          `yaml:"enable"`
+	Threshold        time.Duration `yaml:"p99_threshold"`     // 默认 10ms
+	RecoverThreshold time.Duration `yaml:"recover_threshold"` // p99 低于该值才视为恢复，默认阈值的 80%
+	For              time.Duration `yaml:"for"`               // 持续超过阈值多久后标记，默认 5m
+	RecoverFor       time.Duration `yaml:"recover_for"`       // 持续恢复多久后移除标记，默认 5m
+	Interval         time.Duration `yaml:"interval"`          // p99 统计周期，默认 1m
+	MinSamples       int           `yaml:"min_samples"`       // 周期内调度次数低于该值时视为未超过阈值，默认 100
+	Taint            TaintConfig   `yaml:"taint"`
+	Condition        bool          `yaml:"condition"` // 同时设置节点 Condition CPUContention
+}
+
+// TaintConfig 节点被标记时附加的污点
+type TaintConfig struct {
+	Enable bool   `yaml:"enable"`
+	Effect string `yaml:"effect"` // 默认 PreferNoSchedule
+}
+
+// PolicyConfig 通过 ShepherdPolicy CRD 下发集群级监控策略
+type PolicyConfig struct {
+	Enable bool `yaml:"enable"`
+}
+
+// ScopeConfig 按命名空间与 Pod 标签限定监控范围
+type ScopeConfig struct {
+	Enable            bool     `yaml:"enable"`
+	IncludeNamespaces []string `yaml:"include_namespaces"` // 为空表示全部命名空间
+	ExcludeNamespaces []string `yaml:"exclude_namespaces"`
+	LabelSelectors    []string `yaml:"label_selectors"` // 标签选择器，如 "app=web,tier!=batch"，满足任意一个即可
+}
+
+type EnricherType string
+
+const (
+	EnricherTypeAPIServer EnricherType = "apiserver"
+	EnricherTypeCRI       EnricherType = "cri"
+)
+
+// AnalysisConfig 吵闹邻居检测引擎配置
+type AnalysisConfig struct {
+	Enable       bool            `yaml:"enable"`
+	Window       time.Duration   `yaml:"window"`        // 滑动窗口大小，默认 1m
+	EvalInterval time.Duration   `yaml:"eval_interval"` // 评分周期，默认 5s
+	Threshold    float64         `yaml:"threshold"`     // 干扰分数(0-100)超过该值判定为干扰事件，默认 60
+	CountRef     uint64          `yaml:"count_ref"`     // 窗口内抢占次数达到该值时次数分量取满分，默认 100
+	DelayRef     time.Duration   `yaml:"delay_ref"`     // 窗口内累计延迟达到该值时延迟分量取满分，默认 100ms
+	Weights      AnalysisWeights `yaml:"weights"`
+	Sink         SinkConfig      `yaml:"sink"` // 类型默认 file，kafka 默认 topic 为 shepherd-episodes
+}
+
+// AnalysisWeights 干扰分数各分量的权重，全部为 0 时使用默认值 0.3/0.4/0.3
+type AnalysisWeights struct {
+	Count float64 `yaml:"count"`
+	Delay float64 `yaml:"delay"`
+	Share float64 `yaml:"share"`
+}
+
+// MitigationConfig 针对吵闹邻居事件中抢占者 cgroup 的自动缓解配置
+type MitigationConfig struct {
+	Enable            bool               `yaml:"enable"`
+	DryRun            bool               `yaml:"dry_run"`            // 仅记录动作，不写入 cgroupfs
+	Actions           []MitigationAction `yaml:"actions"`            // 启用的缓解动作，默认仅 cpu_weight
+	MinScore          float64            `yaml:"min_score"`          // 事件分数达到该值才执行缓解，默认对全部事件执行
+	CgroupRoot        string             `yaml:"cgroup_root"`        // cgroup v2 挂载点，默认 /sys/fs/cgroup
+	ExcludeNamespaces []string           `yaml:"exclude_namespaces"` // 不对这些命名空间中的抢占者执行缓解
+	MaxActive         int                `yaml:"max_active"`         // 同时生效的缓解数量上限，默认 5
+	MaxDuration       time.Duration      `yaml:"max_duration"`       // 单次缓解的最长持续时间，到期后自动回滚，默认 10m
+	CPUWeight         CPUWeightLimit     `yaml:"cpu_weight"`
+	CPUMax            CPUMaxLimit        `yaml:"cpu_max"`
+	Cpuset            CpusetLimit        `yaml:"cpuset"`
+	AuditLog          string             `yaml:"audit_log"`  // 审计日志文件，为空时写入 agent 日志
+	StateFile         string             `yaml:"state_file"` // 生效中缓解的状态文件，agent 异常退出后下次启动时回滚，默认 /var/lib/shepherd/mitigations.json
+}
+
+type MitigationAction string
+
+const (
+	MitigationCPUWeight MitigationAction = "cpu_weight"
+	MitigationCPUMax    MitigationAction = "cpu_max"
+	MitigationCpuset    MitigationAction = "cpuset"
+)
+
+// CPUWeightLimit 将 cpu.weight 按比例降低，且不低于 Min
+type CPUWeightLimit struct {
+	Ratio float64 `yaml:"ratio"` // 默认 0.25
+	Min   uint64  `yaml:"min"`   // 默认 10
+}
+
+// CPUMaxLimit 临时的 cpu.max 上限
+type CPUMaxLimit struct {
+	Cores  float64 `yaml:"cores"`     // 允许使用的 CPU 核数，默认 1
+	Period uint64  `yaml:"period_us"` // 默认 100000
+}
+
+// CpusetLimit 将 cpuset.cpus 收窄到指定 CPU 列表
+type CpusetLimit struct {
+	Cpus string `yaml:"cpus"` // 如 "0-1"，为空时不执行 cpuset 动作
+}
+
+type ThrottleSource string
+
+const (
+	ThrottleSourceKprobe  ThrottleSource = "kprobe"  // 挂载 throttle_cfs_rq/unthrottle_cfs_rq
+	ThrottleSourceCPUStat ThrottleSource = "cpustat" // 轮询 cgroup 的 cpu.stat
+)
+
+// ThrottleConfig CFS 带宽(cpu.max)限流检测
+type ThrottleConfig struct {
+	Enable       bool           `yaml:"enable"`
+	Source       ThrottleSource `yaml:"source"`        // 为空时优先使用 kprobe，函数不可挂载时回退到 cpustat
+	Interval     time.Duration  `yaml:"interval"`      // 读取限流统计的周期，默认 1s
+	RecoverAfter time.Duration  `yaml:"recover_after"` // 持续未限流超过该时间后结束限流事件，默认 5s
+	CgroupRoot   string         `yaml:"cgroup_root"`   // cgroup v2 挂载点，默认 /sys/fs/cgroup
+	Sink         SinkConfig     `yaml:"sink"`          // 类型默认 file，kafka 默认 topic 为 shepherd-throttle-episodes
+}
+
+// ProbesConfig 将内置的可选探针挂载到指定内核函数，统计调用次数与耗时
+type ProbesConfig struct {
+	Items        []ProbeConfig `yaml:"items"`
+	DisableMulti bool          `yaml:"disable_multi"` // 不使用 kprobe.multi，始终逐个挂载 kprobe
+	Batch        uint          `yaml:"batch"`         // 逐个挂载 kprobe 时每批的数量，默认 16
+}
+
+// ProbeConfig 单个可选探针及其挂载的内核函数
+type ProbeConfig struct {
+	Name  string   `yaml:"name"` // func_count/func_latency/fentry_func_count/fentry_func_latency
+	Funcs []string `yaml:"funcs"`
+}
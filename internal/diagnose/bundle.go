@@ -0,0 +1,108 @@
+package diagnose
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+	"gopkg.in/yaml.v2"
+)
+
+// kernelFiles 写入支持包的内核信息，相对 /proc，容器内通过 PROC_PATH 访问宿主机
+var kernelFiles = []string{"version", "cmdline", "1/root/etc/os-release"}
+
+type bundleFile struct {
+	name string
+	data []byte
+}
+
+// WriteBundle 将诊断报告、内核信息与脱敏后的配置写入 tar.gz 支持包
+func WriteBundle(path string, report *Report, cfg config.Configuration) error {
+	var text bytes.Buffer
+	report.WriteText(&text)
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal report")
+	}
+	files := []bundleFile{
+		{"report.txt", text.Bytes()},
+		{"report.json", data},
+		{"kernel.txt", kernelInfo()},
+	}
+
+	// 配置中可能包含输出端的密码
+	if cfg.Output.Clickhouse.Password != "" {
+		cfg.Output.Clickhouse.Password = "<redacted>"
+	}
+	if data, err = yaml.Marshal(cfg); err != nil {
+		return errors.Wrap(err, "failed to marshal config")
+	}
+	files = append(files, bundleFile{"config.yaml", data})
+
+	return errors.Wrapf(writeTarGz(path, files), "failed to write support bundle %s", path)
+}
+
+func kernelInfo() []byte {
+	var b bytes.Buffer
+
+	var uname unix.Utsname
+	if err := unix.Uname(&uname); err == nil {
+		fmt.Fprintf(&b, "uname: %s %s %s %s\n", unix.ByteSliceToString(uname.Sysname[:]),
+			unix.ByteSliceToString(uname.Release[:]), unix.ByteSliceToString(uname.Version[:]),
+			unix.ByteSliceToString(uname.Machine[:]))
+	}
+
+	for _, name := range kernelFiles {
+		data, err := os.ReadFile(config.GetProcPath(name))
+		if err != nil {
+			fmt.Fprintf(&b, "\n== %s ==\n%v\n", name, err)
+			continue
+		}
+		fmt.Fprintf(&b, "\n== %s ==\n%s", name, data)
+	}
+
+	return b.Bytes()
+}
+
+func writeTarGz(path string, files []bundleFile) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	now := time.Now()
+	for _, file := range files {
+		hdr := &tar.Header{
+			Name:    file.name,
+			Mode:    0o644,
+			Size:    int64(len(file.data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
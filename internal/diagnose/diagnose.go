@@ -0,0 +1,206 @@
+package diagnose
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	ebpfbinary "github.com/cen-ngc5139/shepherd/internal/binary"
+	"github.com/cen-ngc5139/shepherd/internal/bpf"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/rlimit"
+	"github.com/pkg/errors"
+)
+
+// defaultTracingTarget 运行时才确定目标函数的 fentry/fexit 程序诊断时挂载的函数
+const defaultTracingTarget = "vprintk"
+
+// Report 逐个加载程序的诊断结果
+type Report struct {
+	Time      time.Time       `json:"time"`
+	BTFSource string          `json:"btf_source"`
+	BTFError  string          `json:"btf_error,omitempty"`
+	Features  *bpf.Features   `json:"features"`
+	MapsError string          `json:"maps_error,omitempty"` // map 创建失败时不再加载程序
+	Programs  []ProgramResult `json:"programs"`
+}
+
+// ProgramResult 单个程序的加载结果
+type ProgramResult struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	AttachTo string `json:"attach_to,omitempty"`
+	Selected bool   `json:"selected"` // 是否为按内核特性选用的变体，未选用的变体加载失败属于预期
+	Insns    int    `json:"insns"`
+	Loaded   bool   `json:"loaded"`
+	Error    string `json:"error,omitempty"`
+
+	Stats   *bpf.VerifierStats `json:"stats,omitempty"`
+	LogTail []string           `json:"log_tail,omitempty"`
+}
+
+// Options 诊断参数
+type Options struct {
+	LogLines int // 加载失败时保留的校验器日志行数
+}
+
+// Run 按 agent 的方式探测内核特性并创建 map，再逐个加载全部程序，包括未选用的变体与可选探针
+func Run(cfg config.Configuration, opts Options) (*Report, error) {
+	if err := rlimit.RemoveMemlock(); err != nil {
+		return nil, errors.Wrap(err, "failed to remove memlock limit")
+	}
+
+	report := &Report{Time: time.Now()}
+
+	var collOpts ebpf.CollectionOptions
+	btfSpec, btfSource, err := bpf.LoadKernelBTF(cfg.BTF)
+	if err != nil {
+		report.BTFError = err.Error()
+	}
+	report.BTFSource = btfSource
+	collOpts.Programs.KernelTypes = btfSpec
+	report.Features = bpf.ProbeFeatures(btfSource)
+
+	spec, err := ebpfbinary.LoadShepherd()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load bpf spec")
+	}
+
+	// 按内核特性选择变体以确定 map 与常量，但仍然逐个加载全部程序
+	programs := make(map[string]*ebpf.ProgramSpec, len(spec.Programs))
+	for name, ps := range spec.Programs {
+		programs[name] = ps
+	}
+	optional := bpf.TakeOptionalProbes(spec)
+	if err := bpf.SelectProgramVariants(spec, report.Features); err != nil {
+		return nil, err
+	}
+	selected := spec.Programs
+
+	spec.Programs = nil
+	coll, err := ebpf.NewCollectionWithOptions(spec, collOpts)
+	if err != nil {
+		report.MapsError = bpf.FormatLoadError(err, opts.LogLines)
+		return report, nil
+	}
+	defer coll.Close()
+
+	for name, ps := range programs {
+		_, isSelected := selected[name]
+		_, isOptional := optional[name]
+		report.Programs = append(report.Programs,
+			loadProgram(ps.Copy(), coll, collOpts.Programs, isSelected || isOptional, opts.LogLines))
+	}
+	sort.Slice(report.Programs, func(i, j int) bool {
+		return report.Programs[i].Name < report.Programs[j].Name
+	})
+
+	return report, nil
+}
+
+// loadProgram 先以 LogLevelStats 加载获取复杂度，失败时不指定日志级别重新加载，由 ebpf 库获取分支级别的日志
+func loadProgram(ps *ebpf.ProgramSpec, coll *ebpf.Collection, opts ebpf.ProgramOptions, selected bool, logLines int) ProgramResult {
+	if ps.Type == ebpf.Tracing && ps.AttachTo == "" {
+		ps.AttachTo = defaultTracingTarget
+	}
+
+	result := ProgramResult{
+		Name:     ps.Name,
+		Type:     ps.Type.String(),
+		AttachTo: ps.AttachTo,
+		Selected: selected,
+		Insns:    len(ps.Instructions),
+	}
+
+	opts.LogLevel = ebpf.LogLevelStats
+	prog, err := bpf.LoadProgram(ps.Copy(), coll, opts)
+	if err == nil {
+		defer prog.Close()
+		result.Loaded = true
+		if stats, ok := bpf.ParseVerifierStats(prog.VerifierLog); ok {
+			result.Stats = &stats
+		}
+		return result
+	}
+
+	opts.LogLevel = 0
+	if retry, retryErr := bpf.LoadProgram(ps, coll, opts); retryErr != nil {
+		err = retryErr
+	} else {
+		retry.Close()
+	}
+	result.Error = err.Error()
+	result.LogTail = bpf.VerifierLogTail(err, logLines)
+	var ve *ebpf.VerifierError
+	if errors.As(err, &ve) {
+		if stats, ok := bpf.ParseVerifierStats(strings.Join(ve.Log, "\n")); ok {
+			result.Stats = &stats
+		}
+	}
+
+	return result
+}
+
+// Failed 返回选用的程序中加载失败的数量
+func (r *Report) Failed() int {
+	var failed int
+	if r.MapsError != "" {
+		failed++
+	}
+	for _, p := range r.Programs {
+		if p.Selected && !p.Loaded {
+			failed++
+		}
+	}
+
+	return failed
+}
+
+// WriteText 输出可读的诊断报告
+func (r *Report) WriteText(w io.Writer) {
+	f := r.Features
+	fmt.Fprintf(w, "kernel: %s\n", f.KernelRelease)
+	if r.BTFError != "" {
+		fmt.Fprintf(w, "btf: %s\n", r.BTFError)
+	} else {
+		fmt.Fprintf(w, "btf: %s (kernel btf %t)\n", r.BTFSource, f.KernelBTF)
+	}
+	fmt.Fprintf(w, "features: tp_btf %t, fentry %t, ringbuf %t, kprobe.multi %t, tracefs %q\n",
+		f.TpBTF, f.Fentry, f.Ringbuf, f.KprobeMulti, f.Tracefs)
+	fmt.Fprintf(w, "variant: %s, transport: %s\n\n", f.Variant, f.Transport)
+
+	if r.MapsError != "" {
+		fmt.Fprintf(w, "failed to create maps: %s\n", r.MapsError)
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROGRAM\tTYPE\tSELECTED\tINSNS\tPROCESSED\tSTATES(PEAK/TOTAL)\tRESULT")
+	for _, p := range r.Programs {
+		processed, states := "-", "-"
+		if p.Stats != nil {
+			processed = fmt.Sprint(p.Stats.ProcessedInsns)
+			states = fmt.Sprintf("%d/%d", p.Stats.PeakStates, p.Stats.TotalStates)
+		}
+		result := "ok"
+		if !p.Loaded {
+			result = "FAILED"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%t\t%d\t%s\t%s\t%s\n", p.Name, p.Type, p.Selected, p.Insns, processed, states, result)
+	}
+	tw.Flush()
+
+	for _, p := range r.Programs {
+		if p.Loaded {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s: %s\n", p.Name, p.Error)
+		for _, line := range p.LogTail {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package enricher
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultCgroupRoot = "/sys/fs/cgroup"
+
+	// minRescanInterval 两次全量扫描 cgroup 文件系统的最小间隔
+	minRescanInterval = time.Second
+
+	// negativeTTL 未找到的 cgroup id 在此期间不再触发扫描，短生命周期与非 Pod 的 cgroup 会反复未命中
+	negativeTTL = 30 * time.Second
+	// maxNegativeEntries 负缓存上限，超过后整体清空
+	maxNegativeEntries = 65536
+)
+
+// CgroupResolver 将 cgroup id 映射为 cgroup 路径
+// cgroup v2 下 kernfs 节点 id 即 cgroup 目录的 inode 号
+type CgroupResolver struct {
+	root string
+
+	mu     sync.RWMutex
+	paths  map[uint64]string    // cgroup id -> 相对 root 的路径
+	misses map[uint64]time.Time // cgroup id -> 未命中的时间
+
+	scanMu   sync.Mutex // 串行化扫描，遍历文件系统时不持有 mu
+	lastScan time.Time
+	scanning atomic.Bool
+}
+
+func NewCgroupResolver(root string) *CgroupResolver {
+	if root == "" {
+		root = DefaultCgroupRoot
+	}
+
+	return &CgroupResolver{
+		root:   filepath.Clean(root),
+		paths:  make(map[uint64]string),
+		misses: make(map[uint64]time.Time),
+	}
+}
+
+// Path 返回 cgroup id 对应的路径，用于事件处理路径：未命中时在后台重新扫描，本次直接返回未找到，
+// 负缓存期内的 id 不再触发扫描
+func (r *CgroupResolver) Path(id uint64) (string, bool) {
+	if id == 0 {
+		return "", false
+	}
+
+	r.mu.RLock()
+	path, ok := r.paths[id]
+	missed, negative := r.misses[id]
+	r.mu.RUnlock()
+	if ok {
+		return path, true
+	}
+	if negative && time.Since(missed) < negativeTTL {
+		return "", false
+	}
+
+	r.recordMiss(id)
+	if r.scanning.CompareAndSwap(false, true) {
+		go func() {
+			defer r.scanning.Store(false)
+			_ = r.rescan()
+		}()
+	}
+
+	return "", false
+}
+
+// Resolve 返回 cgroup id 对应的路径，未命中时同步重新扫描，用于周期性任务等不在事件处理路径上的调用方
+func (r *CgroupResolver) Resolve(id uint64) (string, bool) {
+	if id == 0 {
+		return "", false
+	}
+
+	r.mu.RLock()
+	path, ok := r.paths[id]
+	r.mu.RUnlock()
+	if ok {
+		return path, true
+	}
+
+	if err := r.rescan(); err != nil {
+		return "", false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	path, ok = r.paths[id]
+	return path, ok
+}
+
+func (r *CgroupResolver) recordMiss(id uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.misses) >= maxNegativeEntries {
+		r.misses = make(map[uint64]time.Time)
+	}
+	r.misses[id] = time.Now()
+}
+
+// Match 重新扫描 cgroup 文件系统，返回路径满足 fn 的全部 cgroup id
+func (r *CgroupResolver) Match(fn func(path string) bool) []uint64 {
+	if err := r.rescan(); err != nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var ids []uint64
+	for id, path := range r.paths {
+		if fn(path) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// Invalidate 删除路径中包含指定容器 ID 的缓存，避免 inode 复用后命中过期路径
+func (r *CgroupResolver) Invalidate(containerID string) {
+	if containerID == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, path := range r.paths {
+		if strings.Contains(path, containerID) {
+			delete(r.paths, id)
+		}
+	}
+}
+
+// rescan 全量扫描 cgroup 文件系统，遍历期间不阻塞查询
+func (r *CgroupResolver) rescan() error {
+	r.scanMu.Lock()
+	defer r.scanMu.Unlock()
+
+	if time.Since(r.lastScan) < minRescanInterval {
+		return nil
+	}
+	r.lastScan = time.Now()
+
+	paths := make(map[uint64]string)
+	err := filepath.WalkDir(r.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// cgroup 可能在遍历过程中被删除
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return nil
+		}
+
+		rel := strings.TrimPrefix(path, r.root)
+		if rel == "" {
+			rel = "/"
+		}
+		paths[stat.Ino] = rel
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to walk cgroup root %s", r.root)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paths = paths
+	// 扫描到的 id 立即可用，过期的负缓存一并清理
+	for id, missed := range r.misses {
+		if _, ok := paths[id]; ok || time.Since(missed) >= negativeTTL {
+			delete(r.misses, id)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package enricher
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	// 匹配 cgroup 路径末尾的容器 ID，兼容以下格式：
+	// systemd 驱动: .../cri-containerd-<id>.scope、.../crio-<id>.scope、.../docker-<id>.scope
+	// cgroupfs 驱动: /kubepods/burstable/pod<uid>/<id>
+	containerIDRegexp = regexp.MustCompile(`([0-9a-f]{64})(?:\.scope)?$`)
+
+	// 匹配 cgroup 路径中的 Pod UID，systemd 驱动下 UID 中的 '-' 被替换为 '_'
+	podUIDRegexp = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+)
+
+// ContainerIDFromPath 从 cgroup 路径中解析容器 ID，非容器 cgroup 返回空字符串
+func ContainerIDFromPath(cgroupPath string) string {
+	match := containerIDRegexp.FindStringSubmatch(path.Base(cgroupPath))
+	if match == nil {
+		return ""
+	}
+
+	return match[1]
+}
+
+// PodUIDFromPath 从 cgroup 路径中解析 Pod UID，非 Pod cgroup 返回空字符串
+func PodUIDFromPath(cgroupPath string) string {
+	match := podUIDRegexp.FindStringSubmatch(cgroupPath)
+	if match == nil {
+		return ""
+	}
+
+	return strings.ReplaceAll(match[1], "_", "-")
+}
+
+// TrimContainerIDScheme 去掉容器运行时前缀，例如 containerd://<id> -> <id>
+func TrimContainerIDScheme(containerID string) string {
+	if i := strings.Index(containerID, "://"); i >= 0 {
+		return containerID[i+3:]
+	}
+
+	return containerID
+}
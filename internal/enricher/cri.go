@@ -0,0 +1,284 @@
+package enricher
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cen-ngc5139/shepherd/internal/metadata"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+const (
+	// kubelet 写入容器与 sandbox 的标准标签
+	criPodNameLabel       = "io.kubernetes.pod.name"
+	criPodNamespaceLabel  = "io.kubernetes.pod.namespace"
+	criPodUIDLabel        = "io.kubernetes.pod.uid"
+	criContainerNameLabel = "io.kubernetes.container.name"
+
+	criRequestTimeout = 2 * time.Second
+	criSyncPeriod     = 30 * time.Second
+	// criMinResyncInterval 遇到未知容器时提前同步的最小间隔
+	criMinResyncInterval = 2 * time.Second
+	// criNegativeTTL 未知容器的 cgroup 在此期间直接返回未命中
+	criNegativeTTL = 30 * time.Second
+)
+
+// DefaultCRIEndpoints 未配置 CRI 地址时依次探测的本地 socket
+var DefaultCRIEndpoints = []string{
+	"unix:///run/containerd/containerd.sock",
+	"unix:///run/crio/crio.sock",
+}
+
+// CRIEnricher 通过本地容器运行时的 CRI 接口将 cgroup id 解析为 Pod，不依赖 API Server
+type CRIEnricher struct {
+	endpoint string
+	cgroups  *CgroupResolver
+	conn     *grpc.ClientConn
+	runtime  runtimeapi.RuntimeServiceClient
+
+	mu         sync.RWMutex
+	pods       map[uint64]*metadata.PodInfo // cgroup id -> PodInfo
+	misses     map[uint64]time.Time         // 未解析到 Pod 的 cgroup id -> 未命中时间
+	containers map[string]*metadata.PodInfo // 容器 ID 与 sandbox ID -> PodInfo，由周期同步维护
+	sandboxes  []*metadata.PodInfo          // 最近一次同步到的 Pod 列表
+	resync     chan struct{}
+	notifier
+}
+
+func NewCRIEnricher(cfg config.KubernetesConfig) (*CRIEnricher, error) {
+	endpoints := DefaultCRIEndpoints
+	if cfg.CRIEndpoint != "" {
+		endpoints = []string{cfg.CRIEndpoint}
+	}
+
+	var lastErr error
+	for _, endpoint := range endpoints {
+		e, err := newCRIEnricher(endpoint, cfg.CgroupRoot)
+		if err == nil {
+			return e, nil
+		}
+		lastErr = err
+	}
+
+	return nil, errors.Wrapf(lastErr, "no available cri endpoint in %v", endpoints)
+}
+
+func newCRIEnricher(endpoint, cgroupRoot string) (*CRIEnricher, error) {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "unix://" + endpoint
+	}
+
+	conn, err := grpc.NewClient(endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to dial cri endpoint %s", endpoint)
+	}
+
+	e := &CRIEnricher{
+		endpoint:   endpoint,
+		cgroups:    NewCgroupResolver(cgroupRoot),
+		conn:       conn,
+		runtime:    runtimeapi.NewRuntimeServiceClient(conn),
+		pods:       make(map[uint64]*metadata.PodInfo),
+		misses:     make(map[uint64]time.Time),
+		containers: make(map[string]*metadata.PodInfo),
+		resync:     make(chan struct{}, 1),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), criRequestTimeout)
+	defer cancel()
+	version, err := e.runtime.Version(ctx, &runtimeapi.VersionRequest{})
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrapf(err, "failed to query cri version from %s", endpoint)
+	}
+
+	log.Infof("connected to cri runtime %s %s at %s", version.RuntimeName, version.RuntimeVersion, endpoint)
+	return e, nil
+}
+
+// Start 周期性同步 sandbox 与容器列表，遇到未知容器时提前同步
+func (e *CRIEnricher) Start(ctx context.Context) error {
+	defer e.conn.Close()
+
+	ticker := time.NewTicker(criSyncPeriod)
+	defer ticker.Stop()
+
+	var lastSync time.Time
+	for {
+		if time.Since(lastSync) >= criMinResyncInterval {
+			if err := e.sync(ctx); err != nil {
+				log.Warningf("failed to sync cri pod sandboxes: %v", err)
+			}
+			lastSync = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-e.resync:
+		}
+	}
+}
+
+// Lookup 只查询内存中的索引，不在事件处理路径上调用 CRI，未知容器进入负缓存并触发后台同步
+func (e *CRIEnricher) Lookup(cgroupID uint64) (*metadata.PodInfo, bool) {
+	e.mu.RLock()
+	info, ok := e.pods[cgroupID]
+	missed, negative := e.misses[cgroupID]
+	e.mu.RUnlock()
+	if ok {
+		return info, true
+	}
+	if negative && time.Since(missed) < criNegativeTTL {
+		return nil, false
+	}
+
+	cgroupPath, ok := e.cgroups.Path(cgroupID)
+	if !ok {
+		return nil, false
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	containerID := ContainerIDFromPath(cgroupPath)
+	if containerID == "" {
+		// 非容器 cgroup 不会出现在索引中，无需同步
+		e.misses[cgroupID] = time.Now()
+		return nil, false
+	}
+
+	info, ok = e.containers[containerID]
+	if !ok {
+		e.misses[cgroupID] = time.Now()
+		select {
+		case e.resync <- struct{}{}:
+		default:
+		}
+		return nil, false
+	}
+
+	e.pods[cgroupID] = info
+	return info, true
+}
+
+func (e *CRIEnricher) Pods() []*metadata.PodInfo {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]*metadata.PodInfo{}, e.sandboxes...)
+}
+
+// sync 刷新 Pod 列表与容器索引，删除已不存在的 Pod 的缓存
+func (e *CRIEnricher) sync(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, criRequestTimeout)
+	defer cancel()
+
+	sandboxes, err := e.runtime.ListPodSandbox(ctx, &runtimeapi.ListPodSandboxRequest{})
+	if err != nil {
+		return errors.Wrap(err, "failed to list pod sandboxes")
+	}
+	containers, err := e.runtime.ListContainers(ctx, &runtimeapi.ListContainersRequest{})
+	if err != nil {
+		return errors.Wrap(err, "failed to list containers")
+	}
+
+	alive := make(map[string]struct{}, len(sandboxes.Items))
+	pods := make([]*metadata.PodInfo, 0, len(sandboxes.Items))
+	bySandbox := make(map[string]*runtimeapi.PodSandbox, len(sandboxes.Items))
+	// sandbox ID 即 pause 容器 ID，同样可以出现在 cgroup 路径中
+	index := make(map[string]*metadata.PodInfo, len(sandboxes.Items)+len(containers.Containers))
+	for _, sandbox := range sandboxes.Items {
+		bySandbox[sandbox.Id] = sandbox
+		info := newPodInfoFromSandbox(sandbox)
+		info.ContainerID = sandbox.Id
+		index[sandbox.Id] = info
+
+		if sandbox.State != runtimeapi.PodSandboxState_SANDBOX_READY {
+			continue
+		}
+		alive[info.UID] = struct{}{}
+		pods = append(pods, newPodInfoFromSandbox(sandbox))
+	}
+	for _, container := range containers.Containers {
+		sandbox, ok := bySandbox[container.PodSandboxId]
+		if !ok {
+			continue
+		}
+		info := newPodInfoFromSandbox(sandbox)
+		info.ContainerID = container.Id
+		info.ContainerName = container.Labels[criContainerNameLabel]
+		if info.ContainerName == "" && container.Metadata != nil {
+			info.ContainerName = container.Metadata.Name
+		}
+		index[container.Id] = info
+	}
+
+	var stale []string
+	e.mu.Lock()
+	changed := len(pods) != len(e.sandboxes)
+	for _, info := range e.sandboxes {
+		if _, ok := alive[info.UID]; !ok {
+			changed = true
+		}
+	}
+	e.sandboxes = pods
+	e.containers = index
+	// 同步后此前未知的容器可能已在索引中
+	e.misses = make(map[uint64]time.Time)
+	for id, info := range e.pods {
+		if _, ok := alive[info.UID]; !ok {
+			stale = append(stale, info.ContainerID)
+			delete(e.pods, id)
+		}
+	}
+	e.mu.Unlock()
+
+	for _, containerID := range stale {
+		e.cgroups.Invalidate(containerID)
+	}
+
+	if changed {
+		e.notify()
+	}
+
+	return nil
+}
+
+func newPodInfoFromSandbox(sandbox *runtimeapi.PodSandbox) *metadata.PodInfo {
+	info := &metadata.PodInfo{
+		Name:      sandbox.Labels[criPodNameLabel],
+		Namespace: sandbox.Labels[criPodNamespaceLabel],
+		UID:       sandbox.Labels[criPodUIDLabel],
+		Labels:    make(map[string]string, len(sandbox.Labels)),
+	}
+
+	if sandbox.Metadata != nil {
+		if info.Name == "" {
+			info.Name = sandbox.Metadata.Name
+		}
+		if info.Namespace == "" {
+			info.Namespace = sandbox.Metadata.Namespace
+		}
+		if info.UID == "" {
+			info.UID = sandbox.Metadata.Uid
+		}
+	}
+
+	// sandbox 标签中除 kubelet 内部标签外即为 Pod 标签
+	for k, v := range sandbox.Labels {
+		if strings.HasPrefix(k, "io.kubernetes.") {
+			continue
+		}
+		info.Labels[k] = v
+	}
+
+	return info
+}
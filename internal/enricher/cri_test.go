@@ -0,0 +1,254 @@
+package enricher
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"google.golang.org/grpc"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+const (
+	testPodUID      = "0b8f3c7e-5d2a-4c1b-9e6f-1a2b3c4d5e6f"
+	testSandboxID   = "1111111111111111111111111111111111111111111111111111111111111111"
+	testContainerID = "2222222222222222222222222222222222222222222222222222222222222222"
+	testUnknownID   = "3333333333333333333333333333333333333333333333333333333333333333"
+)
+
+// fakeRuntime 只实现 enricher 用到的 CRI 接口，并记录 List 调用次数
+type fakeRuntime struct {
+	runtimeapi.UnimplementedRuntimeServiceServer
+
+	mu         sync.Mutex
+	sandboxes  []*runtimeapi.PodSandbox
+	containers []*runtimeapi.Container
+	calls      int
+}
+
+func (f *fakeRuntime) Version(context.Context, *runtimeapi.VersionRequest) (*runtimeapi.VersionResponse, error) {
+	return &runtimeapi.VersionResponse{RuntimeName: "fake", RuntimeVersion: "v1"}, nil
+}
+
+func (f *fakeRuntime) ListPodSandbox(context.Context, *runtimeapi.ListPodSandboxRequest) (*runtimeapi.ListPodSandboxResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	return &runtimeapi.ListPodSandboxResponse{Items: f.sandboxes}, nil
+}
+
+func (f *fakeRuntime) ListContainers(context.Context, *runtimeapi.ListContainersRequest) (*runtimeapi.ListContainersResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	return &runtimeapi.ListContainersResponse{Containers: f.containers}, nil
+}
+
+func (f *fakeRuntime) setPods(sandboxes []*runtimeapi.PodSandbox, containers []*runtimeapi.Container) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sandboxes = sandboxes
+	f.containers = containers
+}
+
+func (f *fakeRuntime) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls
+}
+
+func startFakeRuntime(t *testing.T) (*fakeRuntime, string) {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "cri.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", socket, err)
+	}
+
+	fake := &fakeRuntime{}
+	server := grpc.NewServer()
+	runtimeapi.RegisterRuntimeServiceServer(server, fake)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	return fake, "unix://" + socket
+}
+
+// mkCgroup 在临时 cgroup 根目录下创建目录并返回其 inode，即 cgroup id
+func mkCgroup(t *testing.T, root, rel string) uint64 {
+	t.Helper()
+
+	path := filepath.Join(root, rel)
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		t.Fatalf("failed to create %s: %v", path, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat %s: %v", path, err)
+	}
+
+	return info.Sys().(*syscall.Stat_t).Ino
+}
+
+func testSandbox() *runtimeapi.PodSandbox {
+	return &runtimeapi.PodSandbox{
+		Id:    testSandboxID,
+		State: runtimeapi.PodSandboxState_SANDBOX_READY,
+		Metadata: &runtimeapi.PodSandboxMetadata{
+			Name:      "web-0",
+			Namespace: "default",
+			Uid:       testPodUID,
+		},
+		Labels: map[string]string{
+			criPodNameLabel:      "web-0",
+			criPodNamespaceLabel: "default",
+			criPodUIDLabel:       testPodUID,
+			"app":                "web",
+		},
+	}
+}
+
+func testContainer() *runtimeapi.Container {
+	return &runtimeapi.Container{
+		Id:           testContainerID,
+		PodSandboxId: testSandboxID,
+		Metadata:     &runtimeapi.ContainerMetadata{Name: "nginx"},
+		Labels:       map[string]string{criContainerNameLabel: "nginx"},
+	}
+}
+
+func TestCRIEnricherLookup(t *testing.T) {
+	fake, endpoint := startFakeRuntime(t)
+	fake.setPods([]*runtimeapi.PodSandbox{testSandbox()}, []*runtimeapi.Container{testContainer()})
+
+	root := t.TempDir()
+	podDir := filepath.Join("kubepods", "burstable", "pod"+testPodUID)
+	containerCgroup := mkCgroup(t, root, filepath.Join(podDir, testContainerID))
+	sandboxCgroup := mkCgroup(t, root, filepath.Join(podDir, testSandboxID))
+	unknownCgroup := mkCgroup(t, root, filepath.Join(podDir, testUnknownID))
+	systemCgroup := mkCgroup(t, root, filepath.Join("system.slice", "containerd.service"))
+
+	e, err := newCRIEnricher(strings.TrimPrefix(endpoint, "unix://"), root)
+	if err != nil {
+		t.Fatalf("failed to create enricher: %v", err)
+	}
+	defer e.conn.Close()
+
+	// 事件路径上的 Path 只触发后台扫描，测试中先同步扫描
+	if _, ok := e.cgroups.Resolve(containerCgroup); !ok {
+		t.Fatalf("cgroup %d not found under %s", containerCgroup, root)
+	}
+
+	ctx := context.Background()
+	if err := e.sync(ctx); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	synced := fake.callCount()
+
+	info, ok := e.Lookup(containerCgroup)
+	if !ok {
+		t.Fatalf("container cgroup not resolved")
+	}
+	if info.Name != "web-0" || info.Namespace != "default" || info.UID != testPodUID {
+		t.Errorf("unexpected pod %s/%s uid %s", info.Namespace, info.Name, info.UID)
+	}
+	if info.ContainerID != testContainerID || info.ContainerName != "nginx" {
+		t.Errorf("unexpected container %s name %s", info.ContainerID, info.ContainerName)
+	}
+	if info.Labels["app"] != "web" {
+		t.Errorf("unexpected labels %v", info.Labels)
+	}
+
+	info, ok = e.Lookup(sandboxCgroup)
+	if !ok {
+		t.Fatalf("sandbox cgroup not resolved")
+	}
+	if info.ContainerID != testSandboxID || info.Name != "web-0" {
+		t.Errorf("unexpected sandbox %s of pod %s", info.ContainerID, info.Name)
+	}
+
+	// 未知容器与非容器 cgroup 不在事件路径上调用 CRI
+	if _, ok := e.Lookup(unknownCgroup); ok {
+		t.Errorf("unknown container resolved")
+	}
+	if _, ok := e.Lookup(unknownCgroup); ok {
+		t.Errorf("unknown container resolved from negative cache")
+	}
+	if _, ok := e.Lookup(systemCgroup); ok {
+		t.Errorf("system cgroup resolved")
+	}
+	if calls := fake.callCount(); calls != synced {
+		t.Errorf("lookup issued %d cri calls", calls-synced)
+	}
+	select {
+	case <-e.resync:
+	default:
+		t.Errorf("unknown container did not request a resync")
+	}
+
+	if pods := e.Pods(); len(pods) != 1 || pods[0].UID != testPodUID {
+		t.Errorf("unexpected pods %v", pods)
+	}
+}
+
+func TestCRIEnricherSyncRemovesStalePods(t *testing.T) {
+	fake, endpoint := startFakeRuntime(t)
+	fake.setPods([]*runtimeapi.PodSandbox{testSandbox()}, []*runtimeapi.Container{testContainer()})
+
+	root := t.TempDir()
+	containerCgroup := mkCgroup(t, root, filepath.Join("kubepods", "pod"+testPodUID, testContainerID))
+
+	e, err := newCRIEnricher(endpoint, root)
+	if err != nil {
+		t.Fatalf("failed to create enricher: %v", err)
+	}
+	defer e.conn.Close()
+	changes := e.Subscribe()
+
+	e.cgroups.Resolve(containerCgroup)
+	ctx := context.Background()
+	if err := e.sync(ctx); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	if _, ok := e.Lookup(containerCgroup); !ok {
+		t.Fatalf("container cgroup not resolved")
+	}
+	drain(changes)
+
+	fake.setPods(nil, nil)
+	if err := e.sync(ctx); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+
+	if _, ok := e.Lookup(containerCgroup); ok {
+		t.Errorf("deleted pod still resolved")
+	}
+	if pods := e.Pods(); len(pods) != 0 {
+		t.Errorf("unexpected pods %v", pods)
+	}
+	select {
+	case <-changes:
+	default:
+		t.Errorf("pod removal not notified")
+	}
+}
+
+func drain(ch <-chan struct{}) {
+	for {
+		select {
+		case <-ch:
+		default:
+			return
+		}
+	}
+}
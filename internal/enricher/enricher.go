@@ -0,0 +1,93 @@
+package enricher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cen-ngc5139/shepherd/internal/binary"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/metadata"
+)
+
+// Enricher 将 cgroup id 解析为 Pod 元数据
+type Enricher interface {
+	// Start 同步元数据，阻塞直至 ctx 结束
+	Start(ctx context.Context) error
+	// Lookup 查询 cgroup id 所属 Pod，非容器进程返回 false
+	Lookup(cgroupID uint64) (*metadata.PodInfo, bool)
+	// Pods 返回当前节点上的全部 Pod
+	Pods() []*metadata.PodInfo
+	// Subscribe 订阅节点上 Pod 的增删改通知
+	Subscribe() <-chan struct{}
+}
+
+// New 根据配置创建 Enricher，未开启时返回空实现
+func New(cfg config.KubernetesConfig, nodeName string) (Enricher, error) {
+	if !cfg.Enable {
+		return noop{}, nil
+	}
+
+	switch cfg.Enricher {
+	case config.EnricherTypeCRI:
+		return NewCRIEnricher(cfg)
+	case config.EnricherTypeAPIServer, "":
+		return NewKubernetesEnricher(cfg, nodeName)
+	default:
+		return nil, fmt.Errorf("unknown enricher type %q", cfg.Enricher)
+	}
+}
+
+// Enrich 为调度事件附加被延迟进程与抢占进程的 Pod 信息
+func Enrich(e Enricher, event binary.ShepherdSchedLatencyT) metadata.SchedEvent {
+	se := metadata.NewSchedEvent(event)
+
+	if pod, ok := e.Lookup(event.CgroupId); ok {
+		se.Pod = pod
+	}
+
+	if event.IsPreempt == 1 {
+		if pod, ok := e.Lookup(event.PreemptedCgroupId); ok {
+			se.PreemptedPod = pod
+		}
+	}
+
+	return se
+}
+
+type noop struct{}
+
+func (noop) Start(ctx context.Context) error { return nil }
+
+func (noop) Lookup(uint64) (*metadata.PodInfo, bool) { return nil, false }
+
+func (noop) Pods() []*metadata.PodInfo { return nil }
+
+func (noop) Subscribe() <-chan struct{} { return nil }
+
+// notifier 向全部订阅者广播变更，并合并短时间内的多次通知
+type notifier struct {
+	mu   sync.Mutex
+	subs []chan struct{}
+}
+
+func (n *notifier) Subscribe() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	ch := make(chan struct{}, 1)
+	n.subs = append(n.subs, ch)
+	return ch
+}
+
+func (n *notifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, ch := range n.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
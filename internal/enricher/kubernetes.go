@@ -0,0 +1,239 @@
+package enricher
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cen-ngc5139/shepherd/internal/metadata"
+	"github.com/cen-ngc5139/shepherd/pkg/client"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+const (
+	containerIDIndex = "containerID"
+	podUIDIndex      = "podUID"
+
+	informerResyncPeriod = 10 * time.Minute
+)
+
+// KubernetesEnricher 通过节点范围的 Pod Informer 将 cgroup id 解析为 Pod
+type KubernetesEnricher struct {
+	nodeName string
+	cgroups  *CgroupResolver
+	factory  informers.SharedInformerFactory
+	informer toolscache.SharedIndexInformer
+
+	mu   sync.RWMutex
+	pods map[uint64]*metadata.PodInfo // cgroup id -> PodInfo
+	notifier
+}
+
+func NewKubernetesEnricher(cfg config.KubernetesConfig, nodeName string) (*KubernetesEnricher, error) {
+	m := client.NewK8sManager()
+	if err := m.CreateClient(); err != nil {
+		return nil, errors.Wrap(err, "failed to create k8s client")
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(m.GetK8sClientSet(), informerResyncPeriod,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
+		}))
+
+	e := &KubernetesEnricher{
+		nodeName: nodeName,
+		cgroups:  NewCgroupResolver(cfg.CgroupRoot),
+		factory:  factory,
+		informer: factory.Core().V1().Pods().Informer(),
+		pods:     make(map[uint64]*metadata.PodInfo),
+	}
+
+	err := e.informer.AddIndexers(toolscache.Indexers{
+		containerIDIndex: indexByContainerID,
+		podUIDIndex:      indexByPodUID,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to add pod indexers")
+	}
+
+	_, err = e.informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { e.notify() },
+		UpdateFunc: func(interface{}, interface{}) { e.notify() },
+		DeleteFunc: e.onPodDelete,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to add pod event handler")
+	}
+
+	return e, nil
+}
+
+func (e *KubernetesEnricher) Start(ctx context.Context) error {
+	log.Infof("starting pod informer on node %s", e.nodeName)
+	e.factory.Start(ctx.Done())
+	if !toolscache.WaitForCacheSync(ctx.Done(), e.informer.HasSynced) {
+		return errors.New("failed to sync pod informer cache")
+	}
+
+	<-ctx.Done()
+	e.factory.Shutdown()
+	return nil
+}
+
+func (e *KubernetesEnricher) Lookup(cgroupID uint64) (*metadata.PodInfo, bool) {
+	e.mu.RLock()
+	info, ok := e.pods[cgroupID]
+	e.mu.RUnlock()
+	if ok {
+		return info, true
+	}
+
+	cgroupPath, ok := e.cgroups.Path(cgroupID)
+	if !ok {
+		return nil, false
+	}
+
+	info = e.resolve(cgroupPath)
+	if info == nil {
+		return nil, false
+	}
+
+	e.mu.Lock()
+	e.pods[cgroupID] = info
+	e.mu.Unlock()
+
+	return info, true
+}
+
+func (e *KubernetesEnricher) Pods() []*metadata.PodInfo {
+	objs := e.informer.GetStore().List()
+	pods := make([]*metadata.PodInfo, 0, len(objs))
+	for _, obj := range objs {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			pods = append(pods, newPodInfo(pod, ""))
+		}
+	}
+
+	return pods
+}
+
+// resolve 优先按容器 ID 匹配 Pod，未命中时（例如 pause 容器）按 Pod UID 匹配
+func (e *KubernetesEnricher) resolve(cgroupPath string) *metadata.PodInfo {
+	containerID := ContainerIDFromPath(cgroupPath)
+	if containerID != "" {
+		objs, err := e.informer.GetIndexer().ByIndex(containerIDIndex, containerID)
+		if err == nil && len(objs) > 0 {
+			if pod, ok := objs[0].(*corev1.Pod); ok {
+				return newPodInfo(pod, containerID)
+			}
+		}
+	}
+
+	podUID := PodUIDFromPath(cgroupPath)
+	if podUID == "" {
+		return nil
+	}
+
+	objs, err := e.informer.GetIndexer().ByIndex(podUIDIndex, podUID)
+	if err != nil || len(objs) == 0 {
+		return nil
+	}
+
+	pod, ok := objs[0].(*corev1.Pod)
+	if !ok {
+		return nil
+	}
+
+	return newPodInfo(pod, containerID)
+}
+
+// onPodDelete Pod 删除后清理相关缓存
+func (e *KubernetesEnricher) onPodDelete(obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+
+	e.mu.Lock()
+	for id, info := range e.pods {
+		if info.UID == string(pod.UID) {
+			delete(e.pods, id)
+		}
+	}
+	e.mu.Unlock()
+
+	for _, id := range podContainerIDs(pod) {
+		e.cgroups.Invalidate(id)
+	}
+
+	e.notify()
+}
+
+func newPodInfo(pod *corev1.Pod, containerID string) *metadata.PodInfo {
+	info := &metadata.PodInfo{
+		Name:        pod.Name,
+		Namespace:   pod.Namespace,
+		UID:         string(pod.UID),
+		ContainerID: containerID,
+		Labels:      pod.Labels,
+	}
+
+	for _, status := range podContainerStatuses(pod) {
+		if TrimContainerIDScheme(status.ContainerID) == containerID {
+			info.ContainerName = status.Name
+			break
+		}
+	}
+
+	return info
+}
+
+func podContainerStatuses(pod *corev1.Pod) []corev1.ContainerStatus {
+	statuses := make([]corev1.ContainerStatus, 0,
+		len(pod.Status.InitContainerStatuses)+len(pod.Status.ContainerStatuses)+len(pod.Status.EphemeralContainerStatuses))
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	statuses = append(statuses, pod.Status.EphemeralContainerStatuses...)
+	return statuses
+}
+
+func podContainerIDs(pod *corev1.Pod) []string {
+	var ids []string
+	for _, status := range podContainerStatuses(pod) {
+		if status.ContainerID == "" {
+			continue
+		}
+		ids = append(ids, TrimContainerIDScheme(status.ContainerID))
+	}
+
+	return ids
+}
+
+func indexByContainerID(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return nil, nil
+	}
+
+	return podContainerIDs(pod), nil
+}
+
+func indexByPodUID(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return nil, nil
+	}
+
+	return []string{string(pod.UID)}, nil
+}
@@ -0,0 +1,154 @@
+package enricher
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	ebpfbinary "github.com/cen-ngc5139/shepherd/internal/binary"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/perf"
+	"github.com/pkg/errors"
+)
+
+const (
+	TaskExitsMap = "task_exits"
+
+	// maxTgidCache 缓存上限，退出通知丢失时避免缓存无限增长
+	maxTgidCache = 65536
+)
+
+// TgidResolver 经典 tracepoint 变体下事件中的 pid 可能是线程 id，通过 /proc/<tid>/status 解析所属进程
+// 线程退出后 tid 可能被复用，收到内核的退出通知时删除对应缓存
+type TgidResolver struct {
+	exits *ebpf.Map
+
+	mu    sync.RWMutex
+	tgids map[uint32]uint32 // tid -> tgid
+}
+
+func NewTgidResolver(coll *ebpf.Collection) (*TgidResolver, error) {
+	m, ok := coll.Maps[TaskExitsMap]
+	if !ok {
+		return nil, errors.Errorf("map %s not found", TaskExitsMap)
+	}
+
+	return &TgidResolver{
+		exits: m,
+		tgids: make(map[uint32]uint32),
+	}, nil
+}
+
+// Start 消费线程退出通知直到 ctx 结束
+func (r *TgidResolver) Start(ctx context.Context) error {
+	rd, err := perf.NewReader(r.exits, os.Getpagesize())
+	if err != nil {
+		return errors.Wrap(err, "failed to create task exit reader")
+	}
+
+	go func() {
+		<-ctx.Done()
+		rd.Close()
+	}()
+
+	for {
+		record, err := rd.Read()
+		if err != nil {
+			if errors.Is(err, perf.ErrClosed) {
+				return nil
+			}
+			log.Errorf("failed to read task exit: %v", err)
+			continue
+		}
+
+		// 丢失的退出通知无法得知对应的线程，清空缓存以免复用的 tid 命中旧的 tgid
+		if record.LostSamples > 0 {
+			r.reset()
+			continue
+		}
+		if len(record.RawSample) < 4 {
+			continue
+		}
+
+		tid := binary.LittleEndian.Uint32(record.RawSample)
+		r.mu.Lock()
+		delete(r.tgids, tid)
+		r.mu.Unlock()
+	}
+}
+
+// Resolve 返回线程所属的 tgid，线程已退出无法解析时返回 tid 本身
+func (r *TgidResolver) Resolve(tid uint32) uint32 {
+	if tid == 0 {
+		return 0
+	}
+
+	r.mu.RLock()
+	tgid, ok := r.tgids[tid]
+	r.mu.RUnlock()
+	if ok {
+		return tgid
+	}
+
+	tgid, err := readTgid(tid)
+	if err != nil {
+		return tid
+	}
+
+	r.mu.Lock()
+	if len(r.tgids) >= maxTgidCache {
+		r.tgids = make(map[uint32]uint32)
+	}
+	r.tgids[tid] = tgid
+	r.mu.Unlock()
+
+	return tgid
+}
+
+// Fill 内核未能确定 tgid 时 pid 与 tid 相同，此时改为从 /proc 解析，r 为 nil 时不做处理
+func (r *TgidResolver) Fill(event *ebpfbinary.ShepherdSchedLatencyT) {
+	if r == nil || event.Pid != event.Tid {
+		return
+	}
+
+	event.Pid = r.Resolve(event.Tid)
+}
+
+func (r *TgidResolver) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tgids = make(map[uint32]uint32)
+}
+
+// readTgid 读取 /proc/<tid>/status 中的 Tgid 字段，非主线程的 tid 在 /proc 下不可见但仍可直接访问
+func readTgid(tid uint32) (uint32, error) {
+	f, err := os.Open(config.GetProcPath(fmt.Sprintf("%d/status", tid)))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Tgid:	1234
+		value, ok := strings.CutPrefix(scanner.Text(), "Tgid:")
+		if !ok {
+			continue
+		}
+		tgid, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to parse tgid of %d", tid)
+		}
+		return uint32(tgid), nil
+	}
+
+	return 0, errors.Errorf("tgid of %d not found", tid)
+}
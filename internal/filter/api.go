@@ -0,0 +1,31 @@
+package filter
+
+import (
+	"net/http"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes 注册过滤规则的查询与修改接口
+func (f *Filter) RegisterRoutes(r gin.IRouter) {
+	r.GET("/filter", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, f.Rules())
+	})
+
+	// PUT /filter 整体替换过滤规则，未指定 mode 的维度关闭过滤
+	r.PUT("/filter", func(ctx *gin.Context) {
+		var rules config.FilterConfig
+		if err := ctx.ShouldBindJSON(&rules); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := f.Apply(rules); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, f.Rules())
+	})
+}
@@ -0,0 +1,314 @@
+package filter
+
+import (
+	"context"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ebpfbinary "github.com/cen-ngc5139/shepherd/internal/binary"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/enricher"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+)
+
+const (
+	configMap  = "filter_config"
+	tgidsMap   = "filter_tgids"
+	commsMap   = "filter_comms"
+	cgroupsMap = "filter_cgroups"
+
+	// commLen 与 trace.c 中 FILTER_COMM_LEN 一致，末尾保留一个字节给结束符
+	commLen = 16
+
+	// resyncPeriod 按路径指定的 cgroup 重新解析的周期，覆盖规则下发之后才创建的 cgroup
+	resyncPeriod = 10 * time.Second
+)
+
+// 与 trace.c 中 FILTER_* 一致
+const (
+	modeOff   uint32 = 0
+	modeAllow uint32 = 1
+	modeDeny  uint32 = 2
+)
+
+// Filter 维护内核态的进程过滤 map，修改立即生效，无需重新挂载程序
+type Filter struct {
+	cgroups *enricher.CgroupResolver
+
+	configMap *ebpf.Map
+	tgidMap   *ebpf.Map
+	commMap   *ebpf.Map
+	cgroupMap *ebpf.Map
+
+	mu        sync.Mutex
+	rules     config.FilterConfig
+	tgids     map[uint32]struct{}
+	comms     map[ebpfbinary.ShepherdFilterCommKeyT]struct{}
+	cgroupIDs map[uint64]struct{}
+}
+
+func New(coll *ebpf.Collection, cgroupRoot string) (*Filter, error) {
+	maps := make(map[string]*ebpf.Map)
+	for _, name := range []string{configMap, tgidsMap, commsMap, cgroupsMap} {
+		m, ok := coll.Maps[name]
+		if !ok {
+			return nil, errors.Errorf("map %s not found", name)
+		}
+		maps[name] = m
+	}
+
+	return &Filter{
+		cgroups:   enricher.NewCgroupResolver(cgroupRoot),
+		configMap: maps[configMap],
+		tgidMap:   maps[tgidsMap],
+		commMap:   maps[commsMap],
+		cgroupMap: maps[cgroupsMap],
+		tgids:     make(map[uint32]struct{}),
+		comms:     make(map[ebpfbinary.ShepherdFilterCommKeyT]struct{}),
+		cgroupIDs: make(map[uint64]struct{}),
+	}, nil
+}
+
+// Rules 返回当前生效的过滤规则
+func (f *Filter) Rules() config.FilterConfig {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.rules
+}
+
+// Apply 校验并下发过滤规则，与当前 map 内容做增量同步
+func (f *Filter) Apply(rules config.FilterConfig) error {
+	for _, rule := range []config.FilterRule{rules.Tgid, rules.Comm, rules.Cgroup} {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+
+	tgids, err := parseTgids(rules.Tgid.Values)
+	if err != nil {
+		return err
+	}
+	comms, err := parseComms(rules.Comm.Values)
+	if err != nil {
+		return err
+	}
+	cgroupIDs, err := f.resolveCgroups(rules.Cgroup.Values)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// 模式变化的维度先关闭过滤，避免新旧规则混用期间误过滤
+	old := f.rules
+	transient := ebpfbinary.ShepherdFilterConfigT{
+		TgidMode:   keepMode(old.Tgid.Mode, rules.Tgid.Mode),
+		CommMode:   keepMode(old.Comm.Mode, rules.Comm.Mode),
+		CgroupMode: keepMode(old.Cgroup.Mode, rules.Cgroup.Mode),
+	}
+	if err := f.putConfig(transient); err != nil {
+		return err
+	}
+
+	if err := syncSet(f.tgidMap, f.tgids, tgids); err != nil {
+		return errors.Wrapf(err, "failed to sync %s", tgidsMap)
+	}
+	if err := syncSet(f.commMap, f.comms, comms); err != nil {
+		return errors.Wrapf(err, "failed to sync %s", commsMap)
+	}
+	if err := syncSet(f.cgroupMap, f.cgroupIDs, cgroupIDs); err != nil {
+		return errors.Wrapf(err, "failed to sync %s", cgroupsMap)
+	}
+
+	if err := f.putConfig(ebpfbinary.ShepherdFilterConfigT{
+		TgidMode:   modeValue(rules.Tgid.Mode),
+		CommMode:   modeValue(rules.Comm.Mode),
+		CgroupMode: modeValue(rules.Cgroup.Mode),
+	}); err != nil {
+		return err
+	}
+
+	f.rules = rules
+	log.Infof("process filter applied, tgid: %s %v, comm: %s %v, cgroup: %s %v (%d ids)",
+		rules.Tgid.Mode, rules.Tgid.Values, rules.Comm.Mode, rules.Comm.Values,
+		rules.Cgroup.Mode, rules.Cgroup.Values, len(cgroupIDs))
+	return nil
+}
+
+// Start 周期性重新解析按路径指定的 cgroup
+func (f *Filter) Start(ctx context.Context) error {
+	ticker := time.NewTicker(resyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if err := f.resync(); err != nil {
+			log.Warningf("failed to resync cgroup filter: %v", err)
+		}
+	}
+}
+
+func (f *Filter) resync() error {
+	rules := f.Rules()
+	if rules.Cgroup.Mode == "" || !hasCgroupPath(rules.Cgroup.Values) {
+		return nil
+	}
+
+	ids, err := f.resolveCgroups(rules.Cgroup.Values)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// 解析期间规则被修改时放弃本次同步
+	if !equalRule(f.rules.Cgroup, rules.Cgroup) {
+		return nil
+	}
+
+	return errors.Wrapf(syncSet(f.cgroupMap, f.cgroupIDs, ids), "failed to sync %s", cgroupsMap)
+}
+
+func (f *Filter) putConfig(cfg ebpfbinary.ShepherdFilterConfigT) error {
+	var key uint32
+	return errors.Wrapf(f.configMap.Put(key, cfg), "failed to update %s", configMap)
+}
+
+// resolveCgroups 数字视为 cgroup id，其余视为路径并展开为其下全部 cgroup
+func (f *Filter) resolveCgroups(values []string) (map[uint64]struct{}, error) {
+	ids := make(map[uint64]struct{})
+	var paths []string
+	for _, v := range values {
+		if id, err := strconv.ParseUint(v, 10, 64); err == nil {
+			ids[id] = struct{}{}
+			continue
+		}
+		if !strings.HasPrefix(v, "/") {
+			return nil, errors.Errorf("invalid cgroup %q, must be an id or an absolute path", v)
+		}
+		paths = append(paths, path.Clean(v))
+	}
+
+	if len(paths) > 0 {
+		matched := f.cgroups.Match(func(p string) bool {
+			for _, prefix := range paths {
+				if prefix == "/" || p == prefix || strings.HasPrefix(p, prefix+"/") {
+					return true
+				}
+			}
+			return false
+		})
+		for _, id := range matched {
+			ids[id] = struct{}{}
+		}
+	}
+
+	return ids, nil
+}
+
+func parseTgids(values []string) (map[uint32]struct{}, error) {
+	tgids := make(map[uint32]struct{}, len(values))
+	for _, v := range values {
+		tgid, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, errors.Errorf("invalid tgid %q", v)
+		}
+		tgids[uint32(tgid)] = struct{}{}
+	}
+
+	return tgids, nil
+}
+
+func parseComms(values []string) (map[ebpfbinary.ShepherdFilterCommKeyT]struct{}, error) {
+	comms := make(map[ebpfbinary.ShepherdFilterCommKeyT]struct{}, len(values))
+	for _, v := range values {
+		if len(v) >= commLen {
+			return nil, errors.Errorf("comm prefix %q exceeds %d bytes", v, commLen-1)
+		}
+
+		key := ebpfbinary.ShepherdFilterCommKeyT{Prefixlen: uint32(len(v) * 8)}
+		for i := 0; i < len(v); i++ {
+			key.Comm[i] = int8(v[i])
+		}
+		comms[key] = struct{}{}
+	}
+
+	return comms, nil
+}
+
+// syncSet 将 desired 增量同步到 BPF map，current 记录 map 中已有的 key
+func syncSet[K comparable](m *ebpf.Map, current, desired map[K]struct{}) error {
+	var value uint8 = 1
+	for k := range desired {
+		if _, ok := current[k]; ok {
+			continue
+		}
+		if err := m.Put(k, value); err != nil {
+			return err
+		}
+		current[k] = struct{}{}
+	}
+
+	for k := range current {
+		if _, ok := desired[k]; ok {
+			continue
+		}
+		if err := m.Delete(k); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return err
+		}
+		delete(current, k)
+	}
+
+	return nil
+}
+
+func modeValue(mode config.FilterMode) uint32 {
+	switch mode {
+	case config.FilterAllow:
+		return modeAllow
+	case config.FilterDeny:
+		return modeDeny
+	default:
+		return modeOff
+	}
+}
+
+func keepMode(old, new config.FilterMode) uint32 {
+	if old != new {
+		return modeOff
+	}
+	return modeValue(old)
+}
+
+func hasCgroupPath(values []string) bool {
+	for _, v := range values {
+		if _, err := strconv.ParseUint(v, 10, 64); err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func equalRule(a, b config.FilterRule) bool {
+	if a.Mode != b.Mode || len(a.Values) != len(b.Values) {
+		return false
+	}
+	for i := range a.Values {
+		if a.Values[i] != b.Values[i] {
+			return false
+		}
+	}
+	return true
+}
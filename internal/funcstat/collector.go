@@ -0,0 +1,91 @@
+package funcstat
+
+import (
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/binary"
+	"github.com/cen-ngc5139/shepherd/internal/bpf"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector 在抓取时读取可选探针按函数地址累计的调用次数与耗时
+type Collector struct {
+	stats *ebpf.Map
+	names map[uint64]string // 函数地址 -> 函数名
+
+	calls   *prometheus.Desc
+	seconds *prometheus.Desc
+}
+
+func NewCollector(coll *ebpf.Collection, cfg config.ProbesConfig) (*Collector, error) {
+	stats, ok := coll.Maps[bpf.ProbeFuncStatsMap]
+	if !ok {
+		return nil, errors.Errorf("map %s not found", bpf.ProbeFuncStatsMap)
+	}
+
+	var funcs []string
+	for _, item := range cfg.Items {
+		funcs = append(funcs, item.Funcs...)
+	}
+	names := make(map[uint64]string)
+	for name, addr := range bpf.KernelFuncAddrs(funcs...) {
+		names[addr] = name
+	}
+
+	c := &Collector{
+		stats: stats,
+		names: names,
+		calls: prometheus.NewDesc("kernel_func_calls_total",
+			"calls of kernel functions traced by optional probes", []string{"func"}, nil),
+		seconds: prometheus.NewDesc("kernel_func_seconds_total",
+			"time spent in kernel functions traced by latency probes", []string{"func"}, nil),
+	}
+	if err := prometheus.Register(c); err != nil {
+		return nil, errors.Wrap(err, "failed to register kernel function collector")
+	}
+
+	return c, nil
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.calls
+	ch <- c.seconds
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	var (
+		ip     uint64
+		values []binary.ShepherdProbeFuncStatT
+	)
+
+	// 多个地址可能无法还原函数名，按函数名合并后导出
+	stats := make(map[string]binary.ShepherdProbeFuncStatT)
+	iter := c.stats.Iterate()
+	for iter.Next(&ip, &values) {
+		// kptr_restrict 生效时 kallsyms 中地址为 0，无法还原函数名
+		name, ok := c.names[ip]
+		if !ok {
+			name = "unknown"
+		}
+
+		stat := stats[name]
+		for _, v := range values {
+			stat.Count += v.Count
+			stat.TimeNs += v.TimeNs
+		}
+		stats[name] = stat
+	}
+	if err := iter.Err(); err != nil {
+		log.Errorf("failed to iterate %s: %v", bpf.ProbeFuncStatsMap, err)
+	}
+
+	for name, stat := range stats {
+		ch <- prometheus.MustNewConstMetric(c.calls, prometheus.CounterValue, float64(stat.Count), name)
+		ch <- prometheus.MustNewConstMetric(c.seconds, prometheus.CounterValue,
+			time.Duration(stat.TimeNs).Seconds(), name)
+	}
+}
@@ -0,0 +1,184 @@
+package irqstat
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/binary"
+	"github.com/cen-ngc5139/shepherd/internal/bpf"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 与 trace.c 中 IRQ_* 一致
+const (
+	typeHardirq = 0
+	typeSoftirq = 1
+)
+
+// softirqNames 软中断向量名，与 include/linux/interrupt.h 一致
+var softirqNames = []string{"HI", "TIMER", "NET_TX", "NET_RX", "BLOCK", "IRQ_POLL", "TASKLET", "SCHED", "HRTIMER", "RCU"}
+
+// Collector 在抓取时读取内核态累计的中断处理时间，按中断号、软中断向量与 CPU 导出
+type Collector struct {
+	vecTime *ebpf.Map
+	cpuTime *ebpf.Map
+
+	vecSeconds *prometheus.Desc
+	vecCount   *prometheus.Desc
+	cpuSeconds *prometheus.Desc
+
+	mu    sync.Mutex
+	names map[uint32]string // 硬中断号 -> /proc/interrupts 中的设备名
+}
+
+func NewCollector(coll *ebpf.Collection) (*Collector, error) {
+	vecTime, ok := coll.Maps[bpf.IrqVecTimeMap]
+	if !ok {
+		return nil, errors.Errorf("map %s not found", bpf.IrqVecTimeMap)
+	}
+	cpuTime, ok := coll.Maps[bpf.CPUIrqTimeMap]
+	if !ok {
+		return nil, errors.Errorf("map %s not found", bpf.CPUIrqTimeMap)
+	}
+
+	c := &Collector{
+		vecTime: vecTime,
+		cpuTime: cpuTime,
+		vecSeconds: prometheus.NewDesc("sched_irq_seconds_total",
+			"time spent in hardirq and softirq handlers by vector", []string{"type", "vector", "name"}, nil),
+		vecCount: prometheus.NewDesc("sched_irq_total",
+			"hardirq and softirq handler invocations by vector", []string{"type", "vector", "name"}, nil),
+		cpuSeconds: prometheus.NewDesc("sched_cpu_irq_seconds_total",
+			"time spent in hardirq and softirq handlers by cpu", []string{"cpu", "type"}, nil),
+		names: readInterruptNames(),
+	}
+	if err := prometheus.Register(c); err != nil {
+		return nil, errors.Wrap(err, "failed to register irq collector")
+	}
+
+	return c, nil
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.vecSeconds
+	ch <- c.vecCount
+	ch <- c.cpuSeconds
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.collectVectors(ch)
+	c.collectCPUs(ch)
+}
+
+func (c *Collector) collectVectors(ch chan<- prometheus.Metric) {
+	var (
+		key    binary.ShepherdIrqKeyT
+		values []binary.ShepherdIrqVecStatT
+	)
+
+	iter := c.vecTime.Iterate()
+	for iter.Next(&key, &values) {
+		var stat binary.ShepherdIrqVecStatT
+		for _, v := range values {
+			stat.Count += v.Count
+			stat.TimeNs += v.TimeNs
+		}
+
+		typ, name := c.describe(key)
+		vector := strconv.FormatUint(uint64(key.Vec), 10)
+		ch <- prometheus.MustNewConstMetric(c.vecSeconds, prometheus.CounterValue,
+			time.Duration(stat.TimeNs).Seconds(), typ, vector, name)
+		ch <- prometheus.MustNewConstMetric(c.vecCount, prometheus.CounterValue,
+			float64(stat.Count), typ, vector, name)
+	}
+	if err := iter.Err(); err != nil {
+		log.Errorf("failed to iterate %s: %v", bpf.IrqVecTimeMap, err)
+	}
+}
+
+func (c *Collector) collectCPUs(ch chan<- prometheus.Metric) {
+	var (
+		cpu   uint32
+		total binary.ShepherdCpuIrqTimeT
+	)
+
+	iter := c.cpuTime.Iterate()
+	for iter.Next(&cpu, &total) {
+		// 数组 map 包含全部可能的 CPU，跳过从未处理过中断的条目
+		if total.HardirqNs == 0 && total.SoftirqNs == 0 {
+			continue
+		}
+
+		id := strconv.FormatUint(uint64(cpu), 10)
+		ch <- prometheus.MustNewConstMetric(c.cpuSeconds, prometheus.CounterValue,
+			time.Duration(total.HardirqNs).Seconds(), id, "hardirq")
+		ch <- prometheus.MustNewConstMetric(c.cpuSeconds, prometheus.CounterValue,
+			time.Duration(total.SoftirqNs).Seconds(), id, "softirq")
+	}
+	if err := iter.Err(); err != nil {
+		log.Errorf("failed to iterate %s: %v", bpf.CPUIrqTimeMap, err)
+	}
+}
+
+func (c *Collector) describe(key binary.ShepherdIrqKeyT) (string, string) {
+	if key.Type == typeSoftirq {
+		if int(key.Vec) < len(softirqNames) {
+			return "softirq", softirqNames[key.Vec]
+		}
+		return "softirq", "unknown"
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 设备热插拔后中断号可能重新分配，遇到未知中断号时重新读取
+	name, ok := c.names[key.Vec]
+	if !ok {
+		c.names = readInterruptNames()
+		if name, ok = c.names[key.Vec]; !ok {
+			name = "unknown"
+			c.names[key.Vec] = name
+		}
+	}
+
+	return "hardirq", name
+}
+
+// readInterruptNames 读取 /proc/interrupts 中硬中断号对应的设备名
+func readInterruptNames() map[uint32]string {
+	names := map[uint32]string{}
+
+	path := config.GetProcPath("interrupts")
+	f, err := os.Open(path)
+	if err != nil {
+		log.Warningf("failed to open %s: %v", path, err)
+		return names
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 24:   0   0   PCI-MSI 65536-edge   nvme0q0
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		irq, err := strconv.ParseUint(strings.TrimSuffix(fields[0], ":"), 10, 32)
+		if err != nil {
+			// NMI、LOC 等架构相关的中断没有中断号
+			continue
+		}
+		names[uint32(irq)] = fields[len(fields)-1]
+	}
+
+	return names
+}
@@ -0,0 +1,178 @@
+package k8sevent
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cen-ngc5139/shepherd/internal/metadata"
+	"github.com/cen-ngc5139/shepherd/pkg/client"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	ReasonNoisyNeighbor = "NoisyNeighborPreemption"
+
+	defaultWindow             = time.Minute
+	defaultCooldown           = 10 * time.Minute
+	defaultMaxEventsPerMinute = 30
+	defaultMinPreemptions     = 100
+
+	// minWindow 窗口按 windowSlots 个槽位滑动，每个槽位至少 1s
+	minWindow = windowSlots * time.Second
+)
+
+// Recorder 跟踪 Pod 之间的抢占，持续超过阈值时在受害 Pod 上创建 Kubernetes Event
+type Recorder struct {
+	cfg      config.EventsConfig
+	recorder record.EventRecorder
+	limiter  *rate.Limiter
+
+	mu        sync.Mutex
+	pairs     map[pairKey]*pairStats
+	lastSweep time.Time
+}
+
+func NewRecorder(cfg config.EventsConfig, nodeName string) (*Recorder, error) {
+	if err := validate(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Window == 0 {
+		cfg.Window = defaultWindow
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaultCooldown
+	}
+	if cfg.MaxEventsPerMinute <= 0 {
+		cfg.MaxEventsPerMinute = defaultMaxEventsPerMinute
+	}
+	if cfg.MinPreemptions <= 0 && cfg.LatencyBudget <= 0 {
+		cfg.MinPreemptions = defaultMinPreemptions
+	}
+
+	m := client.NewK8sManager()
+	if err := m.CreateClient(); err != nil {
+		return nil, errors.Wrap(err, "failed to create k8s client")
+	}
+
+	// EventBroadcaster 会合并相同的 Event 并递增 count，实现服务端去重
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: m.GetK8sClientSet().CoreV1().Events(""),
+	})
+
+	return &Recorder{
+		cfg: cfg,
+		recorder: broadcaster.NewRecorder(client.Scheme, corev1.EventSource{
+			Component: "shepherd",
+			Host:      nodeName,
+		}),
+		limiter: rate.NewLimiter(rate.Limit(float64(cfg.MaxEventsPerMinute)/60), cfg.MaxEventsPerMinute),
+		pairs:   make(map[pairKey]*pairStats),
+	}, nil
+}
+
+// Handle 记录一次跨 Pod 抢占，满足条件时创建 Event
+func (r *Recorder) Handle(event metadata.SchedEvent) {
+	if event.IsPreempt != 1 || event.Pod == nil || event.PreemptedPod == nil {
+		return
+	}
+	if event.Pod.UID == event.PreemptedPod.UID {
+		return
+	}
+
+	now := time.Now()
+	key := pairKey{victimUID: event.Pod.UID, aggressorUID: event.PreemptedPod.UID}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sweep(now)
+
+	stats, ok := r.pairs[key]
+	if !ok {
+		stats = &pairStats{victim: event.Pod, aggressor: event.PreemptedPod}
+		r.pairs[key] = stats
+	}
+
+	stats.add(now, r.cfg.Window, event.DelayNs)
+	if !r.exceeded(stats.count, stats.totalNs) {
+		return
+	}
+
+	if now.Sub(stats.lastEvent) < r.cfg.Cooldown {
+		return
+	}
+
+	if !r.limiter.Allow() {
+		log.Warningf("noisy neighbor event for pod %s/%s dropped by rate limiter",
+			stats.victim.Namespace, stats.victim.Name)
+		return
+	}
+
+	stats.lastEvent = now
+	r.emit(stats)
+}
+
+// validate 检查配置，0 表示使用默认值
+func validate(cfg config.EventsConfig) error {
+	if cfg.Window != 0 && cfg.Window < minWindow {
+		return errors.Errorf("events window %s is too short, must be at least %s", cfg.Window, minWindow)
+	}
+	if cfg.MinPreemptions < 0 {
+		return errors.Errorf("invalid events min_preemptions %d", cfg.MinPreemptions)
+	}
+	if cfg.LatencyBudget < 0 || cfg.Cooldown < 0 || cfg.MaxEventsPerMinute < 0 {
+		return errors.New("events latency_budget, cooldown and max_events_per_minute must not be negative")
+	}
+
+	return nil
+}
+
+func (r *Recorder) exceeded(count, totalNs uint64) bool {
+	if r.cfg.MinPreemptions > 0 && count >= uint64(r.cfg.MinPreemptions) {
+		return true
+	}
+
+	return r.cfg.LatencyBudget > 0 && time.Duration(totalNs) >= r.cfg.LatencyBudget
+}
+
+func (r *Recorder) emit(stats *pairStats) {
+	ref := &corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Name:       stats.victim.Name,
+		Namespace:  stats.victim.Namespace,
+		UID:        types.UID(stats.victim.UID),
+	}
+
+	maxNs, p99Ns := stats.maxAndQuantile(0.99)
+	message := fmt.Sprintf("preempted %d times by pod %s/%s in the last %s, total scheduling delay %s, p99 %s, max %s",
+		stats.count, stats.aggressor.Namespace, stats.aggressor.Name, r.cfg.Window,
+		time.Duration(stats.totalNs), time.Duration(p99Ns), time.Duration(maxNs))
+
+	r.recorder.Event(ref, corev1.EventTypeWarning, ReasonNoisyNeighbor, message)
+	log.Infof("noisy neighbor event on pod %s/%s: %s", stats.victim.Namespace, stats.victim.Name, message)
+}
+
+// sweep 清理窗口与冷却期都已过去的 Pod 对
+func (r *Recorder) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < r.cfg.Window {
+		return
+	}
+	r.lastSweep = now
+
+	for key, stats := range r.pairs {
+		stats.prune(now, r.cfg.Window)
+		if stats.count == 0 && now.Sub(stats.lastEvent) > r.cfg.Cooldown {
+			delete(r.pairs, key)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package k8sevent
+
+import (
+	"math"
+	"math/bits"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/metadata"
+)
+
+const (
+	// windowSlots 统计窗口划分的槽位数，窗口按槽位整体滑动，边界误差不超过一个槽位
+	windowSlots = 10
+
+	// latencyBuckets 第 i 个桶统计延迟位于 [2^i, 2^(i+1)) 纳秒的次数
+	latencyBuckets = 64
+)
+
+// pairKey 受害 Pod 与抢占 Pod 组成的键
+type pairKey struct {
+	victimUID    string
+	aggressorUID string
+}
+
+// slot 一个槽位内的抢占计数与延迟分布
+type slot struct {
+	start   time.Time
+	count   uint64
+	totalNs uint64
+	maxNs   uint64
+	buckets [latencyBuckets]uint64
+}
+
+// pairStats 单个 Pod 对在窗口内的抢占统计，内存占用固定，不随抢占次数增长
+type pairStats struct {
+	victim    *metadata.PodInfo
+	aggressor *metadata.PodInfo
+	slots     [windowSlots]slot
+	count     uint64 // 窗口内全部槽位的抢占次数
+	totalNs   uint64 // 窗口内全部槽位的累计延迟
+	lastEvent time.Time
+}
+
+// add 记录一次抢占，槽位被新的时间段复用前先扣除旧的计数
+func (p *pairStats) add(now time.Time, window time.Duration, delayNs uint64) {
+	p.prune(now, window)
+
+	width := window / windowSlots
+	start := now.Truncate(width)
+	s := &p.slots[(start.UnixNano()/int64(width))%windowSlots]
+	if !s.start.Equal(start) {
+		p.drop(s)
+		s.start = start
+	}
+
+	s.count++
+	s.totalNs += delayNs
+	s.maxNs = max(s.maxNs, delayNs)
+	s.buckets[bucketIndex(delayNs)]++
+	p.count++
+	p.totalNs += delayNs
+}
+
+// prune 丢弃窗口外的槽位
+func (p *pairStats) prune(now time.Time, window time.Duration) {
+	for i := range p.slots {
+		if s := &p.slots[i]; s.count > 0 && now.Sub(s.start) > window {
+			p.drop(s)
+		}
+	}
+}
+
+func (p *pairStats) drop(s *slot) {
+	p.count -= s.count
+	p.totalNs -= s.totalNs
+	*s = slot{}
+}
+
+// maxAndQuantile 合并窗口内的槽位，返回最大延迟与分位数的近似值（桶上界）
+func (p *pairStats) maxAndQuantile(q float64) (maxNs, quantileNs uint64) {
+	var buckets [latencyBuckets]uint64
+	for i := range p.slots {
+		s := &p.slots[i]
+		maxNs = max(maxNs, s.maxNs)
+		for j, c := range s.buckets {
+			buckets[j] += c
+		}
+	}
+	if p.count == 0 {
+		return maxNs, 0
+	}
+
+	rank := uint64(math.Ceil(q * float64(p.count)))
+	var cum uint64
+	for i, c := range buckets {
+		cum += c
+		if cum >= rank {
+			// 分位数不超过实际最大值
+			return maxNs, min(bucketUpper(i), maxNs)
+		}
+	}
+
+	return maxNs, maxNs
+}
+
+func bucketIndex(v uint64) int {
+	if v == 0 {
+		return 0
+	}
+
+	return 63 - bits.LeadingZeros64(v)
+}
+
+func bucketUpper(idx int) uint64 {
+	if idx >= 63 {
+		return math.MaxUint64
+	}
+
+	return uint64(1) << uint(idx+1)
+}
@@ -0,0 +1,64 @@
+package k8sevent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+)
+
+func TestPairStatsWindow(t *testing.T) {
+	window := time.Minute
+	start := time.Unix(1700000000, 0)
+	var p pairStats
+
+	// 超过旧实现样本上限的抢占次数仍能全部计入
+	for i := 0; i < 10000; i++ {
+		p.add(start.Add(time.Duration(i)*time.Millisecond), window, uint64(i%100+1)*uint64(time.Microsecond))
+	}
+	if p.count != 10000 {
+		t.Fatalf("count = %d, want 10000", p.count)
+	}
+
+	maxNs, p99Ns := p.maxAndQuantile(0.99)
+	if maxNs != 100*uint64(time.Microsecond) {
+		t.Errorf("max = %d", maxNs)
+	}
+	// 对数分桶的上界不超过真实值的 2 倍
+	if p99Ns < 99*uint64(time.Microsecond) || p99Ns > maxNs {
+		t.Errorf("p99 = %d", p99Ns)
+	}
+
+	p.add(start.Add(30*time.Second), window, 1)
+	if p.count != 10001 {
+		t.Errorf("count = %d, want 10001", p.count)
+	}
+
+	// 最早的槽位滑出窗口后只保留之后的抢占
+	p.prune(start.Add(window+15*time.Second), window)
+	if p.count != 1 || p.totalNs != 1 {
+		t.Errorf("after prune count = %d, total = %d", p.count, p.totalNs)
+	}
+
+	p.prune(start.Add(2*window), window)
+	if p.count != 0 || p.totalNs != 0 {
+		t.Errorf("after window count = %d, total = %d", p.count, p.totalNs)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		cfg config.EventsConfig
+		ok  bool
+	}{
+		{config.EventsConfig{}, true},
+		{config.EventsConfig{Window: time.Minute, MinPreemptions: 100000}, true},
+		{config.EventsConfig{Window: time.Second}, false},
+		{config.EventsConfig{MinPreemptions: -1}, false},
+		{config.EventsConfig{Cooldown: -time.Second}, false},
+	} {
+		if err := validate(tc.cfg); (err == nil) != tc.ok {
+			t.Errorf("validate(%+v) = %v", tc.cfg, err)
+		}
+	}
+}
@@ -1,15 +1,208 @@
 package metadata
 
+import (
+	"strings"
+
+	"github.com/cen-ngc5139/shepherd/internal/binary"
+)
+
 type SchedMetrics struct {
 	Pid           uint32 // 进程ID
 	DelayNs       uint64 // 调度延迟
 	Ts            uint64 // 时间戳
 	PreempteCount uint64 // 被抢占的次数
 	Comm          string // 进程名
+	Pod           string // 所属 Pod
+	Namespace     string // 所属命名空间
 }
 
 type SchedPreempted struct {
-	Pid   uint32 // 被抢占的进程
-	Count uint64 // 被抢占的次数
-	Comm  string // 被抢占的进程名
+	Pid       uint32 // 被抢占的进程
+	Count     uint64 // 被抢占的次数
+	Comm      string // 被抢占的进程名
+	Pod       string // 被抢占的进程所属 Pod
+	Namespace string // 被抢占的进程所属命名空间
+}
+
+type SchedCPUKey struct {
+	Cpu        uint32 // 运行所在 CPU
+	SchedClass string // 调度类
+}
+
+type SchedCPUMetrics struct {
+	SchedCPUKey
+	Count   uint64 // 事件数
+	DelayNs uint64 // 累计调度延迟
+}
+
+// SchedImbalanceMetrics 等待期间存在可用空闲 CPU 的调度延迟，反映负载均衡失效
+type SchedImbalanceMetrics struct {
+	Cpu        uint32 // 等待所在 CPU
+	Count      uint64 // 存在空闲 CPU 的事件数
+	DelayNs    uint64 // 存在空闲 CPU 的累计调度延迟
+	Migrations uint64 // 等待期间的累计迁移次数
+}
+
+type SchedWakerKey struct {
+	Comm    string // 唤醒方进程名
+	Context string // 唤醒上下文
+}
+
+type SchedWakerMetrics struct {
+	SchedWakerKey
+	Count   uint64 // 唤醒导致的事件数
+	DelayNs uint64 // 累计调度延迟
+}
+
+// PodInfo 描述 cgroup 所属容器对应的 Pod 信息
+type PodInfo struct {
+	Name          string            `json:"name"`           // Pod 名称
+	Namespace     string            `json:"namespace"`      // Pod 命名空间
+	UID           string            `json:"uid"`            // Pod UID
+	ContainerID   string            `json:"container_id"`   // 容器 ID
+	ContainerName string            `json:"container_name"` // 容器名
+	Labels        map[string]string `json:"labels,omitempty"`
+}
+
+// SchedEvent 调度延迟事件及其关联的 Pod 元数据
+type SchedEvent struct {
+	binary.ShepherdSchedLatencyT
+	SchedClass     string   `json:"sched_class"`               // 调度类，由 Policy 推导
+	LatencyKind    string   `json:"latency_kind"`              // 延迟类型，由 LatencyType 推导
+	WakerContext   string   `json:"waker_context"`             // 唤醒上下文，由 WakerCtx 推导
+	Pod            *PodInfo `json:"pod,omitempty"`             // 被延迟进程所属 Pod
+	PreemptedPod   *PodInfo `json:"preempted_pod,omitempty"`   // 抢占进程所属 Pod
+	Stack          *Stack   `json:"stack,omitempty"`           // 被延迟进程上次被切出时的调用栈
+	PreemptedStack *Stack   `json:"preempted_stack,omitempty"` // 抢占进程在抢占点的调用栈
+}
+
+// Stack 符号化后的调用栈，均按叶子帧在前排列
+type Stack struct {
+	Kernel []string `json:"kernel,omitempty"`
+	User   []string `json:"user,omitempty"`
+}
+
+// Folded 返回折叠格式的调用栈：根帧在前，内核帧以 _[k] 结尾
+func (s *Stack) Folded() string {
+	if s == nil {
+		return ""
+	}
+
+	frames := make([]string, 0, len(s.User)+len(s.Kernel))
+	for i := len(s.User) - 1; i >= 0; i-- {
+		frames = append(frames, s.User[i])
+	}
+	for i := len(s.Kernel) - 1; i >= 0; i-- {
+		frames = append(frames, s.Kernel[i]+"_[k]")
+	}
+
+	return strings.Join(frames, ";")
+}
+
+func NewSchedEvent(event binary.ShepherdSchedLatencyT) SchedEvent {
+	return SchedEvent{
+		ShepherdSchedLatencyT: event,
+		SchedClass:            SchedClassName(event.Policy),
+		LatencyKind:           LatencyKindName(event.LatencyType),
+		WakerContext:          WakerContextName(event.WakerCtx),
+	}
+}
+
+// CommString 将内核中以 0 结尾的 comm 转换为字符串
+func CommString(data []int8) string {
+	var result strings.Builder
+	for _, b := range data {
+		if b == 0 {
+			break
+		}
+		result.WriteByte(byte(b))
+	}
+
+	return strings.TrimSpace(result.String())
+}
+
+// 与 trace.c 中 LATENCY_* 一致
+const (
+	LatencyWakeup  = 0 // 唤醒后等待调度
+	LatencyRequeue = 1 // 被抢占后重新入队等待调度
+)
+
+func LatencyKindName(latencyType uint32) string {
+	switch latencyType {
+	case LatencyWakeup:
+		return "wakeup"
+	case LatencyRequeue:
+		return "requeue"
+	}
+
+	return "unknown"
+}
+
+// 与 trace.c 中 WAKER_* 一致
+const (
+	WakerUnknown = 0 // 未知或无唤醒方
+	WakerTask    = 1 // 进程上下文，如 IPC、锁释放
+	WakerHardirq = 2 // 硬中断，如定时器、IO 完成
+	WakerSoftirq = 3 // 软中断，如网络收包、hrtimer
+	WakerNMI     = 4
+)
+
+func WakerContextName(ctx uint32) string {
+	switch ctx {
+	case WakerTask:
+		return "task"
+	case WakerHardirq:
+		return "hardirq"
+	case WakerSoftirq:
+		return "softirq"
+	case WakerNMI:
+		return "nmi"
+	}
+
+	return "unknown"
+}
+
+// 调度策略，与 include/uapi/linux/sched.h 一致
+const (
+	SchedNormal   = 0
+	SchedFIFO     = 1
+	SchedRR       = 2
+	SchedBatch    = 3
+	SchedIdle     = 5
+	SchedDeadline = 6
+	SchedExt      = 7
+)
+
+// SchedClassName 将调度策略映射为所属调度类
+func SchedClassName(policy uint32) string {
+	switch policy {
+	case SchedNormal, SchedBatch:
+		return "CFS"
+	case SchedFIFO, SchedRR:
+		return "RT"
+	case SchedDeadline:
+		return "DL"
+	case SchedIdle:
+		return "IDLE"
+	case SchedExt:
+		return "EXT"
+	}
+
+	return "UNKNOWN"
+}
+
+// GetName 返回 Pod 名称，未关联 Pod 时返回空字符串
+func (p *PodInfo) GetName() string {
+	if p == nil {
+		return ""
+	}
+	return p.Name
+}
+
+// GetNamespace 返回 Pod 命名空间，未关联 Pod 时返回空字符串
+func (p *PodInfo) GetNamespace() string {
+	if p == nil {
+		return ""
+	}
+	return p.Namespace
 }
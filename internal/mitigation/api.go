@@ -0,0 +1,14 @@
+package mitigation
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes 注册生效中缓解的查询接口
+func (c *Controller) RegisterRoutes(r gin.IRouter) {
+	r.GET("/mitigations", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, c.Mitigations())
+	})
+}
@@ -0,0 +1,80 @@
+package mitigation
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/pkg/errors"
+)
+
+type Operation string
+
+const (
+	OperationApply  Operation = "apply"
+	OperationRevert Operation = "revert"
+)
+
+// AuditRecord 一次 cgroup 调整的审计记录
+type AuditRecord struct {
+	Time      time.Time               `json:"time"`
+	Node      string                  `json:"node"`
+	Operation Operation               `json:"operation"`
+	Action    config.MitigationAction `json:"action"`
+	EpisodeID string                  `json:"episode_id"`
+	Aggressor string                  `json:"aggressor"`
+	Cgroup    string                  `json:"cgroup"`
+	OldValue  string                  `json:"old_value"`
+	NewValue  string                  `json:"new_value"`
+	DryRun    bool                    `json:"dry_run"`
+	Reason    string                  `json:"reason,omitempty"`
+	Error     string                  `json:"error,omitempty"`
+}
+
+// auditLogger 以 JSON Lines 追加写入审计日志，未配置文件时写入 agent 日志
+type auditLogger struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newAuditLogger(path string) (*auditLogger, error) {
+	if path == "" {
+		return &auditLogger{}, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open audit log %s", path)
+	}
+
+	return &auditLogger{file: f}, nil
+}
+
+func (a *auditLogger) Record(record AuditRecord) {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		log.Errorf("failed to marshal mitigation audit record: %v", err)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.file == nil {
+		log.Infof("mitigation audit: %s", raw)
+		return
+	}
+
+	if _, err := a.file.Write(append(raw, '\n')); err != nil {
+		log.Errorf("failed to write mitigation audit record: %v", err)
+	}
+}
+
+func (a *auditLogger) Close() {
+	if a.file != nil {
+		a.file.Close()
+	}
+}
@@ -0,0 +1,118 @@
+package mitigation
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	cpuWeightFile = "cpu.weight"
+	cpuMaxFile    = "cpu.max"
+	cpusetFile    = "cpuset.cpus"
+
+	defaultWeightRatio = 0.25
+	defaultMinWeight   = 10
+	defaultMaxCores    = 1
+	defaultMaxPeriod   = 100000
+)
+
+// controlFile 返回缓解动作对应的 cgroup v2 接口文件
+func controlFile(action config.MitigationAction) string {
+	switch action {
+	case config.MitigationCPUWeight:
+		return cpuWeightFile
+	case config.MitigationCPUMax:
+		return cpuMaxFile
+	case config.MitigationCpuset:
+		return cpusetFile
+	}
+
+	return ""
+}
+
+func readControl(dir, file string) (string, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %s of %s", file, dir)
+	}
+
+	return strings.TrimSpace(string(raw)), nil
+}
+
+func writeControl(dir, file, value string) error {
+	// 空的 cpuset.cpus 表示继承父 cgroup，需写入换行符才能触发内核解析
+	data := value
+	if data == "" {
+		data = "\n"
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(data), 0644); err != nil {
+		return errors.Wrapf(err, "failed to write %q to %s of %s", value, file, dir)
+	}
+
+	return nil
+}
+
+// targetValue 按配置限制计算缓解后的取值，返回空字符串表示无需调整
+func targetValue(action config.MitigationAction, current string, cfg config.MitigationConfig) (string, error) {
+	switch action {
+	case config.MitigationCPUWeight:
+		weight, err := strconv.ParseUint(current, 10, 64)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid cpu.weight %q", current)
+		}
+
+		ratio, min := cfg.CPUWeight.Ratio, cfg.CPUWeight.Min
+		if ratio <= 0 || ratio >= 1 {
+			ratio = defaultWeightRatio
+		}
+		if min == 0 {
+			min = defaultMinWeight
+		}
+
+		target := uint64(float64(weight) * ratio)
+		if target < min {
+			target = min
+		}
+		if target >= weight {
+			return "", nil
+		}
+		return strconv.FormatUint(target, 10), nil
+
+	case config.MitigationCPUMax:
+		cores, period := cfg.CPUMax.Cores, cfg.CPUMax.Period
+		if cores <= 0 {
+			cores = defaultMaxCores
+		}
+		if period == 0 {
+			period = defaultMaxPeriod
+		}
+		quota := uint64(cores * float64(period))
+
+		// 已有更严格的限制时不放宽
+		fields := strings.Fields(current)
+		if len(fields) == 2 && fields[0] != "max" {
+			curQuota, err1 := strconv.ParseUint(fields[0], 10, 64)
+			curPeriod, err2 := strconv.ParseUint(fields[1], 10, 64)
+			if err1 == nil && err2 == nil && curPeriod > 0 &&
+				float64(curQuota)/float64(curPeriod) <= float64(quota)/float64(period) {
+				return "", nil
+			}
+		}
+		return fmt.Sprintf("%d %d", quota, period), nil
+
+	case config.MitigationCpuset:
+		if cfg.Cpuset.Cpus == "" || cfg.Cpuset.Cpus == current {
+			return "", nil
+		}
+		return cfg.Cpuset.Cpus, nil
+	}
+
+	return "", errors.Errorf("unknown mitigation action %s", action)
+}
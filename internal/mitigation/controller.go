@@ -0,0 +1,344 @@
+package mitigation
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/analysis"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/enricher"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+)
+
+const (
+	defaultMaxActive   = 5
+	defaultMaxDuration = 10 * time.Minute
+
+	expireCheckInterval = 10 * time.Second
+)
+
+// Change 对单个 cgroup 接口文件的一次调整
+type Change struct {
+	Action   config.MitigationAction `json:"action"`
+	OldValue string                  `json:"old_value"`
+	NewValue string                  `json:"new_value"`
+}
+
+// Mitigation 对单个抢占者 cgroup 生效中的缓解
+type Mitigation struct {
+	CgroupID  uint64    `json:"cgroup_id"`
+	Cgroup    string    `json:"cgroup"`
+	Aggressor string    `json:"aggressor"`
+	Episodes  []string  `json:"episodes"`
+	Changes   []Change  `json:"changes"`
+	StartedAt time.Time `json:"started_at"`
+	DryRun    bool      `json:"dry_run"`
+}
+
+// Controller 在吵闹邻居事件开始时限制抢占者 cgroup 的 CPU 资源，事件结束后回滚
+type Controller struct {
+	cfg      config.MitigationConfig
+	node     string
+	cgroups  *enricher.CgroupResolver
+	audit    *auditLogger
+	metrics  *metrics
+	excluded map[string]struct{}
+
+	mu     sync.Mutex
+	active map[uint64]*Mitigation // 抢占者 cgroup id -> 缓解
+}
+
+func NewController(cfg config.MitigationConfig, nodeName string) (*Controller, error) {
+	if len(cfg.Actions) == 0 {
+		cfg.Actions = []config.MitigationAction{config.MitigationCPUWeight}
+	}
+	if cfg.MaxActive <= 0 {
+		cfg.MaxActive = defaultMaxActive
+	}
+	if cfg.MaxDuration <= 0 {
+		cfg.MaxDuration = defaultMaxDuration
+	}
+	if cfg.CgroupRoot == "" {
+		cfg.CgroupRoot = enricher.DefaultCgroupRoot
+	}
+	if cfg.StateFile == "" {
+		cfg.StateFile = DefaultStateFile
+	}
+
+	audit, err := newAuditLogger(cfg.AuditLog)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := make(map[string]struct{}, len(cfg.ExcludeNamespaces))
+	for _, ns := range cfg.ExcludeNamespaces {
+		excluded[ns] = struct{}{}
+	}
+
+	return &Controller{
+		cfg:      cfg,
+		node:     nodeName,
+		cgroups:  enricher.NewCgroupResolver(cfg.CgroupRoot),
+		audit:    audit,
+		metrics:  newMetrics(),
+		excluded: excluded,
+		active:   make(map[uint64]*Mitigation),
+	}, nil
+}
+
+// Start 先回滚上次运行遗留的缓解，之后回滚超过最长持续时间的缓解，ctx 结束时回滚全部缓解
+func (c *Controller) Start(ctx context.Context) error {
+	c.restore()
+
+	ticker := time.NewTicker(expireCheckInterval)
+	defer ticker.Stop()
+
+	log.Infof("mitigation controller started, dry run %t, actions %v", c.cfg.DryRun, c.cfg.Actions)
+	for {
+		select {
+		case <-ctx.Done():
+			c.revertAll("agent shutdown")
+			c.audit.Close()
+			return nil
+		case now := <-ticker.C:
+			c.expire(now)
+		}
+	}
+}
+
+func (c *Controller) OnEpisode(episode analysis.Episode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if episode.State == analysis.EpisodeResolved {
+		c.release(episode.Aggressor.CgroupID, episode.ID, "episode resolved")
+		return
+	}
+
+	c.apply(episode)
+}
+
+// apply 只对 Pod 的 cgroup 执行缓解，调用方需持有锁
+func (c *Controller) apply(episode analysis.Episode) {
+	aggressor := episode.Aggressor
+	// 宿主机进程（system.slice、kubelet、容器运行时等）不做限制
+	if aggressor.CgroupID == 0 || aggressor.PodUID == "" || episode.Score < c.cfg.MinScore {
+		return
+	}
+	if _, ok := c.excluded[aggressor.Namespace]; ok && aggressor.Namespace != "" {
+		return
+	}
+
+	if m, ok := c.active[aggressor.CgroupID]; ok {
+		m.Episodes = append(m.Episodes, episode.ID)
+		return
+	}
+
+	if len(c.active) >= c.cfg.MaxActive {
+		log.Warningf("skip mitigation of %s: %d mitigations already active", aggressor, len(c.active))
+		return
+	}
+
+	cgroup, ok := c.cgroups.Resolve(aggressor.CgroupID)
+	if !ok {
+		log.Warningf("skip mitigation of %s: cgroup %d not found", aggressor, aggressor.CgroupID)
+		return
+	}
+	if !isPodCgroup(cgroup, aggressor.PodUID) {
+		log.Warningf("skip mitigation of %s: cgroup %s is not a cgroup of pod %s", aggressor, cgroup, aggressor.PodUID)
+		return
+	}
+	dir := filepath.Join(c.cfg.CgroupRoot, cgroup)
+
+	m := &Mitigation{
+		CgroupID:  aggressor.CgroupID,
+		Cgroup:    cgroup,
+		Aggressor: aggressor.String(),
+		Episodes:  []string{episode.ID},
+		StartedAt: time.Now(),
+		DryRun:    c.cfg.DryRun,
+	}
+
+	for _, action := range c.cfg.Actions {
+		record := AuditRecord{
+			Operation: OperationApply,
+			Action:    action,
+			EpisodeID: episode.ID,
+			Aggressor: m.Aggressor,
+			Cgroup:    cgroup,
+			DryRun:    c.cfg.DryRun,
+			Reason:    "noisy neighbor episode started",
+		}
+
+		current, err := readControl(dir, controlFile(action))
+		if err != nil {
+			c.record(record, err)
+			continue
+		}
+		record.OldValue = current
+
+		target, err := targetValue(action, current, c.cfg)
+		if err != nil || target == "" {
+			if err != nil {
+				c.record(record, err)
+			}
+			continue
+		}
+		record.NewValue = target
+
+		// 写入 cgroupfs 前先持久化，写入后异常退出时仍能在下次启动回滚；
+		// 未写入成功的变更在回滚时因当前值不等于目标值而跳过
+		m.Changes = append(m.Changes, Change{Action: action, OldValue: current, NewValue: target})
+		c.active[aggressor.CgroupID] = m
+		c.saveState()
+
+		if !c.cfg.DryRun {
+			if err := writeControl(dir, controlFile(action), target); err != nil {
+				c.record(record, err)
+				m.Changes = m.Changes[:len(m.Changes)-1]
+				continue
+			}
+		}
+
+		c.record(record, nil)
+	}
+
+	if len(m.Changes) == 0 {
+		delete(c.active, aggressor.CgroupID)
+		c.saveState()
+		return
+	}
+
+	c.metrics.active.Set(float64(len(c.active)))
+}
+
+// isPodCgroup 判断 cgroup 是否位于 kubepods 层级下且属于指定的 Pod
+func isPodCgroup(cgroup, podUID string) bool {
+	if !strings.HasPrefix(strings.TrimPrefix(cgroup, "/"), "kubepods") {
+		return false
+	}
+
+	return enricher.PodUIDFromPath(cgroup) == podUID
+}
+
+// release 移除事件对缓解的引用，无引用时回滚；调用方需持有锁
+func (c *Controller) release(cgroupID uint64, episodeID, reason string) {
+	m, ok := c.active[cgroupID]
+	if !ok {
+		return
+	}
+
+	episodes := m.Episodes[:0]
+	for _, id := range m.Episodes {
+		if id != episodeID {
+			episodes = append(episodes, id)
+		}
+	}
+	m.Episodes = episodes
+	if len(m.Episodes) > 0 {
+		return
+	}
+
+	c.revert(m, episodeID, reason)
+}
+
+// revert 逆序回滚缓解；调用方需持有锁
+func (c *Controller) revert(m *Mitigation, episodeID, reason string) {
+	delete(c.active, m.CgroupID)
+	c.metrics.active.Set(float64(len(c.active)))
+	defer c.saveState()
+
+	dir := filepath.Join(c.cfg.CgroupRoot, m.Cgroup)
+	for i := len(m.Changes) - 1; i >= 0; i-- {
+		change := m.Changes[i]
+		record := AuditRecord{
+			Operation: OperationRevert,
+			Action:    change.Action,
+			EpisodeID: episodeID,
+			Aggressor: m.Aggressor,
+			Cgroup:    m.Cgroup,
+			OldValue:  change.NewValue,
+			NewValue:  change.OldValue,
+			DryRun:    m.DryRun,
+			Reason:    reason,
+		}
+
+		if m.DryRun {
+			c.record(record, nil)
+			continue
+		}
+
+		// 缓解期间被其他组件修改过的值不再覆盖
+		current, err := readControl(dir, controlFile(change.Action))
+		if err != nil {
+			c.record(record, err)
+			continue
+		}
+		if current != change.NewValue {
+			record.OldValue = current
+			record.Reason = reason + ", skipped: value modified externally"
+			c.record(record, nil)
+			continue
+		}
+
+		c.record(record, writeControl(dir, controlFile(change.Action), change.OldValue))
+	}
+}
+
+func (c *Controller) expire(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, m := range c.active {
+		if now.Sub(m.StartedAt) >= c.cfg.MaxDuration {
+			c.revert(m, "", "max duration exceeded")
+		}
+	}
+}
+
+func (c *Controller) revertAll(reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, m := range c.active {
+		c.revert(m, "", reason)
+	}
+}
+
+func (c *Controller) record(record AuditRecord, err error) {
+	record.Time = time.Now()
+	record.Node = c.node
+
+	result := "success"
+	switch {
+	case err != nil:
+		record.Error = err.Error()
+		result = "failed"
+	case record.DryRun:
+		result = "dry_run"
+	}
+
+	c.audit.Record(record)
+	c.metrics.actions.WithLabelValues(string(record.Action), string(record.Operation), result).Inc()
+}
+
+// Mitigations 返回生效中的缓解，按开始时间倒序
+func (c *Controller) Mitigations() []Mitigation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	mitigations := make([]Mitigation, 0, len(c.active))
+	for _, m := range c.active {
+		copied := *m
+		copied.Episodes = append([]string{}, m.Episodes...)
+		copied.Changes = append([]Change{}, m.Changes...)
+		mitigations = append(mitigations, copied)
+	}
+
+	sort.Slice(mitigations, func(i, j int) bool { return mitigations[i].StartedAt.After(mitigations[j].StartedAt) })
+	return mitigations
+}
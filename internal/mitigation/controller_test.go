@@ -0,0 +1,121 @@
+package mitigation
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/cen-ngc5139/shepherd/internal/analysis"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/enricher"
+)
+
+const testPodUID = "0b8f3c7e-5d2a-4c1b-9e6f-1a2b3c4d5e6f"
+
+// testMetrics 指标注册到全局 registry，只能创建一次
+var testMetrics = newMetrics()
+
+func newTestController(t *testing.T, root, stateFile string) *Controller {
+	t.Helper()
+
+	return &Controller{
+		cfg: config.MitigationConfig{
+			Actions:     []config.MitigationAction{config.MitigationCPUWeight},
+			MaxActive:   defaultMaxActive,
+			MaxDuration: defaultMaxDuration,
+			CgroupRoot:  root,
+			StateFile:   stateFile,
+		},
+		cgroups:  enricher.NewCgroupResolver(root),
+		audit:    &auditLogger{},
+		metrics:  testMetrics,
+		excluded: map[string]struct{}{},
+		active:   make(map[uint64]*Mitigation),
+	}
+}
+
+// mkCgroup 创建带 cpu.weight 的 cgroup 目录并返回其 id
+func mkCgroup(t *testing.T, root, rel string) uint64 {
+	t.Helper()
+
+	dir := filepath.Join(root, rel)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("failed to create %s: %v", dir, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, cpuWeightFile), []byte("100\n"), 0o644); err != nil {
+		t.Fatalf("failed to write cpu.weight: %v", err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatalf("failed to stat %s: %v", dir, err)
+	}
+
+	return info.Sys().(*syscall.Stat_t).Ino
+}
+
+func weight(t *testing.T, root, rel string) string {
+	t.Helper()
+
+	value, err := readControl(filepath.Join(root, rel), cpuWeightFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func episode(id string, cgroupID uint64, podUID string) analysis.Episode {
+	return analysis.Episode{
+		ID:        id,
+		State:     analysis.EpisodeActive,
+		Aggressor: analysis.Entity{CgroupID: cgroupID, Pod: "noisy", Namespace: "default", PodUID: podUID},
+	}
+}
+
+func TestApplySkipsHostCgroups(t *testing.T) {
+	root := t.TempDir()
+	system := filepath.Join("system.slice", "containerd.service")
+	systemID := mkCgroup(t, root, system)
+	c := newTestController(t, root, filepath.Join(t.TempDir(), "state.json"))
+
+	// 没有 Pod UID 的抢占者不做限制
+	c.OnEpisode(episode("host", systemID, ""))
+	// Pod UID 与 cgroup 不一致时同样不做限制
+	c.OnEpisode(episode("mismatch", systemID, testPodUID))
+
+	if len(c.Mitigations()) != 0 {
+		t.Errorf("host cgroup mitigated: %+v", c.Mitigations())
+	}
+	if got := weight(t, root, system); got != "100" {
+		t.Errorf("cpu.weight of %s = %s", system, got)
+	}
+}
+
+func TestStateRevertedOnRestart(t *testing.T) {
+	root := t.TempDir()
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	pod := filepath.Join("kubepods.slice", "kubepods-burstable.slice",
+		"kubepods-burstable-pod0b8f3c7e_5d2a_4c1b_9e6f_1a2b3c4d5e6f.slice")
+	podID := mkCgroup(t, root, pod)
+
+	c := newTestController(t, root, stateFile)
+	c.OnEpisode(episode("noisy", podID, testPodUID))
+	if got := weight(t, root, pod); got != "25" {
+		t.Fatalf("cpu.weight = %s, want 25", got)
+	}
+
+	saved, err := readState(stateFile)
+	if err != nil || len(saved) != 1 || saved[0].CgroupID != podID {
+		t.Fatalf("unexpected state %+v: %v", saved, err)
+	}
+
+	// 模拟 agent 崩溃后重启，不经过 revertAll
+	restarted := newTestController(t, root, stateFile)
+	restarted.restore()
+	if got := weight(t, root, pod); got != "100" {
+		t.Errorf("cpu.weight after restart = %s, want 100", got)
+	}
+	if saved, err := readState(stateFile); err != nil || len(saved) != 0 {
+		t.Errorf("state not cleared after restore: %+v: %v", saved, err)
+	}
+}
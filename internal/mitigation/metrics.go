@@ -0,0 +1,29 @@
+package mitigation
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	MitigationActions = "mitigation_actions_total"
+	MitigationActive  = "mitigation_active"
+)
+
+type metrics struct {
+	actions *prometheus.CounterVec
+	active  prometheus.Gauge
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		actions: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: MitigationActions,
+			Help: "cgroup mitigation actions applied or reverted",
+		}, []string{"action", "operation", "result"}),
+		active: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: MitigationActive,
+			Help: "aggressor cgroups currently mitigated",
+		}),
+	}
+}
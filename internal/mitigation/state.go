@@ -0,0 +1,87 @@
+package mitigation
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/pkg/errors"
+)
+
+// DefaultStateFile 默认的缓解状态文件，需要位于宿主机目录才能在 Pod 重建后保留
+const DefaultStateFile = "/var/lib/shepherd/mitigations.json"
+
+// saveState 持久化生效中的缓解，agent 异常退出后由下次启动时回滚；调用方需持有锁
+func (c *Controller) saveState() {
+	// dry run 不修改 cgroupfs，无需回滚
+	if c.cfg.DryRun {
+		return
+	}
+
+	mitigations := make([]*Mitigation, 0, len(c.active))
+	for _, m := range c.active {
+		mitigations = append(mitigations, m)
+	}
+
+	if err := writeState(c.cfg.StateFile, mitigations); err != nil {
+		log.Warningf("failed to save mitigation state: %v", err)
+	}
+}
+
+// restore 回滚上次运行遗留的缓解，事件状态无法恢复，因此不再继续保持
+func (c *Controller) restore() {
+	mitigations, err := readState(c.cfg.StateFile)
+	if err != nil {
+		log.Warningf("failed to load mitigation state: %v", err)
+		return
+	}
+	if len(mitigations) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	log.Infof("reverting %d mitigations left by previous run", len(mitigations))
+	for _, m := range mitigations {
+		c.revert(m, "", "left by previous run")
+	}
+	c.saveState()
+}
+
+func readState(path string) ([]*Mitigation, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", path)
+	}
+
+	var mitigations []*Mitigation
+	if err := json.Unmarshal(data, &mitigations); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+
+	return mitigations, nil
+}
+
+// writeState 先写入临时文件再 rename，避免异常退出时留下不完整的状态
+func writeState(path string, mitigations []*Mitigation) error {
+	data, err := json.Marshal(mitigations)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal mitigations")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return errors.Wrapf(err, "failed to create %s", filepath.Dir(path))
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return errors.Wrapf(err, "failed to write %s", tmp)
+	}
+
+	return errors.Wrapf(os.Rename(tmp, path), "failed to rename %s", tmp)
+}
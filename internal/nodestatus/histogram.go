@@ -0,0 +1,47 @@
+package nodestatus
+
+import (
+	"math"
+
+	"github.com/cen-ngc5139/shepherd/internal/aggregate"
+)
+
+// histogram 累计内核态 log2 直方图的增量，用于计算未经采样与阈值过滤的分位数
+type histogram struct {
+	slots [aggregate.HistSlots]uint64
+	total uint64
+}
+
+func (h *histogram) merge(delta aggregate.Record) {
+	for i, c := range delta.Buckets {
+		h.slots[i] += c
+	}
+	h.total += delta.Count
+}
+
+// quantile 返回分位数的近似值，槽位跨度为 2 倍，在槽位内按线性插值
+func (h *histogram) quantile(q float64) uint64 {
+	if h.total == 0 {
+		return 0
+	}
+
+	rank := math.Ceil(q * float64(h.total))
+	var cum float64
+	for i, c := range h.slots {
+		if c == 0 {
+			continue
+		}
+		if cum+float64(c) >= rank {
+			lower, upper := aggregate.SlotBoundsNs(i)
+			return lower + uint64(float64(upper-lower)*(rank-cum)/float64(c))
+		}
+		cum += float64(c)
+	}
+
+	_, upper := aggregate.SlotBoundsNs(aggregate.HistSlots - 1)
+	return upper
+}
+
+func (h *histogram) reset() {
+	*h = histogram{}
+}
@@ -0,0 +1,263 @@
+package nodestatus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/aggregate"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cen-ngc5139/shepherd/pkg/client"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// AnnotationContention 节点处于 CPU 争用状态时写入的注解，值为 JSON 格式的详情
+	AnnotationContention = "shepherd.io/cpu-contention"
+	TaintContention      = "shepherd.io/cpu-contention"
+	ConditionContention  = corev1.NodeConditionType("CPUContention")
+
+	defaultThreshold  = 10 * time.Millisecond
+	defaultFor        = 5 * time.Minute
+	defaultInterval   = time.Minute
+	defaultMinSamples = 100
+	defaultRecoverPct = 0.8
+)
+
+// contention 写入注解的争用详情
+type contention struct {
+	Since     time.Time `json:"since"`
+	P99       string    `json:"p99"`
+	Threshold string    `json:"threshold"`
+}
+
+// Reporter 基于内核态直方图统计节点调度延迟 p99，持续超过阈值时标记节点，持续恢复后移除标记
+type Reporter struct {
+	cfg      config.NodeStatusConfig
+	nodeName string
+	client   kubernetes.Interface
+
+	mu   sync.Mutex
+	hist histogram
+
+	// 以下字段仅在 Start 所在 goroutine 中访问
+	contended bool
+	since     time.Time // 当前连续超过阈值或连续恢复的起始时间
+	lastP99   time.Duration
+}
+
+func NewReporter(cfg config.NodeStatusConfig, nodeName string) (*Reporter, error) {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = defaultThreshold
+	}
+	if cfg.RecoverThreshold <= 0 || cfg.RecoverThreshold > cfg.Threshold {
+		cfg.RecoverThreshold = time.Duration(float64(cfg.Threshold) * defaultRecoverPct)
+	}
+	if cfg.For <= 0 {
+		cfg.For = defaultFor
+	}
+	if cfg.RecoverFor <= 0 {
+		cfg.RecoverFor = cfg.For
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.MinSamples <= 0 {
+		cfg.MinSamples = defaultMinSamples
+	}
+	if cfg.Taint.Effect == "" {
+		cfg.Taint.Effect = string(corev1.TaintEffectPreferNoSchedule)
+	}
+
+	m := client.NewK8sManager()
+	if err := m.CreateClient(); err != nil {
+		return nil, errors.Wrap(err, "failed to create k8s client")
+	}
+
+	return &Reporter{
+		cfg:      cfg,
+		nodeName: nodeName,
+		client:   m.GetK8sClientSet(),
+	}, nil
+}
+
+// OnHistogram 累计一个聚合周期内全节点的调度延迟分布，不受事件采样与阈值影响
+func (r *Reporter) OnHistogram(delta aggregate.Record) {
+	r.mu.Lock()
+	r.hist.merge(delta)
+	r.mu.Unlock()
+}
+
+// Start 周期计算 p99 并更新节点标记，阻塞直至 ctx 结束
+func (r *Reporter) Start(ctx context.Context) error {
+	// 沿用上次运行留下的标记，避免重启后重复标记或遗留标记
+	node, err := r.client.CoreV1().Nodes().Get(ctx, r.nodeName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get node %s", r.nodeName)
+	}
+	_, r.contended = node.Annotations[AnnotationContention]
+
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			if err := r.evaluate(ctx, now); err != nil {
+				log.Warningf("failed to update node contention status: %v", err)
+			}
+		}
+	}
+}
+
+func (r *Reporter) evaluate(ctx context.Context, now time.Time) error {
+	r.mu.Lock()
+	total := r.hist.total
+	p99 := time.Duration(r.hist.quantile(0.99))
+	r.hist.reset()
+	r.mu.Unlock()
+
+	// 样本过少时 p99 没有代表性，按未超过阈值处理
+	if total < uint64(r.cfg.MinSamples) {
+		p99 = 0
+	}
+	r.lastP99 = p99
+
+	// 超过阈值与恢复使用不同的阈值和持续时间，避免节点标记来回切换
+	var pending bool
+	if r.contended {
+		pending = p99 < r.cfg.RecoverThreshold
+	} else {
+		pending = p99 > r.cfg.Threshold
+	}
+
+	if !pending {
+		r.since = time.Time{}
+		return nil
+	}
+	if r.since.IsZero() {
+		r.since = now.Add(-r.cfg.Interval)
+	}
+
+	hold := r.cfg.For
+	if r.contended {
+		hold = r.cfg.RecoverFor
+	}
+	if now.Sub(r.since) < hold {
+		return nil
+	}
+
+	if err := r.apply(ctx, !r.contended, r.since); err != nil {
+		return err
+	}
+
+	r.contended = !r.contended
+	r.since = time.Time{}
+	log.Infof("node %s cpu contention set to %t, p99 %s", r.nodeName, r.contended, p99)
+	return nil
+}
+
+// apply 写入或移除节点注解、污点与 Condition
+func (r *Reporter) apply(ctx context.Context, contended bool, since time.Time) error {
+	nodes := r.client.CoreV1().Nodes()
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := nodes.Get(ctx, r.nodeName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if contended {
+			raw, err := json.Marshal(contention{
+				Since:     since,
+				P99:       r.lastP99.String(),
+				Threshold: r.cfg.Threshold.String(),
+			})
+			if err != nil {
+				return err
+			}
+			if node.Annotations == nil {
+				node.Annotations = make(map[string]string)
+			}
+			node.Annotations[AnnotationContention] = string(raw)
+		} else {
+			delete(node.Annotations, AnnotationContention)
+		}
+
+		taints := make([]corev1.Taint, 0, len(node.Spec.Taints)+1)
+		for _, t := range node.Spec.Taints {
+			if t.Key != TaintContention {
+				taints = append(taints, t)
+			}
+		}
+		if contended && r.cfg.Taint.Enable {
+			now := metav1.Now()
+			taints = append(taints, corev1.Taint{
+				Key:       TaintContention,
+				Value:     "true",
+				Effect:    corev1.TaintEffect(r.cfg.Taint.Effect),
+				TimeAdded: &now,
+			})
+		}
+		node.Spec.Taints = taints
+
+		_, err = nodes.Update(ctx, node, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to update node %s", r.nodeName)
+	}
+
+	if !r.cfg.Condition {
+		return nil
+	}
+
+	return r.setCondition(ctx, contended)
+}
+
+func (r *Reporter) setCondition(ctx context.Context, contended bool) error {
+	status := corev1.ConditionFalse
+	reason := "SchedulingLatencyNormal"
+	message := fmt.Sprintf("scheduling latency p99 below %s", r.cfg.RecoverThreshold)
+	if contended {
+		status = corev1.ConditionTrue
+		reason = "SchedulingLatencyHigh"
+		message = fmt.Sprintf("scheduling latency p99 %s above %s for %s", r.lastP99, r.cfg.Threshold, r.cfg.For)
+	}
+
+	now := metav1.Now()
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []corev1.NodeCondition{{
+				Type:               ConditionContention,
+				Status:             status,
+				LastHeartbeatTime:  now,
+				LastTransitionTime: now,
+				Reason:             reason,
+				Message:            message,
+			}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// conditions 以 type 为合并键，strategic merge patch 只会替换本 Condition
+	_, err = r.client.CoreV1().Nodes().Patch(ctx, r.nodeName, types.StrategicMergePatchType, patch,
+		metav1.PatchOptions{}, "status")
+	if err != nil {
+		return errors.Wrapf(err, "failed to patch condition of node %s", r.nodeName)
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package nodestatus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/aggregate"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testNodeName = "node-a"
+
+// delta 构造一个聚合周期的直方图增量，延迟单位为微秒
+func delta(counts map[int]uint64) aggregate.Record {
+	var r aggregate.Record
+	for slot, c := range counts {
+		r.Buckets[slot] = c
+		r.Count += c
+	}
+	return r
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	var h histogram
+	// 990 次落在 [64us, 128us)，10 次落在 [16ms, 32ms)
+	h.merge(delta(map[int]uint64{6: 990, 14: 10}))
+
+	if p50 := time.Duration(h.quantile(0.5)); p50 < 64*time.Microsecond || p50 > 128*time.Microsecond {
+		t.Errorf("p50 = %s", p50)
+	}
+	if p99 := time.Duration(h.quantile(0.99)); p99 < 64*time.Microsecond || p99 > 128*time.Microsecond {
+		t.Errorf("p99 = %s", p99)
+	}
+	if p999 := time.Duration(h.quantile(0.999)); p999 < 16*time.Millisecond || p999 > 32*time.Millisecond {
+		t.Errorf("p99.9 = %s", p999)
+	}
+
+	h.reset()
+	if h.quantile(0.99) != 0 {
+		t.Errorf("quantile of empty histogram is not 0")
+	}
+}
+
+func TestEvaluateFromKernelHistogram(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: testNodeName}})
+	r := &Reporter{
+		cfg: config.NodeStatusConfig{
+			Threshold:        10 * time.Millisecond,
+			RecoverThreshold: 8 * time.Millisecond,
+			For:              2 * time.Minute,
+			RecoverFor:       2 * time.Minute,
+			Interval:         time.Minute,
+			MinSamples:       100,
+		},
+		nodeName: testNodeName,
+		client:   client,
+	}
+
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	annotated := func() bool {
+		node, err := client.CoreV1().Nodes().Get(ctx, testNodeName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, ok := node.Annotations[AnnotationContention]
+		return ok
+	}
+
+	// 超过 1% 的调度落在 [16ms, 32ms)，即使内核只上报了其中少量事件
+	for i := 0; i < 3; i++ {
+		r.OnHistogram(delta(map[int]uint64{6: 900, 14: 100}))
+		now = now.Add(time.Minute)
+		if err := r.evaluate(ctx, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !r.contended || !annotated() {
+		t.Fatalf("node not marked, p99 %s", r.lastP99)
+	}
+
+	// 恢复后持续 RecoverFor 才移除标记
+	for i := 0; i < 3; i++ {
+		r.OnHistogram(delta(map[int]uint64{6: 1000}))
+		now = now.Add(time.Minute)
+		if err := r.evaluate(ctx, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r.contended || annotated() {
+		t.Fatalf("node still marked, p99 %s", r.lastP99)
+	}
+
+	// 样本不足时按未超过阈值处理
+	r.OnHistogram(delta(map[int]uint64{14: 10}))
+	now = now.Add(time.Minute)
+	if err := r.evaluate(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+	if r.lastP99 != 0 {
+		t.Errorf("p99 with too few samples = %s", r.lastP99)
+	}
+}
@@ -6,14 +6,32 @@ import (
 
 	"github.com/ClickHouse/clickhouse-go/v2"
 	ckdriver "github.com/ClickHouse/clickhouse-go/v2/lib/driver"
-	"github.com/cen-ngc5139/shepherd/internal/binary"
 	"github.com/cen-ngc5139/shepherd/internal/config"
 	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cen-ngc5139/shepherd/internal/metadata"
 	"github.com/cen-ngc5139/shepherd/pkg/client"
 	"github.com/cen-ngc5139/shepherd/pkg/kafka"
 	"github.com/pkg/errors"
 )
 
+const insertSchedLatencySQL = `
+	INSERT INTO sched_latency (
+		pid, tid, delay_ns, ts,
+		preempted_pid, preempted_comm,
+		is_preempt, comm,
+		preempted_pid_state,
+		cgroup_id, preempted_cgroup_id,
+		pod, namespace,
+		preempted_pod, preempted_namespace,
+		cpu, prev_cpu, prio, nice, policy, sched_class,
+		stack, preempted_stack,
+		latency_type,
+		waker_pid, waker_tid, waker_comm, waker_cpu, waker_context,
+		idle_cpus, migrations,
+		hardirq_ns, softirq_ns
+	)
+`
+
 type SinkCli struct {
 	CKCli    CKCli
 	KafkaCli *kafka.Producer
@@ -54,14 +72,7 @@ func (o *Output) InitSinkCli(cfg config.OutputConfig) (err error) {
 			return errors.Wrap(err, "failed to init clickhouse client")
 		}
 
-		o.SinkCli.CKCli.batch, err = conn.PrepareBatch(o.ctx, `
-			INSERT INTO sched_latency (
-				pid, tid, delay_ns, ts, 
-				preempted_pid, preempted_comm, 
-				is_preempt, comm,
-				preempted_pid_state
-			)
-		`)
+		o.SinkCli.CKCli.batch, err = conn.PrepareBatch(o.ctx, insertSchedLatencySQL)
 		if err != nil {
 			return errors.Wrap(err, "failed to prepare batch")
 		}
@@ -79,7 +90,7 @@ func (o *Output) InitSinkCli(cfg config.OutputConfig) (err error) {
 	return nil
 }
 
-func (o *Output) Push(event binary.ShepherdSchedLatencyT) error {
+func (o *Output) Push(event metadata.SchedEvent) error {
 	if o.SinkType == config.OutputTypeClickhouse {
 		batch, count, err := insertSchedMetrics(o.ctx, o.SinkCli.CKCli.conn, o.SinkCli.CKCli.batch, event, o.SinkCli.CKCli.counter)
 		if err != nil {
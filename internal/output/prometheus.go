@@ -13,9 +13,17 @@ import (
 )
 
 const (
-	SchedLatencies = "sched_latencies"
-	SchedPreempted = "sched_preempted"
-	SchedPreempte  = "sched_preempte"
+	SchedLatencies          = "sched_latencies"
+	SchedPreempted          = "sched_preempted"
+	SchedPreempte           = "sched_preempte"
+	SchedCPULatencies       = "sched_cpu_latencies"
+	SchedCPUEvents          = "sched_cpu_events"
+	SchedWakerLatencies     = "sched_waker_latencies"
+	SchedWakerEvents        = "sched_waker_events"
+	SchedImbalanceLatencies = "sched_imbalance_latencies"
+	SchedImbalanceEvents    = "sched_imbalance_events"
+	SchedWaitMigrations     = "sched_wait_migrations"
+	SchedNodeImbalanceRatio = "sched_node_imbalance_ratio"
 )
 
 type TraceMetrics struct {
@@ -29,11 +37,22 @@ func NewTraceMetrics(schedMetrics *SchedMetrics) *TraceMetrics {
 }
 
 type SchedMetrics struct {
-	SchedLatencies    *prometheus.GaugeVec // 调度延迟
-	SchedPreempted    *prometheus.GaugeVec // 被抢占的进程
-	SchedPreempte     *prometheus.GaugeVec // 抢占的进程
-	SchedMetricsMap   *sync.Map
-	SchedPreemptedMap *sync.Map
+	SchedLatencies          *prometheus.GaugeVec // 调度延迟
+	SchedPreempted          *prometheus.GaugeVec // 被抢占的进程
+	SchedPreempte           *prometheus.GaugeVec // 抢占的进程
+	SchedCPULatencies       *prometheus.GaugeVec // 按 CPU 与调度类累计的调度延迟
+	SchedCPUEvents          *prometheus.GaugeVec // 按 CPU 与调度类累计的事件数
+	SchedWakerLatencies     *prometheus.GaugeVec // 按唤醒方累计的调度延迟
+	SchedWakerEvents        *prometheus.GaugeVec // 按唤醒方累计的事件数
+	SchedImbalanceLatencies *prometheus.GaugeVec // 按 CPU 累计的存在空闲 CPU 时的调度延迟
+	SchedImbalanceEvents    *prometheus.GaugeVec // 按 CPU 累计的存在空闲 CPU 时的事件数
+	SchedWaitMigrations     *prometheus.GaugeVec // 按 CPU 累计的等待期间迁移次数
+	SchedNodeImbalanceRatio prometheus.Gauge     // 节点存在空闲 CPU 时的调度延迟占全部调度延迟的比例
+	SchedMetricsMap         *sync.Map
+	SchedPreemptedMap       *sync.Map
+	SchedCPUMap             *sync.Map
+	SchedWakerMap           *sync.Map
+	SchedImbalanceMap       *sync.Map
 }
 
 func createGaugeVec(name, help string, labels []string) *prometheus.GaugeVec {
@@ -46,29 +65,77 @@ func createGaugeVec(name, help string, labels []string) *prometheus.GaugeVec {
 	)
 }
 
-func NewSchedMetrics(schedMetricsMap, schedPreemptedMap *sync.Map) *SchedMetrics {
+func NewSchedMetrics(schedMetricsMap, schedPreemptedMap, schedCPUMap, schedWakerMap, schedImbalanceMap *sync.Map) *SchedMetrics {
 	return &SchedMetrics{
-		SchedLatencies:    createGaugeVec(SchedLatencies, "task cpu scheduling latency", []string{"pid", "comm"}),
-		SchedPreempted:    createGaugeVec(SchedPreempted, "task cpu preempted", []string{"pid", "comm"}),
-		SchedPreempte:     createGaugeVec(SchedPreempte, "task cpu preempted", []string{"pid", "comm"}),
+		SchedLatencies:          createGaugeVec(SchedLatencies, "task cpu scheduling latency", []string{"pid", "comm", "pod", "namespace"}),
+		SchedPreempted:          createGaugeVec(SchedPreempted, "task cpu preempted", []string{"pid", "comm", "pod", "namespace"}),
+		SchedPreempte:           createGaugeVec(SchedPreempte, "task cpu preempted", []string{"pid", "comm", "pod", "namespace"}),
+		SchedCPULatencies:       createGaugeVec(SchedCPULatencies, "task cpu scheduling latency by cpu and sched class", []string{"cpu", "sched_class"}),
+		SchedCPUEvents:          createGaugeVec(SchedCPUEvents, "task cpu scheduling events by cpu and sched class", []string{"cpu", "sched_class"}),
+		SchedWakerLatencies:     createGaugeVec(SchedWakerLatencies, "task cpu scheduling latency by waker comm and context", []string{"waker_comm", "waker_context"}),
+		SchedWakerEvents:        createGaugeVec(SchedWakerEvents, "task cpu scheduling events by waker comm and context", []string{"waker_comm", "waker_context"}),
+		SchedImbalanceLatencies: createGaugeVec(SchedImbalanceLatencies, "task cpu scheduling latency while allowed cpus were idle", []string{"cpu"}),
+		SchedImbalanceEvents:    createGaugeVec(SchedImbalanceEvents, "task cpu scheduling events while allowed cpus were idle", []string{"cpu"}),
+		SchedWaitMigrations:     createGaugeVec(SchedWaitMigrations, "task migrations while waiting on run queue", []string{"cpu"}),
+		SchedNodeImbalanceRatio: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: SchedNodeImbalanceRatio,
+			Help: "ratio of scheduling latency spent while allowed cpus were idle",
+		}),
 		SchedMetricsMap:   schedMetricsMap,
 		SchedPreemptedMap: schedPreemptedMap,
+		SchedCPUMap:       schedCPUMap,
+		SchedWakerMap:     schedWakerMap,
+		SchedImbalanceMap: schedImbalanceMap,
 	}
 }
 
 func (m *SchedMetrics) UpdateMetricsFromCache(nodeName string) {
 	m.SchedMetricsMap.Range(func(key, value interface{}) bool {
 		schedMetrics := value.(metadata.SchedMetrics)
-		m.SchedLatencies.WithLabelValues(fmt.Sprintf("%d", schedMetrics.Pid), schedMetrics.Comm).Set(float64(schedMetrics.DelayNs))
+		m.SchedLatencies.WithLabelValues(fmt.Sprintf("%d", schedMetrics.Pid), schedMetrics.Comm,
+			schedMetrics.Pod, schedMetrics.Namespace).Set(float64(schedMetrics.DelayNs))
 		if schedMetrics.PreempteCount > 0 {
-			m.SchedPreempte.WithLabelValues(fmt.Sprintf("%d", schedMetrics.Pid), schedMetrics.Comm).Set(float64(schedMetrics.PreempteCount))
+			m.SchedPreempte.WithLabelValues(fmt.Sprintf("%d", schedMetrics.Pid), schedMetrics.Comm,
+				schedMetrics.Pod, schedMetrics.Namespace).Set(float64(schedMetrics.PreempteCount))
 		}
 		return true
 	})
 
 	m.SchedPreemptedMap.Range(func(key, value interface{}) bool {
 		schedPreempted := value.(metadata.SchedPreempted)
-		m.SchedPreempted.WithLabelValues(fmt.Sprintf("%d", schedPreempted.Pid), schedPreempted.Comm).Set(float64(schedPreempted.Count))
+		m.SchedPreempted.WithLabelValues(fmt.Sprintf("%d", schedPreempted.Pid), schedPreempted.Comm,
+			schedPreempted.Pod, schedPreempted.Namespace).Set(float64(schedPreempted.Count))
+		return true
+	})
+
+	var totalDelayNs, imbalanceDelayNs uint64
+	m.SchedCPUMap.Range(func(key, value interface{}) bool {
+		cpuMetrics := value.(metadata.SchedCPUMetrics)
+		totalDelayNs += cpuMetrics.DelayNs
+		m.SchedCPULatencies.WithLabelValues(fmt.Sprintf("%d", cpuMetrics.Cpu), cpuMetrics.SchedClass).
+			Set(float64(cpuMetrics.DelayNs))
+		m.SchedCPUEvents.WithLabelValues(fmt.Sprintf("%d", cpuMetrics.Cpu), cpuMetrics.SchedClass).
+			Set(float64(cpuMetrics.Count))
+		return true
+	})
+
+	m.SchedImbalanceMap.Range(func(key, value interface{}) bool {
+		imbalance := value.(metadata.SchedImbalanceMetrics)
+		cpu := fmt.Sprintf("%d", imbalance.Cpu)
+		imbalanceDelayNs += imbalance.DelayNs
+		m.SchedImbalanceLatencies.WithLabelValues(cpu).Set(float64(imbalance.DelayNs))
+		m.SchedImbalanceEvents.WithLabelValues(cpu).Set(float64(imbalance.Count))
+		m.SchedWaitMigrations.WithLabelValues(cpu).Set(float64(imbalance.Migrations))
+		return true
+	})
+	if totalDelayNs > 0 {
+		m.SchedNodeImbalanceRatio.Set(float64(imbalanceDelayNs) / float64(totalDelayNs))
+	}
+
+	m.SchedWakerMap.Range(func(key, value interface{}) bool {
+		wakerMetrics := value.(metadata.SchedWakerMetrics)
+		m.SchedWakerLatencies.WithLabelValues(wakerMetrics.Comm, wakerMetrics.Context).Set(float64(wakerMetrics.DelayNs))
+		m.SchedWakerEvents.WithLabelValues(wakerMetrics.Comm, wakerMetrics.Context).Set(float64(wakerMetrics.Count))
 		return true
 	})
 }
@@ -0,0 +1,55 @@
+package output
+
+import (
+	"os"
+
+	"github.com/cen-ngc5139/shepherd/internal/bpf"
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/perf"
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/pkg/errors"
+)
+
+// eventReader 从 perf event 或 ring buffer 读取调度事件
+type eventReader interface {
+	Read(data interface{}) error
+	Close() error
+}
+
+type perfEventReader struct {
+	*perf.Reader
+}
+
+func (r perfEventReader) Read(data interface{}) error {
+	return parseEvent(r.Reader, data)
+}
+
+type ringbufEventReader struct {
+	*ringbuf.Reader
+}
+
+func (r ringbufEventReader) Read(data interface{}) error {
+	record, err := r.Reader.Read()
+	if err != nil {
+		return err
+	}
+
+	return parseRingbufEvent(&record, data)
+}
+
+// newEventReader 加载时未选用 ring buffer 的情况下该 map 被替换为数组，据此选择读取方式
+func newEventReader(coll *ebpf.Collection) (eventReader, error) {
+	if rb, ok := coll.Maps[bpf.SchedEventsRingbufMap]; ok && rb.Type() == ebpf.RingBuf {
+		rd, err := ringbuf.NewReader(rb)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create ringbuf reader")
+		}
+		return ringbufEventReader{rd}, nil
+	}
+
+	rd, err := perf.NewReader(coll.Maps[bpf.SchedEventsMap], os.Getpagesize())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create perf reader")
+	}
+	return perfEventReader{rd}, nil
+}
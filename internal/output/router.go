@@ -0,0 +1,99 @@
+package output
+
+import (
+	"context"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cen-ngc5139/shepherd/internal/metadata"
+	"github.com/cen-ngc5139/shepherd/internal/policy"
+)
+
+const (
+	outputRetryMin = time.Second
+	outputRetryMax = 5 * time.Minute
+)
+
+// Router 按 ShepherdPolicy 为事件选择输出目标，非默认输出按需创建
+type Router struct {
+	cfg      config.Configuration
+	ctx      context.Context
+	policies *policy.Store
+	outputs  map[config.OutputType]*Output
+	failures map[config.OutputType]*outputFailure
+}
+
+// outputFailure 创建失败的输出在退避期间不再重试
+type outputFailure struct {
+	retryAt time.Time
+	backoff time.Duration
+}
+
+func NewRouter(cfg config.Configuration, ctx context.Context, policies *policy.Store) (*Router, error) {
+	o, err := NewOutput(cfg, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Router{
+		cfg:      cfg,
+		ctx:      ctx,
+		policies: policies,
+		outputs:  map[config.OutputType]*Output{cfg.Output.Type: o},
+		failures: make(map[config.OutputType]*outputFailure),
+	}, nil
+}
+
+func (r *Router) Push(event metadata.SchedEvent) error {
+	sinkType := r.policies.OutputFor(event.Pod)
+	if sinkType == "" {
+		sinkType = r.cfg.Output.Type
+	}
+
+	o, ok := r.outputs[sinkType]
+	if !ok {
+		o = r.open(sinkType)
+	}
+	// 策略要求的输出不可用时写入默认输出，避免丢失事件
+	if o == nil {
+		o = r.outputs[r.cfg.Output.Type]
+	}
+
+	return o.Push(event)
+}
+
+// open 创建策略要求的输出，失败后按指数退避重试，退避期间返回 nil
+func (r *Router) open(sinkType config.OutputType) *Output {
+	failure := r.failures[sinkType]
+	if failure != nil && time.Now().Before(failure.retryAt) {
+		return nil
+	}
+
+	cfg := r.cfg
+	cfg.Output.Type = sinkType
+	o, err := NewOutput(cfg, r.ctx)
+	if err == nil {
+		delete(r.failures, sinkType)
+		r.outputs[sinkType] = o
+		return o
+	}
+
+	if failure == nil {
+		failure = &outputFailure{backoff: outputRetryMin}
+		r.failures[sinkType] = failure
+	} else {
+		failure.backoff = min(failure.backoff*2, outputRetryMax)
+	}
+	failure.retryAt = time.Now().Add(failure.backoff)
+	log.Warningf("failed to init output %s required by policy, falling back to %s, retry in %s: %v",
+		sinkType, r.cfg.Output.Type, failure.backoff, err)
+
+	return nil
+}
+
+func (r *Router) Close() {
+	for _, o := range r.outputs {
+		o.Close()
+	}
+}
@@ -2,30 +2,36 @@ package output
 
 import (
 	"context"
-	"os"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	"github.com/cen-ngc5139/shepherd/internal/binary"
 	"github.com/cen-ngc5139/shepherd/internal/cache"
 	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/enricher"
 	"github.com/cen-ngc5139/shepherd/internal/log"
 	"github.com/cen-ngc5139/shepherd/internal/metadata"
+	"github.com/cen-ngc5139/shepherd/internal/policy"
+	"github.com/cen-ngc5139/shepherd/internal/stack"
 	"github.com/cilium/ebpf"
-	"github.com/cilium/ebpf/perf"
 )
 
-func ProcessSchedDelay(coll *ebpf.Collection, ctx context.Context, cfg config.Configuration) {
-	schedEvents := coll.Maps["sched_events"]
-	perfReader, err := perf.NewReader(schedEvents, os.Getpagesize())
+// Handler 消费经过元数据补全的调度事件
+type Handler interface {
+	Handle(event metadata.SchedEvent)
+}
+
+func ProcessSchedDelay(coll *ebpf.Collection, ctx context.Context, cfg config.Configuration, e enricher.Enricher,
+	tgids *enricher.TgidResolver, policies *policy.Store, symbolizer *stack.Symbolizer, handlers ...Handler) {
+	reader, err := newEventReader(coll)
 	if err != nil {
-		log.Errorf("failed to create ringbuf reader: %v", err)
+		log.Errorf("failed to create event reader: %v", err)
 		return
 	}
 
-	defer perfReader.Close()
+	defer reader.Close()
 
-	output, err := NewOutput(cfg, ctx)
+	output, err := NewRouter(cfg, ctx, policies)
 	if err != nil {
 		log.Fatalf("failed to init output: %v", err)
 	}
@@ -40,21 +46,41 @@ func ProcessSchedDelay(coll *ebpf.Collection, ctx context.Context, cfg config.Co
 			log.Info("退出事件处理")
 			return
 		default:
-			if err := parseEvent(perfReader, &event); err != nil {
-				log.Errorf("failed to parse perf event: %v", err)
+			if err := reader.Read(&event); err != nil {
+				log.Errorf("failed to parse sched event: %v", err)
 				continue
 			}
 
-			if err := output.Push(event); err != nil {
+			// 按进程聚合前将线程 id 解析为 tgid，保证各内核版本的进程维度指标一致
+			tgids.Fill(&event)
+			schedEvent := enricher.Enrich(e, event)
+			if symbolizer != nil {
+				schedEvent.Stack = symbolizer.Symbolize(event.Pid, event.KernStackId, event.UserStackId)
+				if event.IsPreempt == 1 {
+					schedEvent.PreemptedStack = symbolizer.Symbolize(event.PreemptedPid,
+						event.PreemptedKernStackId, event.PreemptedUserStackId)
+				}
+			}
+			if err := output.Push(schedEvent); err != nil {
 				log.Errorf("failed to push event: %v", err)
 				continue
 			}
 
+			for _, h := range handlers {
+				h.Handle(schedEvent)
+			}
+
+			updateSchedCPUMetrics(schedEvent)
+			updateSchedWakerMetrics(schedEvent)
+			updateSchedImbalanceMetrics(schedEvent)
+
 			schedMetrics := metadata.SchedMetrics{
-				Pid:     event.Pid,
-				DelayNs: event.DelayNs,
-				Ts:      event.Ts,
-				Comm:    sanitizeString(convertInt8ToString(event.Comm[:])),
+				Pid:       event.Pid,
+				DelayNs:   event.DelayNs,
+				Ts:        event.Ts,
+				Comm:      metadata.CommString(event.Comm[:]),
+				Pod:       schedEvent.Pod.GetName(),
+				Namespace: schedEvent.Pod.GetNamespace(),
 			}
 
 			current, isExist := cache.SchedMetricsMap.Load(event.Pid)
@@ -70,6 +96,10 @@ func ProcessSchedDelay(coll *ebpf.Collection, ctx context.Context, cfg config.Co
 			}
 
 			currentSchedMetrics.DelayNs = event.DelayNs + currentSchedMetrics.DelayNs
+			if currentSchedMetrics.Pod == "" {
+				currentSchedMetrics.Pod = schedMetrics.Pod
+				currentSchedMetrics.Namespace = schedMetrics.Namespace
+			}
 			if event.IsPreempt != 1 {
 				cache.SchedMetricsMap.Store(event.Pid, currentSchedMetrics)
 				continue
@@ -77,10 +107,11 @@ func ProcessSchedDelay(coll *ebpf.Collection, ctx context.Context, cfg config.Co
 
 			currentSchedMetrics.PreempteCount++
 			schedPreempted := metadata.SchedPreempted{
-
-				Pid:   event.PreemptedPid,
-				Count: 1,
-				Comm:  sanitizeString(convertInt8ToString(event.PreemptedComm[:])),
+				Pid:       event.PreemptedPid,
+				Count:     1,
+				Comm:      metadata.CommString(event.PreemptedComm[:]),
+				Pod:       schedEvent.PreemptedPod.GetName(),
+				Namespace: schedEvent.PreemptedPod.GetNamespace(),
 			}
 
 			preempted, isExist := cache.SchedPreemptedMap.Load(event.PreemptedPid)
@@ -103,17 +134,93 @@ func ProcessSchedDelay(coll *ebpf.Collection, ctx context.Context, cfg config.Co
 
 }
 
-func insertSchedMetrics(ctx context.Context, conn clickhouse.Conn, batch driver.Batch, event binary.ShepherdSchedLatencyT, count int) (driver.Batch, int, error) {
+// updateSchedCPUMetrics 按 CPU 与调度类累计调度延迟，仅由事件处理协程写入
+func updateSchedCPUMetrics(event metadata.SchedEvent) {
+	key := metadata.SchedCPUKey{Cpu: event.Cpu, SchedClass: event.SchedClass}
+	cpuMetrics := metadata.SchedCPUMetrics{SchedCPUKey: key}
+	if current, ok := cache.SchedCPUMap.Load(key); ok {
+		cpuMetrics = current.(metadata.SchedCPUMetrics)
+	}
+
+	cpuMetrics.Count++
+	cpuMetrics.DelayNs += event.DelayNs
+	cache.SchedCPUMap.Store(key, cpuMetrics)
+}
+
+// updateSchedImbalanceMetrics 按 CPU 累计等待期间存在可用空闲 CPU 的调度延迟及迁移次数
+func updateSchedImbalanceMetrics(event metadata.SchedEvent) {
+	if event.IdleCpus == 0 && event.Migrations == 0 {
+		return
+	}
+
+	imbalance := metadata.SchedImbalanceMetrics{Cpu: event.Cpu}
+	if current, ok := cache.SchedImbalanceMap.Load(event.Cpu); ok {
+		imbalance = current.(metadata.SchedImbalanceMetrics)
+	}
+
+	if event.IdleCpus > 0 {
+		imbalance.Count++
+		imbalance.DelayNs += event.DelayNs
+	}
+	imbalance.Migrations += uint64(event.Migrations)
+	cache.SchedImbalanceMap.Store(event.Cpu, imbalance)
+}
+
+// updateSchedWakerMetrics 按唤醒方进程名与唤醒上下文累计调度延迟，重新入队的事件没有唤醒方
+func updateSchedWakerMetrics(event metadata.SchedEvent) {
+	if event.LatencyType != metadata.LatencyWakeup {
+		return
+	}
+
+	key := metadata.SchedWakerKey{
+		Comm:    metadata.CommString(event.WakerComm[:]),
+		Context: event.WakerContext,
+	}
+	wakerMetrics := metadata.SchedWakerMetrics{SchedWakerKey: key}
+	if current, ok := cache.SchedWakerMap.Load(key); ok {
+		wakerMetrics = current.(metadata.SchedWakerMetrics)
+	}
+
+	wakerMetrics.Count++
+	wakerMetrics.DelayNs += event.DelayNs
+	cache.SchedWakerMap.Store(key, wakerMetrics)
+}
+
+func insertSchedMetrics(ctx context.Context, conn clickhouse.Conn, batch driver.Batch, event metadata.SchedEvent, count int) (driver.Batch, int, error) {
 	err := batch.Append(
 		event.Pid,
 		event.Tid,
 		event.DelayNs,
 		event.Ts,
 		event.PreemptedPid,
-		sanitizeString(convertInt8ToString(event.PreemptedComm[:])),
+		metadata.CommString(event.PreemptedComm[:]),
 		event.IsPreempt,
-		sanitizeString(convertInt8ToString(event.Comm[:])),
+		metadata.CommString(event.Comm[:]),
 		event.PreemptedPidState,
+		event.CgroupId,
+		event.PreemptedCgroupId,
+		event.Pod.GetName(),
+		event.Pod.GetNamespace(),
+		event.PreemptedPod.GetName(),
+		event.PreemptedPod.GetNamespace(),
+		event.Cpu,
+		event.PrevCpu,
+		event.Prio,
+		event.Nice,
+		event.Policy,
+		event.SchedClass,
+		event.Stack.Folded(),
+		event.PreemptedStack.Folded(),
+		event.LatencyKind,
+		event.WakerPid,
+		event.WakerTid,
+		metadata.CommString(event.WakerComm[:]),
+		event.WakerCpu,
+		event.WakerContext,
+		event.IdleCpus,
+		event.Migrations,
+		event.HardirqNs,
+		event.SoftirqNs,
 	)
 	if err != nil {
 		log.Errorf("failed to append to batch: %v", err)
@@ -129,14 +236,7 @@ func insertSchedMetrics(ctx context.Context, conn clickhouse.Conn, batch driver.
 		}
 		count = 0 // 重置计数器
 		// 创建新的批次
-		batch, err = conn.PrepareBatch(ctx, `
-			INSERT INTO sched_latency (
-				pid, tid, delay_ns, ts, 
-				preempted_pid, preempted_comm, 
-				is_preempt, comm,
-				preempted_pid_state
-			)
-		`)
+		batch, err = conn.PrepareBatch(ctx, insertSchedLatencySQL)
 		if err != nil {
 			log.Errorf("failed to prepare new batch: %v", err)
 			return batch, count, err
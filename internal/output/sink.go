@@ -0,0 +1,98 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cen-ngc5139/shepherd/pkg/client"
+	"github.com/cen-ngc5139/shepherd/pkg/kafka"
+	"github.com/pkg/errors"
+)
+
+// SinkSpec 描述一类记录在外部存储中的格式
+type SinkSpec[T any] struct {
+	Name         string        // 日志中的记录名称
+	DefaultTopic string        // 未配置 topic 时 kafka 输出使用的 topic
+	InsertSQL    string        // clickhouse 批量写入语句，不含 VALUES
+	Columns      func(T) []any // 与 InsertSQL 列顺序一致的取值
+}
+
+// Sink 将干扰事件、限流事件、聚合直方图等低频记录写入 SinkConfig 指定的输出
+type Sink[T any] struct {
+	spec     SinkSpec[T]
+	sinkType config.OutputType
+	ctx      context.Context
+	ckConn   clickhouse.Conn
+	producer *kafka.Producer
+}
+
+func NewSink[T any](cfg config.Configuration, sink config.SinkConfig, spec SinkSpec[T], ctx context.Context) (*Sink[T], error) {
+	s := &Sink[T]{spec: spec, sinkType: sink.Type, ctx: ctx}
+
+	switch s.sinkType {
+	case config.OutputTypeClickhouse:
+		conn, err := client.NewClickHouseConn(cfg.Output.Clickhouse)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to init clickhouse client")
+		}
+		s.ckConn = conn
+	case config.OutputTypeKafka:
+		topic := sink.Topic
+		if topic == "" {
+			topic = spec.DefaultTopic
+		}
+
+		producer, err := kafka.NewSyncProducer(cfg.Output.Kafka.Brokers, topic, true, true)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to init kafka client")
+		}
+		s.producer = producer
+	}
+
+	return s, nil
+}
+
+// Write 写入一批记录，clickhouse 输出每次调用为一个批次
+func (s *Sink[T]) Write(records ...T) error {
+	switch s.sinkType {
+	case config.OutputTypeClickhouse:
+		batch, err := s.ckConn.PrepareBatch(s.ctx, s.spec.InsertSQL)
+		if err != nil {
+			return errors.Wrap(err, "failed to prepare batch")
+		}
+
+		for _, r := range records {
+			if err := batch.Append(s.spec.Columns(r)...); err != nil {
+				return errors.Wrap(err, "failed to append to batch")
+			}
+		}
+
+		return errors.Wrap(batch.Send(), "failed to send batch")
+	case config.OutputTypeKafka:
+		for _, r := range records {
+			raw, err := json.Marshal(r)
+			if err != nil {
+				return errors.Wrapf(err, "failed to marshal %s", s.spec.Name)
+			}
+
+			if _, _, err := s.producer.SyncSendMessage(raw); err != nil {
+				return errors.Wrap(err, "fail to push kafka data")
+			}
+		}
+	default:
+		for _, r := range records {
+			log.StdoutOrFile(string(s.sinkType), r)
+		}
+	}
+
+	return nil
+}
+
+func (s *Sink[T]) Close() {
+	if s.ckConn != nil {
+		s.ckConn.Close()
+	}
+}
@@ -35,17 +35,6 @@ func parseRingbufEvent(record *ringbuf.Record, data interface{}) error {
 	return nil
 }
 
-func convertInt8ToString(data []int8) string {
-	var result strings.Builder
-	for _, b := range data {
-		if b == 0 {
-			break
-		}
-		result.WriteByte(byte(b))
-	}
-	return result.String()
-}
-
 func parseFileName(bs []int8) string {
 	ba := make([]byte, 0, len(bs))
 	for _, b := range bs {
@@ -64,10 +53,6 @@ func filterNonASCII(data []byte) string {
 	return sb.String()
 }
 
-func sanitizeString(s string) string {
-	return strings.TrimSpace(s)
-}
-
 
 // 线程状态常量
 const (
@@ -0,0 +1,217 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/cen-ngc5139/shepherd/api/v1alpha1"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cen-ngc5139/shepherd/pkg/client"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Reconciler 将集群中的 ShepherdPolicy 应用为本节点的生效策略，并回写状态
+type Reconciler struct {
+	Client   ctrlclient.Client
+	NodeName string
+	Store    *Store
+}
+
+// +kubebuilder:rbac:groups=shepherd.io,resources=shepherdpolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=shepherd.io,resources=shepherdpolicies/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var node corev1.Node
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: r.NodeName}, &node); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to get node %s", r.NodeName)
+	}
+
+	var list v1alpha1.ShepherdPolicyList
+	if err := r.Client.List(ctx, &list); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to list shepherd policies")
+	}
+
+	// 策略之间存在优先级关系，任一策略变化都重新计算全部生效策略
+	var rules []Rule
+	results := make(map[string]error) // 适用于本节点的策略 -> 规则创建结果
+	for i := range list.Items {
+		p := &list.Items[i]
+		if !p.DeletionTimestamp.IsZero() || !nodeMatches(p, &node) {
+			continue
+		}
+
+		rule, err := NewRule(p)
+		results[p.Name] = err
+		if err != nil {
+			log.Warningf("failed to apply shepherd policy %s: %v", p.Name, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	r.Store.Set(rules)
+
+	ruleErr, matches := results[req.Name]
+	if err := r.updateStatus(ctx, req.Name, matches, ruleErr); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// updateStatus 更新策略的 Accepted Condition 与本节点的应用状态，
+// 只有规则实际生效时才记录本节点，策略不适用于本节点或规则创建失败时移除该节点
+func (r *Reconciler) updateStatus(ctx context.Context, name string, matches bool, ruleErr error) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var p v1alpha1.ShepherdPolicy
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: name}, &p); err != nil {
+			return ctrlclient.IgnoreNotFound(err)
+		}
+
+		var changed bool
+		// 规则创建只依赖策略内容，不适用于本节点时不更新 Condition，由适用的节点负责
+		if matches {
+			condition := metav1.Condition{
+				Type:               v1alpha1.ConditionAccepted,
+				Status:             metav1.ConditionTrue,
+				Reason:             v1alpha1.ReasonValid,
+				ObservedGeneration: p.Generation,
+			}
+			if ruleErr != nil {
+				condition.Status = metav1.ConditionFalse
+				condition.Reason = v1alpha1.ReasonInvalidSpec
+				condition.Message = ruleErr.Error()
+			}
+			changed = meta.SetStatusCondition(&p.Status.Conditions, condition)
+		}
+
+		applied := matches && ruleErr == nil
+		nodes := make([]v1alpha1.NodeApplyStatus, 0, len(p.Status.AppliedNodes)+1)
+		var existing *v1alpha1.NodeApplyStatus
+		for i := range p.Status.AppliedNodes {
+			if p.Status.AppliedNodes[i].NodeName == r.NodeName {
+				existing = &p.Status.AppliedNodes[i]
+				continue
+			}
+			nodes = append(nodes, p.Status.AppliedNodes[i])
+		}
+
+		switch {
+		case applied && existing != nil && existing.ObservedGeneration == p.Generation:
+			nodes = append(nodes, *existing)
+		case applied:
+			nodes = append(nodes, v1alpha1.NodeApplyStatus{
+				NodeName:           r.NodeName,
+				ObservedGeneration: p.Generation,
+				AppliedAt:          metav1.Now(),
+			})
+			changed = true
+		case existing != nil:
+			changed = true
+		}
+
+		if !changed {
+			return nil
+		}
+		p.Status.AppliedNodes = nodes
+		return r.Client.Status().Update(ctx, &p)
+	})
+}
+
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	isLocalNode := predicate.NewPredicateFuncs(func(obj ctrlclient.Object) bool {
+		return obj.GetName() == r.NodeName
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("shepherdpolicy").
+		For(&v1alpha1.ShepherdPolicy{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// 节点标签变化可能改变 nodeSelector 的匹配结果
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(r.policiesForNode),
+			builder.WithPredicates(isLocalNode, predicate.LabelChangedPredicate{})).
+		Complete(r)
+}
+
+// policiesForNode 节点变化时重新协调全部策略
+func (r *Reconciler) policiesForNode(ctx context.Context, _ ctrlclient.Object) []reconcile.Request {
+	var list v1alpha1.ShepherdPolicyList
+	if err := r.Client.List(ctx, &list); err != nil {
+		log.Warningf("failed to list shepherd policies: %v", err)
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for i := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: list.Items[i].Name}})
+	}
+
+	return requests
+}
+
+func nodeMatches(p *v1alpha1.ShepherdPolicy, node *corev1.Node) bool {
+	if len(p.Spec.NodeSelector) == 0 {
+		return true
+	}
+
+	return labels.SelectorFromSet(p.Spec.NodeSelector).Matches(labels.Set(node.Labels))
+}
+
+// StartController 启动策略控制器，阻塞直至 ctx 结束
+func StartController(ctx context.Context, nodeName string, store *Store) error {
+	m := client.NewK8sManager()
+	if err := m.CreateClient(); err != nil {
+		return errors.Wrap(err, "failed to create k8s client")
+	}
+
+	mgr, err := newManager(m.GetK8sConfig(), nodeName, store)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("starting shepherd policy controller on node %s", nodeName)
+	return mgr.Start(ctx)
+}
+
+// newManager 创建只缓存本节点的 manager 并注册策略控制器
+func newManager(cfg *rest.Config, nodeName string, store *Store) (ctrl.Manager, error) {
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:                 client.Scheme,
+		Metrics:                metricsserver.Options{BindAddress: "0"},
+		HealthProbeBindAddress: "0",
+		// 只缓存本节点，避免每个 agent 都监听全部节点
+		Cache: cache.Options{
+			ByObject: map[ctrlclient.Object]cache.ByObject{
+				&corev1.Node{}: {Field: fields.OneTermEqualSelector("metadata.name", nodeName)},
+			},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create controller manager")
+	}
+
+	r := &Reconciler{
+		Client:   mgr.GetClient(),
+		NodeName: nodeName,
+		Store:    store,
+	}
+	if err := r.SetupWithManager(mgr); err != nil {
+		return nil, errors.Wrap(err, "failed to setup shepherd policy controller")
+	}
+
+	return mgr, nil
+}
@@ -0,0 +1,128 @@
+package policy
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cen-ngc5139/shepherd/api/v1alpha1"
+	"github.com/cen-ngc5139/shepherd/pkg/client"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testNodeName = "node-a"
+
+func newTestReconciler(objs ...ctrlclient.Object) *Reconciler {
+	c := fake.NewClientBuilder().
+		WithScheme(client.Scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&v1alpha1.ShepherdPolicy{}).
+		Build()
+
+	return &Reconciler{Client: c, NodeName: testNodeName, Store: NewStore()}
+}
+
+func testNode(labels map[string]string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: testNodeName, Labels: labels}}
+}
+
+func testPolicy(name string, spec v1alpha1.ShepherdPolicySpec) *v1alpha1.ShepherdPolicy {
+	return &v1alpha1.ShepherdPolicy{ObjectMeta: metav1.ObjectMeta{Name: name, Generation: 1}, Spec: spec}
+}
+
+func reconcilePolicy(t *testing.T, r *Reconciler, name string) *v1alpha1.ShepherdPolicy {
+	t.Helper()
+
+	ctx := context.Background()
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: name}}); err != nil {
+		t.Fatalf("failed to reconcile %s: %v", name, err)
+	}
+
+	var p v1alpha1.ShepherdPolicy
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: name}, &p); err != nil {
+		t.Fatalf("failed to get %s: %v", name, err)
+	}
+	return &p
+}
+
+func appliedOn(p *v1alpha1.ShepherdPolicy, node string) bool {
+	for _, n := range p.Status.AppliedNodes {
+		if n.NodeName == node {
+			return true
+		}
+	}
+	return false
+}
+
+func TestReconcileInvalidPolicyNotApplied(t *testing.T) {
+	valid := testPolicy("default", v1alpha1.ShepherdPolicySpec{Thresholds: v1alpha1.Thresholds{LatencyNs: 1000}})
+	invalid := testPolicy("bad", v1alpha1.ShepherdPolicySpec{
+		Selector: &v1alpha1.WorkloadSelector{LabelSelectors: []string{"app in ("}},
+	})
+	r := newTestReconciler(testNode(nil), valid, invalid)
+
+	p := reconcilePolicy(t, r, "bad")
+	if appliedOn(p, testNodeName) {
+		t.Errorf("invalid policy recorded as applied: %+v", p.Status.AppliedNodes)
+	}
+	cond := meta.FindStatusCondition(p.Status.Conditions, v1alpha1.ConditionAccepted)
+	if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != v1alpha1.ReasonInvalidSpec {
+		t.Fatalf("unexpected condition %+v", cond)
+	}
+	if !strings.Contains(cond.Message, "invalid selector") {
+		t.Errorf("condition message %q does not contain the rule error", cond.Message)
+	}
+
+	p = reconcilePolicy(t, r, "default")
+	if !appliedOn(p, testNodeName) {
+		t.Errorf("valid policy not applied: %+v", p.Status.AppliedNodes)
+	}
+	if !meta.IsStatusConditionTrue(p.Status.Conditions, v1alpha1.ConditionAccepted) {
+		t.Errorf("valid policy not accepted: %+v", p.Status.Conditions)
+	}
+
+	if rule, ok := r.Store.Default(); !ok || rule.Name != "default" {
+		t.Errorf("unexpected default rule %+v", rule)
+	}
+}
+
+func TestReconcileNodeLabelChange(t *testing.T) {
+	node := testNode(nil)
+	p := testPolicy("zone-a", v1alpha1.ShepherdPolicySpec{NodeSelector: map[string]string{"zone": "a"}})
+	r := newTestReconciler(node, p)
+	ctx := context.Background()
+
+	if got := reconcilePolicy(t, r, "zone-a"); appliedOn(got, testNodeName) || len(got.Status.Conditions) != 0 {
+		t.Errorf("policy applied on unmatched node: %+v", got.Status)
+	}
+
+	node.Labels = map[string]string{"zone": "a"}
+	if err := r.Client.Update(ctx, node); err != nil {
+		t.Fatalf("failed to label node: %v", err)
+	}
+	// 节点变化时重新协调全部策略
+	requests := r.policiesForNode(ctx, node)
+	if len(requests) != 1 || requests[0].Name != "zone-a" {
+		t.Fatalf("unexpected requests %v", requests)
+	}
+	if got := reconcilePolicy(t, r, "zone-a"); !appliedOn(got, testNodeName) {
+		t.Errorf("policy not applied after node labeled: %+v", got.Status)
+	}
+
+	node.Labels = nil
+	if err := r.Client.Update(ctx, node); err != nil {
+		t.Fatalf("failed to unlabel node: %v", err)
+	}
+	if got := reconcilePolicy(t, r, "zone-a"); appliedOn(got, testNodeName) {
+		t.Errorf("policy still applied after label removed: %+v", got.Status)
+	}
+	if _, ok := r.Store.Default(); ok {
+		t.Errorf("rule still installed after label removed")
+	}
+}
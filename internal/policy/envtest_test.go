@@ -0,0 +1,123 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/api/v1alpha1"
+	"github.com/cen-ngc5139/shepherd/pkg/client"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+const envtestTimeout = 10 * time.Second
+
+// TestControllerEnvtest 在 envtest 启动的 API Server 上运行控制器，需要通过 KUBEBUILDER_ASSETS 指定
+// etcd 与 kube-apiserver，例如 KUBEBUILDER_ASSETS=$(setup-envtest use -p path) go test ./internal/policy/
+func TestControllerEnvtest(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set")
+	}
+
+	env := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "deploy", "helm", "crds")},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := env.Start()
+	if err != nil {
+		t.Fatalf("failed to start envtest: %v", err)
+	}
+	defer env.Stop()
+
+	c, err := ctrlclient.New(cfg, ctrlclient.Options{Scheme: client.Scheme})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: testNodeName}}
+	if err := c.Create(ctx, node); err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	// 其他节点的变化不应触发本节点的协调
+	if err := c.Create(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-b"}}); err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+
+	store := NewStore()
+	mgr, err := newManager(cfg, testNodeName, store)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	go func() {
+		if err := mgr.Start(ctx); err != nil {
+			t.Errorf("manager exited: %v", err)
+		}
+	}()
+
+	policies := []*v1alpha1.ShepherdPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "bad"},
+			Spec: v1alpha1.ShepherdPolicySpec{
+				Selector: &v1alpha1.WorkloadSelector{LabelSelectors: []string{"app in ("}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "zone-a"},
+			Spec:       v1alpha1.ShepherdPolicySpec{NodeSelector: map[string]string{"zone": "a"}},
+		},
+	}
+	for _, p := range policies {
+		if err := c.Create(ctx, p); err != nil {
+			t.Fatalf("failed to create policy %s: %v", p.Name, err)
+		}
+	}
+
+	waitFor(t, "invalid policy rejected", func() bool {
+		p := getPolicy(ctx, c, "bad")
+		cond := meta.FindStatusCondition(p.Status.Conditions, v1alpha1.ConditionAccepted)
+		return cond != nil && cond.Status == metav1.ConditionFalse && !appliedOn(p, testNodeName)
+	})
+	if appliedOn(getPolicy(ctx, c, "zone-a"), testNodeName) {
+		t.Errorf("policy applied on unmatched node")
+	}
+
+	// 只修改节点标签，策略本身不变
+	node.Labels = map[string]string{"zone": "a"}
+	if err := c.Update(ctx, node); err != nil {
+		t.Fatalf("failed to label node: %v", err)
+	}
+	waitFor(t, "policy applied after node labeled", func() bool {
+		return appliedOn(getPolicy(ctx, c, "zone-a"), testNodeName)
+	})
+	if _, ok := store.Default(); !ok {
+		t.Errorf("rule not installed after node labeled")
+	}
+}
+
+func getPolicy(ctx context.Context, c ctrlclient.Client, name string) *v1alpha1.ShepherdPolicy {
+	var p v1alpha1.ShepherdPolicy
+	_ = c.Get(ctx, types.NamespacedName{Name: name}, &p)
+	return &p
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(envtestTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,133 @@
+package policy
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/cen-ngc5139/shepherd/api/v1alpha1"
+	"github.com/cen-ngc5139/shepherd/internal/binary"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/metadata"
+	"github.com/cen-ngc5139/shepherd/internal/scope"
+	"github.com/pkg/errors"
+)
+
+// Rule 在当前节点生效的策略
+type Rule struct {
+	Name     string
+	Priority int32
+	Selector *scope.Selector // 为 nil 时作为节点默认策略
+	Policy   binary.ShepherdSchedPolicyT
+	Output   config.OutputType
+}
+
+// NewRule 将 ShepherdPolicy 转换为 Rule
+func NewRule(p *v1alpha1.ShepherdPolicy) (Rule, error) {
+	rule := Rule{
+		Name:     p.Name,
+		Priority: p.Spec.Priority,
+		Policy: binary.ShepherdSchedPolicyT{
+			ThresholdNs:   p.Spec.Thresholds.LatencyNs,
+			SamplingRatio: uint64(p.Spec.Sampling.Ratio),
+		},
+	}
+
+	if p.Spec.Output != nil {
+		rule.Output = config.OutputType(p.Spec.Output.Type)
+	}
+
+	if p.Spec.Selector != nil {
+		selector, err := scope.NewSelector(config.ScopeConfig{
+			IncludeNamespaces: p.Spec.Selector.Namespaces,
+			ExcludeNamespaces: p.Spec.Selector.ExcludeNamespaces,
+			LabelSelectors:    p.Spec.Selector.LabelSelectors,
+		})
+		if err != nil {
+			return rule, errors.Wrapf(err, "invalid selector of policy %s", p.Name)
+		}
+		rule.Selector = selector
+	}
+
+	return rule, nil
+}
+
+// Store 保存当前节点生效的策略，供 BPF 同步与事件输出路由使用
+type Store struct {
+	mu      sync.RWMutex
+	rules   []Rule
+	updates chan struct{}
+}
+
+func NewStore() *Store {
+	return &Store{updates: make(chan struct{}, 1)}
+}
+
+// Set 替换全部策略，按优先级从高到低、名称升序排列
+func (s *Store) Set(rules []Rule) {
+	sorted := append([]Rule{}, rules...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Priority != sorted[j].Priority {
+			return sorted[i].Priority > sorted[j].Priority
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	s.mu.Lock()
+	s.rules = sorted
+	s.mu.Unlock()
+
+	select {
+	case s.updates <- struct{}{}:
+	default:
+	}
+}
+
+// Updates 策略变更通知
+func (s *Store) Updates() <-chan struct{} {
+	return s.updates
+}
+
+// Default 返回优先级最高的节点默认策略
+func (s *Store) Default() (Rule, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, rule := range s.rules {
+		if rule.Selector == nil {
+			return rule, true
+		}
+	}
+
+	return Rule{}, false
+}
+
+// Match 返回命中 Pod 的优先级最高的工作负载策略
+func (s *Store) Match(pod *metadata.PodInfo) (Rule, bool) {
+	if pod == nil {
+		return Rule{}, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, rule := range s.rules {
+		if rule.Selector != nil && rule.Selector.Matches(pod) {
+			return rule, true
+		}
+	}
+
+	return Rule{}, false
+}
+
+// OutputFor 返回事件应使用的输出类型，为空表示使用 agent 配置的默认输出
+func (s *Store) OutputFor(pod *metadata.PodInfo) config.OutputType {
+	if rule, ok := s.Match(pod); ok && rule.Output != "" {
+		return rule.Output
+	}
+
+	if rule, ok := s.Default(); ok {
+		return rule.Output
+	}
+
+	return ""
+}
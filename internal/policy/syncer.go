@@ -0,0 +1,144 @@
+package policy
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/binary"
+	"github.com/cen-ngc5139/shepherd/internal/bpf"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/enricher"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+)
+
+const resyncPeriod = 10 * time.Second
+
+// Syncer 将生效策略同步到 BPF 的 sched_config 与 cgroup_policies
+type Syncer struct {
+	store    *Store
+	defaults binary.ShepherdSchedPolicyT
+	enricher enricher.Enricher
+	cgroups  *enricher.CgroupResolver
+
+	coll      *ebpf.Collection
+	policyMap *ebpf.Map
+	current   map[uint64]binary.ShepherdSchedPolicyT
+}
+
+func NewSyncer(cfg config.Configuration, store *Store, e enricher.Enricher, coll *ebpf.Collection) (*Syncer, error) {
+	policyMap, ok := coll.Maps[bpf.CgroupPoliciesMap]
+	if !ok {
+		return nil, errors.Errorf("map %s not found", bpf.CgroupPoliciesMap)
+	}
+
+	return &Syncer{
+		store:     store,
+		defaults:  bpf.NodeSchedPolicy(cfg.Sched),
+		enricher:  e,
+		cgroups:   enricher.NewCgroupResolver(cfg.Kubernetes.CgroupRoot),
+		coll:      coll,
+		policyMap: policyMap,
+		current:   make(map[uint64]binary.ShepherdSchedPolicyT),
+	}, nil
+}
+
+// Start 在策略或 Pod 变化时重新同步
+func (s *Syncer) Start(ctx context.Context) error {
+	changes := s.enricher.Subscribe()
+	ticker := time.NewTicker(resyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		if err := s.reconcile(); err != nil {
+			log.Warningf("failed to sync sched policies: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.store.Updates():
+		case <-changes:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Syncer) reconcile() error {
+	defaults := s.defaults
+	if rule, ok := s.store.Default(); ok {
+		defaults = mergePolicy(rule.Policy, s.defaults)
+	}
+	if err := bpf.SetSchedConfig(s.coll, defaults); err != nil {
+		return err
+	}
+
+	// 按策略归类命中 Pod 的 cgroup 路径特征
+	patterns := make(map[string][]string)
+	policies := make(map[string]binary.ShepherdSchedPolicyT)
+	for _, pod := range s.enricher.Pods() {
+		rule, ok := s.store.Match(pod)
+		if !ok || pod.UID == "" {
+			continue
+		}
+		policies[rule.Name] = mergePolicy(rule.Policy, defaults)
+		patterns[rule.Name] = append(patterns[rule.Name],
+			"pod"+pod.UID, "pod"+strings.ReplaceAll(pod.UID, "-", "_"))
+	}
+
+	desired := make(map[uint64]binary.ShepherdSchedPolicyT)
+	for name, podPatterns := range patterns {
+		ids := s.cgroups.Match(func(path string) bool {
+			for _, pattern := range podPatterns {
+				if strings.Contains(path, pattern) {
+					return true
+				}
+			}
+			return false
+		})
+		for _, id := range ids {
+			desired[id] = policies[name]
+		}
+	}
+
+	for id, policy := range desired {
+		if current, ok := s.current[id]; ok && current == policy {
+			continue
+		}
+		if err := s.policyMap.Put(id, policy); err != nil {
+			return errors.Wrapf(err, "failed to set policy of cgroup %d", id)
+		}
+		s.current[id] = policy
+	}
+
+	for id := range s.current {
+		if _, ok := desired[id]; ok {
+			continue
+		}
+		if err := s.policyMap.Delete(id); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return errors.Wrapf(err, "failed to delete policy of cgroup %d", id)
+		}
+		delete(s.current, id)
+	}
+
+	return nil
+}
+
+// mergePolicy 未设置的字段沿用 fallback，仅节点默认策略生效的字段始终沿用 fallback
+func mergePolicy(policy, fallback binary.ShepherdSchedPolicyT) binary.ShepherdSchedPolicyT {
+	policy.Aggregate = fallback.Aggregate
+	policy.DisableEvents = fallback.DisableEvents
+	policy.CaptureStacks = fallback.CaptureStacks
+	policy.RunqueueStats = fallback.RunqueueStats
+
+	if policy.ThresholdNs == 0 {
+		policy.ThresholdNs = fallback.ThresholdNs
+	}
+	if policy.SamplingRatio == 0 {
+		policy.SamplingRatio = fallback.SamplingRatio
+	}
+
+	return policy
+}
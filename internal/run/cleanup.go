@@ -0,0 +1,30 @@
+package run
+
+import (
+	"github.com/cen-ngc5139/shepherd/internal/bpf"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+)
+
+// Cleanup 删除固定到 bpffs 的 map 与挂载链接，agent 遗留在内核中的程序随之卸载
+// 不要求配置中开启 pin，关闭固定后也可以清理此前遗留的对象
+func Cleanup(cfg config.Configuration) error {
+	if cfg.ConfigPath != "" {
+		if err := config.LoadConfig(&cfg); err != nil {
+			return err
+		}
+	}
+
+	cfg.Pin.Enable = true
+	if cfg.PinPathArg != "" {
+		cfg.Pin.Path = cfg.PinPathArg
+	}
+	dir := bpf.PinPath(cfg.Pin)
+
+	if err := bpf.RemovePins(dir); err != nil {
+		return err
+	}
+
+	log.Infof("removed pinned bpf objects under %s", dir)
+	return nil
+}
@@ -0,0 +1,40 @@
+package run
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/diagnose"
+	"github.com/pkg/errors"
+)
+
+// Diagnose 逐个加载 BPF 程序并输出报告，同时写入支持包，选用的程序加载失败时返回错误
+func Diagnose(cfg config.Configuration) error {
+	if cfg.ConfigPath != "" {
+		if err := config.LoadConfig(&cfg); err != nil {
+			return err
+		}
+	}
+
+	report, err := diagnose.Run(cfg, diagnose.Options{LogLines: cfg.DiagnoseArgs.LogLines})
+	if err != nil {
+		return err
+	}
+	report.WriteText(os.Stdout)
+
+	bundle := cfg.DiagnoseArgs.Output
+	if bundle == "" {
+		hostname, _ := os.Hostname()
+		bundle = fmt.Sprintf("shepherd-diagnose-%s-%s.tar.gz", hostname, report.Time.Format("20060102-150405"))
+	}
+	if err := diagnose.WriteBundle(bundle, report, cfg); err != nil {
+		return err
+	}
+	fmt.Printf("\nsupport bundle written to %s\n", bundle)
+
+	if failed := report.Failed(); failed > 0 {
+		return errors.Errorf("%d selected programs failed to load", failed)
+	}
+	return nil
+}
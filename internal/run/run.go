@@ -2,26 +2,41 @@ package run
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/cen-ngc5139/shepherd/internal/aggregate"
+	"github.com/cen-ngc5139/shepherd/internal/analysis"
 	ebpfbinary "github.com/cen-ngc5139/shepherd/internal/binary"
 	"github.com/cen-ngc5139/shepherd/internal/bpf"
 	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/enricher"
+	"github.com/cen-ngc5139/shepherd/internal/filter"
+	"github.com/cen-ngc5139/shepherd/internal/funcstat"
+	"github.com/cen-ngc5139/shepherd/internal/irqstat"
+	"github.com/cen-ngc5139/shepherd/internal/k8sevent"
 	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cen-ngc5139/shepherd/internal/mitigation"
+	"github.com/cen-ngc5139/shepherd/internal/nodestatus"
 	"github.com/cen-ngc5139/shepherd/internal/output"
+	"github.com/cen-ngc5139/shepherd/internal/policy"
+	"github.com/cen-ngc5139/shepherd/internal/runqueue"
+	"github.com/cen-ngc5139/shepherd/internal/scope"
+	"github.com/cen-ngc5139/shepherd/internal/stack"
+	"github.com/cen-ngc5139/shepherd/internal/throttle"
+	"github.com/cen-ngc5139/shepherd/internal/tracepipe"
 	"github.com/cen-ngc5139/shepherd/server"
 	"github.com/cilium/ebpf"
-	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/rlimit"
 	"golang.org/x/sys/unix"
 )
 
+// verifierLogTailLines 加载失败时输出的校验器日志行数
+const verifierLogTailLines = 20
+
 func Run(cfg config.Configuration) {
 	if cfg.ConfigPath != "" {
 		err := config.LoadConfig(&cfg)
@@ -30,6 +45,16 @@ func Run(cfg config.Configuration) {
 			os.Exit(1)
 		}
 	}
+	if err := cfg.ApplyFilterArgs(); err != nil {
+		log.Fatalf("Failed to parse filter flags: %v", err)
+	}
+	if cfg.PinPathArg != "" {
+		cfg.Pin.Path = cfg.PinPathArg
+	}
+	// 节点争用标记基于内核态直方图计算 p99，未开启聚合时按 cgroup 聚合
+	if cfg.Kubernetes.Enable && cfg.Kubernetes.NodeStatus.Enable && cfg.Sched.Aggregation.Mode == "" {
+		cfg.Sched.Aggregation.Mode = config.AggregationByCgroup
+	}
 
 	stopChan := make(chan struct{})
 	defer close(stopChan)
@@ -71,20 +96,16 @@ func Run(cfg config.Configuration) {
 		log.Fatalf("failed to set temporary rlimit: %s", err)
 	}
 
-	// 获取 BPF 程序的入口函数名
-	var btfSpec *btf.Spec
-	var err error
-	if cfg.BTF.Kernel != "" {
-		btfSpec, err = btf.LoadSpec(cfg.BTF.Kernel)
-	} else {
-		// 从 /sys/kernel/btf/vmlinux 加载内核 BTF 规范
-		btfSpec, err = btf.LoadKernelSpec()
-	}
-
+	// 加载内核 BTF，内核未内置 BTF 时从 btf.model_dir 中查找
+	btfSpec, btfSource, err := bpf.LoadKernelBTF(cfg.BTF)
 	if err != nil {
-		log.Fatalf("Failed to load BTF spec: %s", err)
+		log.Fatalf("Failed to load BTF spec: %v", err)
 	}
 
+	// 探测内核特性，据此选择程序变体
+	features := bpf.ProbeFeatures(btfSource)
+	features.Log()
+
 	var opts ebpf.CollectionOptions
 	opts.Programs.KernelTypes = btfSpec
 	opts.Programs.LogLevel = ebpf.LogLevelInstruction
@@ -95,34 +116,229 @@ func Run(cfg config.Configuration) {
 	if err != nil {
 		log.Fatalf("Failed to load bpf spec: %v", err)
 	}
+	// 可选探针在主程序集加载后按配置单独加载
+	optionalProbes := bpf.TakeOptionalProbes(bpfSpec)
+	if err := bpf.SelectProgramVariants(bpfSpec, features); err != nil {
+		log.Fatalf("Failed to select program variants: %v", err)
+	}
+
+	// 有状态的 map 固定到 bpffs，重启后复用
+	pinPath := bpf.PinPath(cfg.Pin)
+	if pinPath != "" {
+		if err := bpf.PinMaps(bpfSpec, pinPath); err != nil {
+			log.Fatalf("Failed to pin maps: %v", err)
+		}
+		opts.Maps.PinPath = pinPath
+	}
 
 	// 加载 ebpf 程序集
 	coll, err := ebpf.NewCollectionWithOptions(bpfSpec, opts)
 	if err != nil {
-		var (
-			ve          *ebpf.VerifierError
-			verifierLog string
-		)
-		if errors.As(err, &ve) {
-			verifierLog = fmt.Sprintf("Verifier error: %+v\n", ve)
-		}
-
-		log.Fatalf("Failed to load objects: %s\n%+v", verifierLog, err)
+		log.Fatalf("Failed to load objects: %s", bpf.FormatLoadError(err, verifierLogTailLines))
 	}
 	defer coll.Close()
 
 	// 附加调度跟踪点
-	schedTrace, err := bpf.AttachTracepointProgs(coll, bpf.SchedTracepointTargetProgs, "sched")
+	schedTrace, err := bpf.AttachTracepointProgs(coll, bpf.SchedTracepointTargetProgs, "sched", pinPath)
 	if err != nil {
 		log.Fatalf("Failed to attach sched tracepoint: %v", err)
 	}
 	defer schedTrace.Detach()
 
+	// 按配置将内置探针挂载到指定内核函数
+	if len(cfg.Probes.Items) > 0 {
+		kprober, err := bpf.AttachOptionalProbes(ctx, cfg.Probes, features, optionalProbes, coll, opts.Programs)
+		if err != nil {
+			log.Fatalf("Failed to attach optional probes: %v", err)
+		}
+		defer kprober.DetachKprobes()
+
+		if _, err := funcstat.NewCollector(coll, cfg.Probes); err != nil {
+			log.Fatalf("Failed to init kernel function collector: %v", err)
+		}
+	}
+
+	// 写入节点默认采集策略
+	if err := bpf.SetSchedConfig(coll, bpf.NodeSchedPolicy(cfg.Sched)); err != nil {
+		log.Fatalf("Failed to set sched config: %v", err)
+	}
+
+	// 初始化 Pod 元数据解析
+	podEnricher, err := enricher.New(cfg.Kubernetes, nodeName)
+	if err != nil {
+		log.Fatalf("Failed to init pod enricher: %v", err)
+	}
+
 	// 启动任务管理器，从 ebpf map 中获取数据并进行处理
 	tm := NewTaskManager()
 
-	tm.Add("服务器", func() error { return server.NewServer().Start() })
-	tm.Add("处理调度延迟", func() error { output.ProcessSchedDelay(coll, ctx, cfg); return nil })
+	// 按 tgid、进程名前缀与 cgroup 过滤被跟踪的进程，运行时可通过 API 修改
+	processFilter, err := filter.New(coll, cfg.Kubernetes.CgroupRoot)
+	if err != nil {
+		log.Fatalf("Failed to init process filter: %v", err)
+	}
+	if err := processFilter.Apply(cfg.Sched.Filter); err != nil {
+		log.Fatalf("Failed to apply process filter: %v", err)
+	}
+	tm.Add("进程过滤同步", func() error { return processFilter.Start(ctx) })
+
+	// 按命名空间与标签限定监控范围
+	if cfg.Kubernetes.Enable && cfg.Kubernetes.Scope.Enable {
+		monitorScope, err := scope.New(cfg.Kubernetes, podEnricher, coll)
+		if err != nil {
+			log.Fatalf("Failed to init monitoring scope: %v", err)
+		}
+		tm.Add("监控范围同步", func() error { return monitorScope.Start(ctx) })
+	}
+
+	// 通过 ShepherdPolicy 下发集群级监控策略
+	policies := policy.NewStore()
+	if cfg.Kubernetes.Enable && cfg.Kubernetes.Policy.Enable {
+		policySyncer, err := policy.NewSyncer(cfg, policies, podEnricher, coll)
+		if err != nil {
+			log.Fatalf("Failed to init policy syncer: %v", err)
+		}
+		tm.Add("策略控制器", func() error { return policy.StartController(ctx, nodeName, policies) })
+		tm.Add("策略同步", func() error { return policySyncer.Start(ctx) })
+	}
+
+	apiServer := server.NewServer()
+	apiServer.Register(processFilter.RegisterRoutes)
+	apiServer.Register(features.RegisterRoutes)
+
+	// 调试时将 BPF 程序的 bpf_printk 输出转发到日志
+	debugForwarder, err := tracepipe.New(coll, cfg.Debug)
+	if err != nil {
+		log.Fatalf("Failed to init bpf debug forwarder: %v", err)
+	}
+	apiServer.Register(debugForwarder.RegisterRoutes)
+	tm.Add("调试输出转发", func() error { return debugForwarder.Start(ctx) })
+
+	// 读取内核态直方图，导出完整的调度延迟分布
+	var aggregator *aggregate.Aggregator
+	if cfg.Sched.Aggregation.Mode != "" {
+		aggregator, err = aggregate.NewAggregator(cfg, coll, podEnricher, nodeName, ctx)
+		if err != nil {
+			log.Fatalf("Failed to init latency histogram aggregator: %v", err)
+		}
+		tm.Add("调度延迟聚合", func() error { return aggregator.Start(ctx) })
+	}
+
+	// 按 CPU 与 NUMA 节点导出运行队列长度分布
+	if cfg.Sched.RunQueue.Enable {
+		if _, err := runqueue.NewCollector(coll); err != nil {
+			log.Fatalf("Failed to init run queue collector: %v", err)
+		}
+	}
+	// 跟踪硬中断与软中断，统计等待期间的中断处理时间
+	if cfg.Sched.IRQ.Enable {
+		irqTrace, err := bpf.AttachTracepointProgs(coll, bpf.IrqTracepointTargetProgs, "irq", pinPath)
+		if err != nil {
+			log.Fatalf("Failed to attach irq tracepoint: %v", err)
+		}
+		defer irqTrace.Detach()
+
+		if _, err := irqstat.NewCollector(coll); err != nil {
+			log.Fatalf("Failed to init irq collector: %v", err)
+		}
+	}
+
+	tm.Add("Pod 元数据同步", func() error { return podEnricher.Start(ctx) })
+	// 持续被其他 Pod 抢占时在受害 Pod 上创建 Event
+	var handlers []output.Handler
+	if cfg.Kubernetes.Enable && cfg.Kubernetes.Events.Enable {
+		eventRecorder, err := k8sevent.NewRecorder(cfg.Kubernetes.Events, nodeName)
+		if err != nil {
+			log.Fatalf("Failed to init kubernetes event recorder: %v", err)
+		}
+		handlers = append(handlers, eventRecorder)
+	}
+
+	// 节点调度延迟 p99 持续过高时标记节点
+	if cfg.Kubernetes.Enable && cfg.Kubernetes.NodeStatus.Enable {
+		nodeReporter, err := nodestatus.NewReporter(cfg.Kubernetes.NodeStatus, nodeName)
+		if err != nil {
+			log.Fatalf("Failed to init node status reporter: %v", err)
+		}
+		aggregator.AddListener(nodeReporter)
+		tm.Add("节点争用标记", func() error { return nodeReporter.Start(ctx) })
+	}
+
+	// 按受害者/抢占者对计算干扰分数，识别吵闹邻居
+	if cfg.Analysis.Enable {
+		engine := analysis.NewEngine(cfg.Analysis, nodeName)
+		episodeSink, err := analysis.NewSink(cfg, ctx)
+		if err != nil {
+			log.Fatalf("Failed to init noisy neighbor episode sink: %v", err)
+		}
+		defer episodeSink.Close()
+
+		engine.AddListener(episodeSink)
+		apiServer.Register(engine.RegisterRoutes)
+		handlers = append(handlers, engine)
+		tm.Add("吵闹邻居检测", func() error { return engine.Start(ctx) })
+
+		// 限制抢占者 cgroup 的 CPU 资源，事件结束后回滚
+		if cfg.Mitigation.Enable {
+			mitigator, err := mitigation.NewController(cfg.Mitigation, nodeName)
+			if err != nil {
+				log.Fatalf("Failed to init mitigation controller: %v", err)
+			}
+			engine.AddListener(mitigator)
+			apiServer.Register(mitigator.RegisterRoutes)
+			tm.Add("吵闹邻居缓解", func() error { return mitigator.Start(ctx) })
+		}
+	} else if cfg.Mitigation.Enable {
+		log.Warning("mitigation requires analysis.enable, ignored")
+	}
+
+	// 检测 cpu.max 限流，并关联限流期间的调度延迟事件
+	if cfg.Throttle.Enable {
+		throttleTracker, err := throttle.NewTracker(cfg.Throttle, coll, podEnricher, nodeName)
+		if err != nil {
+			log.Fatalf("Failed to init cfs throttle tracker: %v", err)
+		}
+		throttleSink, err := throttle.NewSink(cfg, ctx)
+		if err != nil {
+			log.Fatalf("Failed to init cfs throttle episode sink: %v", err)
+		}
+		defer throttleSink.Close()
+
+		throttleTracker.AddListener(throttleSink)
+		apiServer.Register(throttleTracker.RegisterRoutes)
+		handlers = append(handlers, throttleTracker)
+		tm.Add("CPU 限流检测", func() error { return throttleTracker.Start(ctx) })
+	}
+
+	tm.Add("服务器", func() error { return apiServer.Start() })
+
+	// 符号化受害者与抢占者的调用栈
+	var symbolizer *stack.Symbolizer
+	if cfg.Sched.Stacks.Enable {
+		symbolizer, err = stack.NewSymbolizer(coll, cfg.Sched.Stacks)
+		if err != nil {
+			log.Fatalf("Failed to init stack symbolizer: %v", err)
+		}
+		tm.Add("解析用户栈符号", func() error { return symbolizer.Start(ctx) })
+		foldedStacks := stack.NewFoldedCollector()
+		apiServer.Register(foldedStacks.RegisterRoutes)
+		handlers = append(handlers, foldedStacks)
+	}
+
+	// 经典 tracepoint 拿不到 next 的 tgid，由用户态解析
+	var tgids *enricher.TgidResolver
+	if features.Variant == bpf.VariantTracepoint {
+		tgids, err = enricher.NewTgidResolver(coll)
+		if err != nil {
+			log.Fatalf("Failed to init tgid resolver: %v", err)
+		}
+		tm.Add("线程退出通知", func() error { return tgids.Start(ctx) })
+	}
+
+	tm.Add("处理调度延迟", func() error {
+		output.ProcessSchedDelay(coll, ctx, cfg, podEnricher, tgids, policies, symbolizer, handlers...)
+		return nil
+	})
 	// 运行所有任务
 	if err := tm.Run(); err != nil {
 		log.Errorf("错误: %v\n", err)
@@ -0,0 +1,131 @@
+package runqueue
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/binary"
+	"github.com/cen-ngc5139/shepherd/internal/bpf"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// rqSlots 与 trace.c 中 RQ_SLOTS 一致
+const rqSlots = 12
+
+// slotUpper 槽位的运行队列长度上界，最后一个槽位没有上界
+func slotUpper(i int) float64 {
+	switch {
+	case i < 8:
+		return float64(i)
+	case i < rqSlots-1:
+		return float64(int(1)<<(i-4) - 1) // 15, 31, 63
+	}
+
+	return math.Inf(1)
+}
+
+// Collector 在抓取时读取内核态累计的运行队列长度统计，按 CPU 与 NUMA 节点导出
+type Collector struct {
+	stats *ebpf.Map
+	topo  *Topology
+
+	cpuHist     *prometheus.Desc
+	nodeHist    *prometheus.Desc
+	cpuOversub  *prometheus.Desc
+	nodeOversub *prometheus.Desc
+}
+
+func NewCollector(coll *ebpf.Collection) (*Collector, error) {
+	stats, ok := coll.Maps[bpf.RqStatsMap]
+	if !ok {
+		return nil, errors.Errorf("map %s not found", bpf.RqStatsMap)
+	}
+
+	topo, err := ReadTopology()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read cpu topology")
+	}
+
+	c := &Collector{
+		stats: stats,
+		topo:  topo,
+		cpuHist: prometheus.NewDesc("sched_runqueue_length",
+			"run queue length sampled on context switch and wakeup", []string{"cpu", "node"}, nil),
+		nodeHist: prometheus.NewDesc("sched_numa_runqueue_length",
+			"run queue length sampled on context switch and wakeup by numa node", []string{"node"}, nil),
+		cpuOversub: prometheus.NewDesc("sched_oversubscribed_seconds_total",
+			"time with more than one runnable task on cpu", []string{"cpu", "node"}, nil),
+		nodeOversub: prometheus.NewDesc("sched_numa_oversubscribed_seconds_total",
+			"time with more than one runnable task summed over cpus of numa node", []string{"node"}, nil),
+	}
+	if err := prometheus.Register(c); err != nil {
+		return nil, errors.Wrap(err, "failed to register run queue collector")
+	}
+
+	log.Infof("run queue sampling enabled on %d cpus", len(topo.CPUs))
+	return c, nil
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.cpuHist
+	ch <- c.nodeHist
+	ch <- c.cpuOversub
+	ch <- c.nodeOversub
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	nodes := map[int]*binary.ShepherdRqStatsT{}
+
+	for _, cpu := range c.topo.CPUs {
+		var stats binary.ShepherdRqStatsT
+		if err := c.stats.Lookup(uint32(cpu.ID), &stats); err != nil {
+			log.Errorf("failed to lookup run queue stats of cpu %d: %v", cpu.ID, err)
+			continue
+		}
+
+		id, node := strconv.Itoa(cpu.ID), strconv.Itoa(cpu.Node)
+		c.emit(ch, c.cpuHist, c.cpuOversub, stats, id, node)
+
+		sum, ok := nodes[cpu.Node]
+		if !ok {
+			sum = &binary.ShepherdRqStatsT{}
+			nodes[cpu.Node] = sum
+		}
+		for i, n := range stats.Slots {
+			sum.Slots[i] += n
+		}
+		sum.Samples += stats.Samples
+		sum.Sum += stats.Sum
+		sum.OversubNs += stats.OversubNs
+	}
+
+	for node, stats := range nodes {
+		c.emit(ch, c.nodeHist, c.nodeOversub, *stats, strconv.Itoa(node))
+	}
+}
+
+func (c *Collector) emit(ch chan<- prometheus.Metric, hist, oversub *prometheus.Desc,
+	stats binary.ShepherdRqStatsT, labels ...string) {
+	buckets := make(map[float64]uint64, rqSlots-1)
+	var cum uint64
+	for i, n := range stats.Slots {
+		cum += n
+		if i < rqSlots-1 {
+			buckets[slotUpper(i)] = cum
+		}
+	}
+
+	metric, err := prometheus.NewConstHistogram(hist, stats.Samples, float64(stats.Sum), buckets, labels...)
+	if err != nil {
+		log.Errorf("failed to build run queue histogram %v: %v", labels, err)
+		return
+	}
+	ch <- metric
+
+	ch <- prometheus.MustNewConstMetric(oversub, prometheus.CounterValue,
+		time.Duration(stats.OversubNs).Seconds(), labels...)
+}
@@ -0,0 +1,115 @@
+package runqueue
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const sysDevicesPath = "/sys/devices/system"
+
+// CPU 逻辑 CPU 所属的 NUMA 节点、物理封装与核心
+type CPU struct {
+	ID      int `json:"id"`
+	Node    int `json:"node"`
+	Package int `json:"package"`
+	Core    int `json:"core"`
+}
+
+// Topology 在线 CPU 的拓扑，按 CPU 编号升序排列
+type Topology struct {
+	CPUs []CPU `json:"cpus"`
+}
+
+// ReadTopology 从 sysfs 读取在线 CPU 的拓扑，未开启 NUMA 时全部归属节点 0
+func ReadTopology() (*Topology, error) {
+	online, err := readCPUList(filepath.Join(sysDevicesPath, "cpu", "online"))
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := map[int]int{}
+	nodeDirs, _ := filepath.Glob(filepath.Join(sysDevicesPath, "node", "node[0-9]*"))
+	for _, dir := range nodeDirs {
+		node, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		cpus, err := readCPUList(filepath.Join(dir, "cpulist"))
+		if err != nil {
+			return nil, err
+		}
+		for _, cpu := range cpus {
+			nodes[cpu] = node
+		}
+	}
+
+	topo := &Topology{CPUs: make([]CPU, 0, len(online))}
+	for _, id := range online {
+		dir := filepath.Join(sysDevicesPath, "cpu", "cpu"+strconv.Itoa(id), "topology")
+		topo.CPUs = append(topo.CPUs, CPU{
+			ID:      id,
+			Node:    nodes[id],
+			Package: readInt(filepath.Join(dir, "physical_package_id")),
+			Core:    readInt(filepath.Join(dir, "core_id")),
+		})
+	}
+
+	return topo, nil
+}
+
+func readCPUList(path string) ([]int, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", path)
+	}
+
+	cpus, err := parseCPUList(strings.TrimSpace(string(raw)))
+	return cpus, errors.Wrapf(err, "failed to parse %s", path)
+}
+
+// parseCPUList 解析 0-3,8,10-11 格式的 CPU 列表
+func parseCPUList(list string) ([]int, error) {
+	var cpus []int
+	if list == "" {
+		return cpus, nil
+	}
+
+	for _, part := range strings.Split(list, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		start, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		end := start
+		if len(bounds) == 2 {
+			if end, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, err
+			}
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+func readInt(path string) int {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return -1
+	}
+
+	v, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return -1
+	}
+
+	return v
+}
@@ -0,0 +1,153 @@
+package scope
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/enricher"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+)
+
+const (
+	scopeCgroupsMap = "scope_cgroups"
+	scopeEnabledMap = "scope_enabled"
+
+	// resyncPeriod 兜底的全量同步周期，覆盖 Pod 事件之后才创建的容器 cgroup
+	resyncPeriod = 10 * time.Second
+)
+
+// Scope 将选中 Pod 的 cgroup id 同步到 BPF 过滤 map
+type Scope struct {
+	selector *Selector
+	enricher enricher.Enricher
+	cgroups  *enricher.CgroupResolver
+
+	cgroupMap  *ebpf.Map
+	enabledMap *ebpf.Map
+	current    map[uint64]struct{}
+}
+
+func New(cfg config.KubernetesConfig, e enricher.Enricher, coll *ebpf.Collection) (*Scope, error) {
+	selector, err := NewSelector(cfg.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	cgroupMap, ok := coll.Maps[scopeCgroupsMap]
+	if !ok {
+		return nil, errors.Errorf("map %s not found", scopeCgroupsMap)
+	}
+
+	enabledMap, ok := coll.Maps[scopeEnabledMap]
+	if !ok {
+		return nil, errors.Errorf("map %s not found", scopeEnabledMap)
+	}
+
+	return &Scope{
+		selector:   selector,
+		enricher:   e,
+		cgroups:    enricher.NewCgroupResolver(cfg.CgroupRoot),
+		cgroupMap:  cgroupMap,
+		enabledMap: enabledMap,
+		current:    make(map[uint64]struct{}),
+	}, nil
+}
+
+// Start 开启 BPF 侧过滤，并随 Pod 变化持续同步 cgroup 集合
+func (s *Scope) Start(ctx context.Context) error {
+	if err := s.enable(true); err != nil {
+		return err
+	}
+	defer func() {
+		if err := s.enable(false); err != nil {
+			log.Warningf("failed to disable scope filter: %v", err)
+		}
+	}()
+
+	changes := s.enricher.Subscribe()
+	ticker := time.NewTicker(resyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		if err := s.reconcile(); err != nil {
+			log.Warningf("failed to reconcile monitoring scope: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changes:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scope) enable(on bool) error {
+	var key, value uint32
+	if on {
+		value = 1
+	}
+
+	return errors.Wrap(s.enabledMap.Put(key, value), "failed to update scope_enabled")
+}
+
+// reconcile 计算期望的 cgroup 集合并与 BPF map 做增量同步
+func (s *Scope) reconcile() error {
+	var patterns []string
+	for _, pod := range s.enricher.Pods() {
+		if !s.selector.Matches(pod) || pod.UID == "" {
+			continue
+		}
+		// cgroupfs 驱动路径中为 pod<uid>，systemd 驱动中 '-' 被替换为 '_'
+		patterns = append(patterns, "pod"+pod.UID, "pod"+strings.ReplaceAll(pod.UID, "-", "_"))
+	}
+
+	desired := make(map[uint64]struct{})
+	if len(patterns) > 0 {
+		ids := s.cgroups.Match(func(path string) bool {
+			for _, pattern := range patterns {
+				if strings.Contains(path, pattern) {
+					return true
+				}
+			}
+			return false
+		})
+		for _, id := range ids {
+			desired[id] = struct{}{}
+		}
+	}
+
+	var added, removed int
+	var value uint8 = 1
+	for id := range desired {
+		if _, ok := s.current[id]; ok {
+			continue
+		}
+		if err := s.cgroupMap.Put(id, value); err != nil {
+			return errors.Wrapf(err, "failed to add cgroup %d to scope", id)
+		}
+		s.current[id] = struct{}{}
+		added++
+	}
+
+	for id := range s.current {
+		if _, ok := desired[id]; ok {
+			continue
+		}
+		if err := s.cgroupMap.Delete(id); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return errors.Wrapf(err, "failed to remove cgroup %d from scope", id)
+		}
+		delete(s.current, id)
+		removed++
+	}
+
+	if added > 0 || removed > 0 {
+		log.Infof("monitoring scope synced, %d cgroups selected (+%d/-%d)", len(s.current), added, removed)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package scope
+
+import (
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/metadata"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Selector 按命名空间与标签选择 Pod
+type Selector struct {
+	include map[string]struct{}
+	exclude map[string]struct{}
+	labels  []labels.Selector
+}
+
+func NewSelector(cfg config.ScopeConfig) (*Selector, error) {
+	s := &Selector{
+		include: toSet(cfg.IncludeNamespaces),
+		exclude: toSet(cfg.ExcludeNamespaces),
+	}
+
+	for _, raw := range cfg.LabelSelectors {
+		selector, err := labels.Parse(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid label selector %q", raw)
+		}
+		s.labels = append(s.labels, selector)
+	}
+
+	return s, nil
+}
+
+// Matches 判断 Pod 是否在监控范围内
+func (s *Selector) Matches(pod *metadata.PodInfo) bool {
+	if pod == nil {
+		return false
+	}
+
+	if _, ok := s.exclude[pod.Namespace]; ok {
+		return false
+	}
+
+	if len(s.include) > 0 {
+		if _, ok := s.include[pod.Namespace]; !ok {
+			return false
+		}
+	}
+
+	if len(s.labels) == 0 {
+		return true
+	}
+
+	set := labels.Set(pod.Labels)
+	for _, selector := range s.labels {
+		if selector.Matches(set) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func toSet(items []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		set[item] = struct{}{}
+	}
+
+	return set
+}
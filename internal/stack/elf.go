@@ -0,0 +1,126 @@
+package stack
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+type elfSymbol struct {
+	start uint64
+	end   uint64
+	name  string
+}
+
+// elfFile 可执行文件或共享库的函数符号与可加载段
+type elfFile struct {
+	syms  []elfSymbol
+	loads []elf.ProgHeader
+}
+
+// loadELF 读取函数符号，优先使用 .symtab/.dynsym，均不存在时回退到 DWARF 调试信息
+func loadELF(path string) (*elfFile, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open elf %s", path)
+	}
+	defer f.Close()
+
+	ef := &elfFile{}
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_LOAD {
+			ef.loads = append(ef.loads, p.ProgHeader)
+		}
+	}
+
+	symtab, _ := f.Symbols()
+	dynsym, _ := f.DynamicSymbols()
+	for _, syms := range [][]elf.Symbol{symtab, dynsym} {
+		for _, sym := range syms {
+			if elf.ST_TYPE(sym.Info) != elf.STT_FUNC || sym.Value == 0 {
+				continue
+			}
+			ef.syms = append(ef.syms, elfSymbol{start: sym.Value, end: sym.Value + sym.Size, name: sym.Name})
+		}
+	}
+
+	if len(ef.syms) == 0 {
+		ef.syms = dwarfSymbols(f)
+	}
+
+	sort.Slice(ef.syms, func(i, j int) bool { return ef.syms[i].start < ef.syms[j].start })
+	return ef, nil
+}
+
+func dwarfSymbols(f *elf.File) []elfSymbol {
+	data, err := f.DWARF()
+	if err != nil {
+		return nil
+	}
+
+	var syms []elfSymbol
+	r := data.Reader()
+	for {
+		entry, err := r.Next()
+		if err != nil || entry == nil {
+			break
+		}
+		if entry.Tag != dwarf.TagSubprogram {
+			continue
+		}
+
+		name, _ := entry.Val(dwarf.AttrName).(string)
+		low, ok := entry.Val(dwarf.AttrLowpc).(uint64)
+		if name == "" || !ok {
+			continue
+		}
+
+		// DW_AT_high_pc 可能是地址，也可能是相对 low_pc 的偏移
+		var high uint64
+		switch field := entry.AttrField(dwarf.AttrHighpc); {
+		case field == nil:
+		case field.Class == dwarf.ClassAddress:
+			high, _ = field.Val.(uint64)
+		case field.Class == dwarf.ClassConstant:
+			if off, ok := field.Val.(int64); ok {
+				high = low + uint64(off)
+			}
+		}
+		syms = append(syms, elfSymbol{start: low, end: high, name: name})
+	}
+
+	return syms
+}
+
+// resolve 将文件偏移转换为虚拟地址并查找所属函数
+func (f *elfFile) resolve(offset uint64) (string, bool) {
+	addr, ok := f.vaddr(offset)
+	if !ok {
+		return "", false
+	}
+
+	i := sort.Search(len(f.syms), func(i int) bool { return f.syms[i].start > addr })
+	if i == 0 {
+		return "", false
+	}
+
+	sym := f.syms[i-1]
+	// 大小未知的符号只要位于下一个符号之前即视为命中
+	if sym.end > sym.start && addr >= sym.end {
+		return "", false
+	}
+
+	return sym.name, true
+}
+
+func (f *elfFile) vaddr(offset uint64) (uint64, bool) {
+	for _, p := range f.loads {
+		if offset >= p.Off && offset < p.Off+p.Filesz {
+			return offset - p.Off + p.Vaddr, true
+		}
+	}
+
+	return 0, false
+}
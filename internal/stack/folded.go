@@ -0,0 +1,114 @@
+package stack
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cen-ngc5139/shepherd/internal/metadata"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RoleVictim    = "victim"
+	RoleAggressor = "aggressor"
+
+	// maxFoldedStacks 每种角色保留的不同调用栈数量上限
+	maxFoldedStacks = 10000
+)
+
+type foldedValue struct {
+	count   uint64
+	delayNs uint64
+}
+
+// FoldedCollector 按调用栈累计事件数与调度延迟，输出折叠格式供火焰图使用
+type FoldedCollector struct {
+	mu     sync.Mutex
+	stacks map[string]map[string]*foldedValue // 角色 -> 折叠栈 -> 累计值
+}
+
+func NewFoldedCollector() *FoldedCollector {
+	return &FoldedCollector{
+		stacks: map[string]map[string]*foldedValue{
+			RoleVictim:    {},
+			RoleAggressor: {},
+		},
+	}
+}
+
+func (c *FoldedCollector) Handle(event metadata.SchedEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.add(RoleVictim, metadata.CommString(event.Comm[:]), event.Stack, event.DelayNs)
+	if event.IsPreempt == 1 {
+		c.add(RoleAggressor, metadata.CommString(event.PreemptedComm[:]), event.PreemptedStack, event.DelayNs)
+	}
+}
+
+// add 调用方需持有锁
+func (c *FoldedCollector) add(role, comm string, stack *metadata.Stack, delayNs uint64) {
+	folded := stack.Folded()
+	if folded == "" {
+		return
+	}
+	key := comm + ";" + folded
+
+	stacks := c.stacks[role]
+	v, ok := stacks[key]
+	if !ok {
+		if len(stacks) >= maxFoldedStacks {
+			return
+		}
+		v = &foldedValue{}
+		stacks[key] = v
+	}
+
+	v.count++
+	v.delayNs += delayNs
+}
+
+// Folded 返回折叠格式的调用栈，weight 为 delay 时以纳秒延迟为权重，否则以事件数为权重
+func (c *FoldedCollector) Folded(role, weight string, reset bool) string {
+	c.mu.Lock()
+	stacks := c.stacks[role]
+	if reset {
+		c.stacks[role] = map[string]*foldedValue{}
+	}
+
+	lines := make([]string, 0, len(stacks))
+	for key, v := range stacks {
+		value := v.count
+		if weight == "delay" {
+			value = v.delayNs
+		}
+		lines = append(lines, fmt.Sprintf("%s %d", key, value))
+	}
+	c.mu.Unlock()
+
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// RegisterRoutes 注册折叠栈导出接口
+func (c *FoldedCollector) RegisterRoutes(r gin.IRouter) {
+	// GET /stacks/folded?role=victim|aggressor&weight=count|delay&reset=true
+	r.GET("/stacks/folded", func(ctx *gin.Context) {
+		role := ctx.DefaultQuery("role", RoleVictim)
+		if role != RoleVictim && role != RoleAggressor {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid role"})
+			return
+		}
+
+		weight := ctx.DefaultQuery("weight", "count")
+		if weight != "count" && weight != "delay" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid weight"})
+			return
+		}
+
+		ctx.String(http.StatusOK, c.Folded(role, weight, ctx.Query("reset") == "true"))
+	})
+}
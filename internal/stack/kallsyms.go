@@ -0,0 +1,73 @@
+package stack
+
+import (
+	"bufio"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/pkg/errors"
+)
+
+type kernelSymbol struct {
+	addr uint64
+	name string
+}
+
+// kallsyms 内核符号表，按地址升序排列
+type kallsyms struct {
+	syms []kernelSymbol
+}
+
+func loadKallsyms() (*kallsyms, error) {
+	path := config.GetProcPath("kallsyms")
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open %s", path)
+	}
+	defer f.Close()
+
+	var syms []kernelSymbol
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// ffffffff81000000 T _stext [module]
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+
+		switch fields[1] {
+		case "T", "t", "W", "w":
+		default:
+			continue
+		}
+
+		addr, err := strconv.ParseUint(fields[0], 16, 64)
+		if err != nil || addr == 0 {
+			continue
+		}
+		syms = append(syms, kernelSymbol{addr: addr, name: fields[2]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", path)
+	}
+
+	// kptr_restrict 生效时地址全部为 0
+	if len(syms) == 0 {
+		return nil, errors.Errorf("no usable symbol in %s, check kernel.kptr_restrict", path)
+	}
+
+	sort.Slice(syms, func(i, j int) bool { return syms[i].addr < syms[j].addr })
+	return &kallsyms{syms: syms}, nil
+}
+
+func (k *kallsyms) resolve(addr uint64) string {
+	i := sort.Search(len(k.syms), func(i int) bool { return k.syms[i].addr > addr })
+	if i == 0 {
+		return unknownFrame
+	}
+
+	return k.syms[i-1].name
+}
@@ -0,0 +1,64 @@
+package stack
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/pkg/errors"
+)
+
+// mapping /proc/<pid>/maps 中的可执行文件映射
+type mapping struct {
+	start  uint64
+	end    uint64
+	offset uint64
+	path   string
+}
+
+func readMaps(pid uint32) ([]mapping, error) {
+	path := config.GetProcPath(fmt.Sprintf("%d/maps", pid))
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open %s", path)
+	}
+	defer f.Close()
+
+	var mappings []mapping
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 7f2c4a000000-7f2c4a1c0000 r-xp 00028000 08:01 1234 /usr/lib/libc.so.6
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || !strings.Contains(fields[1], "x") || !strings.HasPrefix(fields[5], "/") {
+			continue
+		}
+
+		addrs := strings.SplitN(fields[0], "-", 2)
+		if len(addrs) != 2 {
+			continue
+		}
+		start, err1 := strconv.ParseUint(addrs[0], 16, 64)
+		end, err2 := strconv.ParseUint(addrs[1], 16, 64)
+		offset, err3 := strconv.ParseUint(fields[2], 16, 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+
+		mappings = append(mappings, mapping{start: start, end: end, offset: offset, path: fields[5]})
+	}
+
+	return mappings, scanner.Err()
+}
+
+func findMapping(mappings []mapping, addr uint64) (mapping, bool) {
+	for _, m := range mappings {
+		if addr >= m.start && addr < m.end {
+			return m, true
+		}
+	}
+
+	return mapping{}, false
+}
@@ -0,0 +1,278 @@
+package stack
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/bpf"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cen-ngc5139/shepherd/internal/metadata"
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// stackDepth 与 trace.c 中 STACK_DEPTH 一致
+	stackDepth = 127
+
+	unknownFrame = "[unknown]"
+
+	// procTTL 进程内存映射缓存的有效期，覆盖 dlopen 与 exec 后映射变化的情况
+	procTTL = 30 * time.Second
+
+	maxCachedProcs = 4096
+	maxCachedFiles = 512
+
+	// maxPendingLoads 等待后台解析的文件数上限，超出时本次不解析，下次命中时再提交
+	maxPendingLoads = 64
+)
+
+// elfLoad 待后台解析的映射文件
+type elfLoad struct {
+	key      string
+	hostPath string
+	path     string
+}
+
+type procEntry struct {
+	exe      string
+	mappings []mapping
+	loadedAt time.Time
+}
+
+// Symbolizer 读取 BPF 采集的栈 id 并符号化，缓存进程映射与 ELF 符号表。
+// ELF 与 DWARF 在后台解析，解析完成前该文件中的帧以文件名代替
+type Symbolizer struct {
+	stacks      *ebpf.Map
+	stackErrors *ebpf.Map
+	kernel      *kallsyms
+	user        bool
+
+	errorsDesc *prometheus.Desc
+
+	loads chan elfLoad
+
+	mu      sync.Mutex
+	procs   map[uint32]*procEntry
+	files   map[string]*elfFile // 以设备号、inode 与修改时间作为键，同一文件在不同容器中只解析一次
+	pending map[string]struct{}
+}
+
+func NewSymbolizer(coll *ebpf.Collection, cfg config.StacksConfig) (*Symbolizer, error) {
+	stacks, ok := coll.Maps[bpf.StackTracesMap]
+	if !ok {
+		return nil, errors.Errorf("map %s not found", bpf.StackTracesMap)
+	}
+	stackErrors, ok := coll.Maps[bpf.StackErrorsMap]
+	if !ok {
+		return nil, errors.Errorf("map %s not found", bpf.StackErrorsMap)
+	}
+
+	kernel, err := loadKallsyms()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Symbolizer{
+		stacks:      stacks,
+		stackErrors: stackErrors,
+		kernel:      kernel,
+		user:        cfg.User,
+		errorsDesc: prometheus.NewDesc("sched_stack_errors_total",
+			"stacks that could not be captured, e.g. user stacks of kernel threads or faulted frames", nil, nil),
+		loads:   make(chan elfLoad, maxPendingLoads),
+		procs:   make(map[uint32]*procEntry),
+		files:   make(map[string]*elfFile),
+		pending: make(map[string]struct{}),
+	}
+	if err := prometheus.Register(s); err != nil {
+		return nil, errors.Wrap(err, "failed to register stack collector")
+	}
+
+	return s, nil
+}
+
+// Start 在后台解析映射文件的符号表，避免在事件处理路径上读取 ELF 与 DWARF
+func (s *Symbolizer) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case load := <-s.loads:
+			s.load(load)
+		}
+	}
+}
+
+func (s *Symbolizer) load(load elfLoad) {
+	f, err := loadELF(load.hostPath)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.pending, load.key)
+	if err != nil {
+		// 进程在解析前退出时下次命中再解析，其余错误同样缓存，避免重复解析
+		if os.IsNotExist(errors.Cause(err)) {
+			return
+		}
+		log.Warningf("failed to load symbols of %s: %v", load.path, err)
+	}
+
+	if len(s.files) >= maxCachedFiles {
+		s.files = make(map[string]*elfFile)
+	}
+	s.files[load.key] = f
+}
+
+func (s *Symbolizer) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.errorsDesc
+}
+
+func (s *Symbolizer) Collect(ch chan<- prometheus.Metric) {
+	var values []uint64
+	if err := s.stackErrors.Lookup(uint32(0), &values); err != nil {
+		log.Errorf("failed to lookup %s: %v", bpf.StackErrorsMap, err)
+		return
+	}
+
+	var total uint64
+	for _, v := range values {
+		total += v
+	}
+	ch <- prometheus.MustNewConstMetric(s.errorsDesc, prometheus.CounterValue, float64(total))
+}
+
+// Symbolize 符号化进程的内核栈与用户栈，均未采集时返回 nil
+func (s *Symbolizer) Symbolize(pid uint32, kernStackID, userStackID int32) *metadata.Stack {
+	var stack metadata.Stack
+
+	for _, addr := range s.lookup(kernStackID) {
+		stack.Kernel = append(stack.Kernel, s.kernel.resolve(addr))
+	}
+
+	if s.user {
+		if addrs := s.lookup(userStackID); len(addrs) > 0 {
+			stack.User = s.resolveUser(pid, addrs)
+		}
+	}
+
+	if len(stack.Kernel) == 0 && len(stack.User) == 0 {
+		return nil
+	}
+
+	return &stack
+}
+
+func (s *Symbolizer) lookup(id int32) []uint64 {
+	if id < 0 {
+		return nil
+	}
+
+	var frames [stackDepth]uint64
+	if err := s.stacks.Lookup(uint32(id), &frames); err != nil {
+		return nil
+	}
+
+	for i, addr := range frames {
+		if addr == 0 {
+			return frames[:i]
+		}
+	}
+
+	return frames[:]
+}
+
+func (s *Symbolizer) resolveUser(pid uint32, addrs []uint64) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	frames := make([]string, 0, len(addrs))
+	proc := s.proc(pid)
+	for _, addr := range addrs {
+		if proc == nil {
+			frames = append(frames, unknownFrame)
+			continue
+		}
+
+		m, ok := findMapping(proc.mappings, addr)
+		if !ok {
+			frames = append(frames, unknownFrame)
+			continue
+		}
+
+		name, ok := "", false
+		if f := s.file(pid, proc, m.path); f != nil {
+			name, ok = f.resolve(addr - m.start + m.offset)
+		}
+		if !ok {
+			name = "[" + filepath.Base(m.path) + "]"
+		}
+		frames = append(frames, name)
+	}
+
+	return frames
+}
+
+// proc 返回进程的内存映射，调用方需持有锁
+func (s *Symbolizer) proc(pid uint32) *procEntry {
+	if p, ok := s.procs[pid]; ok && time.Since(p.loadedAt) < procTTL {
+		return p
+	}
+
+	mappings, err := readMaps(pid)
+	if err != nil {
+		// 进程已退出
+		delete(s.procs, pid)
+		return nil
+	}
+
+	if len(s.procs) >= maxCachedProcs {
+		s.procs = make(map[uint32]*procEntry)
+	}
+
+	exe, _ := os.Readlink(config.GetProcPath(fmt.Sprintf("%d/exe", pid)))
+	p := &procEntry{exe: exe, mappings: mappings, loadedAt: time.Now()}
+	s.procs[pid] = p
+	return p
+}
+
+// file 返回映射文件的符号表，未解析时提交后台解析并返回 nil，调用方需持有锁
+func (s *Symbolizer) file(pid uint32, proc *procEntry, path string) *elfFile {
+	// 通过 /proc/<pid>/exe 与 /proc/<pid>/root 访问容器内的文件
+	hostPath := config.GetProcPath(fmt.Sprintf("%d/root%s", pid, path))
+	if path == proc.exe {
+		hostPath = config.GetProcPath(fmt.Sprintf("%d/exe", pid))
+	}
+
+	info, err := os.Stat(hostPath)
+	if err != nil {
+		return nil
+	}
+	key := hostPath
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		key = fmt.Sprintf("%d:%d:%d", st.Dev, st.Ino, info.ModTime().UnixNano())
+	}
+
+	if f, ok := s.files[key]; ok {
+		return f
+	}
+	if _, ok := s.pending[key]; ok {
+		return nil
+	}
+
+	select {
+	case s.loads <- elfLoad{key: key, hostPath: hostPath, path: path}:
+		s.pending[key] = struct{}{}
+	default:
+	}
+
+	return nil
+}
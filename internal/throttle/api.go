@@ -0,0 +1,28 @@
+package throttle
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes 注册限流事件查询接口
+func (t *Tracker) RegisterRoutes(r gin.IRouter) {
+	r.GET("/throttling/episodes", t.listEpisodes)
+}
+
+// listEpisodes GET /throttling/episodes?state=active|resolved
+func (t *Tracker) listEpisodes(c *gin.Context) {
+	state := EpisodeState(c.Query("state"))
+	if state != "" && state != EpisodeActive && state != EpisodeResolved {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid state"})
+		return
+	}
+
+	episodes := t.Episodes(state)
+	if episodes == nil {
+		episodes = []Episode{}
+	}
+
+	c.JSON(http.StatusOK, episodes)
+}
@@ -0,0 +1,62 @@
+package throttle
+
+import (
+	"fmt"
+	"time"
+)
+
+type EpisodeState string
+
+const (
+	EpisodeActive   EpisodeState = "active"
+	EpisodeResolved EpisodeState = "resolved"
+)
+
+// Episode 一次 cgroup 持续被 CFS 带宽限流的事件
+type Episode struct {
+	ID             string       `json:"id"`
+	Node           string       `json:"node"`
+	State          EpisodeState `json:"state"`
+	CgroupID       uint64       `json:"cgroup_id"`
+	Cgroup         string       `json:"cgroup"` // 相对 cgroup 挂载点的路径
+	Pod            string       `json:"pod,omitempty"`
+	Namespace      string       `json:"namespace,omitempty"`
+	PodUID         string       `json:"pod_uid,omitempty"`
+	Container      string       `json:"container,omitempty"`
+	StartedAt      time.Time    `json:"started_at"`
+	EndedAt        time.Time    `json:"ended_at,omitempty"`
+	Periods        uint64       `json:"periods"`          // 事件期间的限流次数
+	ThrottledNs    uint64       `json:"throttled_ns"`     // 事件期间的累计限流时长
+	LatencyEvents  uint64       `json:"latency_events"`   // 事件期间该 cgroup 及其子 cgroup 中上报的调度延迟事件数
+	LatencyDelayNs uint64       `json:"latency_delay_ns"` // 上述事件的累计调度延迟
+
+	lastThrottled time.Time
+}
+
+func newEpisode(node string, t target, now time.Time) *Episode {
+	return &Episode{
+		ID:        fmt.Sprintf("%s-%d-%d", node, t.id, now.UnixNano()),
+		Node:      node,
+		State:     EpisodeActive,
+		CgroupID:  t.id,
+		Cgroup:    t.path,
+		Pod:       t.pod,
+		Namespace: t.namespace,
+		PodUID:    t.podUID,
+		Container: t.container,
+		StartedAt: now,
+	}
+}
+
+func (e *Episode) Duration() time.Duration {
+	if e.EndedAt.IsZero() {
+		return time.Since(e.StartedAt)
+	}
+
+	return e.EndedAt.Sub(e.StartedAt)
+}
+
+// Listener 接收限流事件的开始与结束
+type Listener interface {
+	OnEpisode(episode Episode)
+}
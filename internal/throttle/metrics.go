@@ -0,0 +1,78 @@
+package throttle
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	ThrottledPeriods = "cfs_throttled_periods_total"
+	ThrottledSeconds = "cfs_throttled_seconds_total"
+	ThrottledDelay   = "cfs_throttled_sched_delay_seconds_total"
+	ThrottleEpisodes = "cfs_throttle_episodes_total"
+	ThrottleActive   = "cfs_throttle_active_episodes"
+)
+
+var cgroupLabels = []string{"cgroup", "pod", "namespace", "container"}
+
+type metrics struct {
+	periods  *prometheus.CounterVec
+	seconds  *prometheus.CounterVec
+	delay    *prometheus.CounterVec
+	episodes *prometheus.CounterVec
+	active   prometheus.Gauge
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		periods: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: ThrottledPeriods,
+			Help: "cfs bandwidth throttled periods",
+		}, cgroupLabels),
+		seconds: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: ThrottledSeconds,
+			Help: "time throttled by cfs bandwidth control",
+		}, cgroupLabels),
+		delay: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: ThrottledDelay,
+			Help: "scheduling latency reported while the cgroup or its ancestor was throttled",
+		}, cgroupLabels),
+		episodes: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: ThrottleEpisodes,
+			Help: "cfs throttle episodes detected",
+		}, []string{"namespace"}),
+		active: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: ThrottleActive,
+			Help: "cfs throttle episodes in progress",
+		}),
+	}
+}
+
+func labels(episode *Episode) []string {
+	return []string{episode.Cgroup, episode.Pod, episode.Namespace, episode.Container}
+}
+
+func (m *metrics) observe(episode *Episode, delta counters) {
+	m.periods.WithLabelValues(labels(episode)...).Add(float64(delta.periods))
+	m.seconds.WithLabelValues(labels(episode)...).Add(time.Duration(delta.throttledNs).Seconds())
+}
+
+func (m *metrics) observeLatency(episode *Episode, delayNs uint64) {
+	m.delay.WithLabelValues(labels(episode)...).Add(time.Duration(delayNs).Seconds())
+}
+
+// OnEpisode 结束后删除该 cgroup 的序列，避免已删除的 cgroup 持续导出
+func (m *metrics) OnEpisode(episode Episode) {
+	if episode.State == EpisodeActive {
+		m.episodes.WithLabelValues(episode.Namespace).Inc()
+		m.active.Inc()
+		return
+	}
+
+	m.active.Dec()
+	m.periods.DeleteLabelValues(labels(&episode)...)
+	m.seconds.DeleteLabelValues(labels(&episode)...)
+	m.delay.DeleteLabelValues(labels(&episode)...)
+}
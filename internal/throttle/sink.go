@@ -0,0 +1,59 @@
+package throttle
+
+import (
+	"context"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cen-ngc5139/shepherd/internal/output"
+)
+
+// episodeSinkSpec 同一事件的开始与结束由 ClickHouse 的 ReplacingMergeTree 合并
+var episodeSinkSpec = output.SinkSpec[Episode]{
+	Name:         "cfs throttle episode",
+	DefaultTopic: "shepherd-throttle-episodes",
+	InsertSQL: `
+	INSERT INTO cfs_throttle_episodes (
+		id, node, state,
+		cgroup_id, cgroup, pod, namespace, container,
+		started_at, ended_at,
+		periods, throttled_ns, latency_events, latency_delay_ns,
+		updated_at
+	)
+`,
+	Columns: func(e Episode) []any {
+		return []any{
+			e.ID, e.Node, string(e.State),
+			e.CgroupID, e.Cgroup, e.Pod, e.Namespace, e.Container,
+			e.StartedAt, e.EndedAt,
+			e.Periods, e.ThrottledNs, e.LatencyEvents, e.LatencyDelayNs,
+			time.Now(),
+		}
+	},
+}
+
+// Sink 将限流事件的开始与结束写入外部存储
+type Sink struct {
+	*output.Sink[Episode]
+}
+
+func NewSink(cfg config.Configuration, ctx context.Context) (*Sink, error) {
+	sinkCfg := cfg.Throttle.Sink
+	if sinkCfg.Type == "" {
+		sinkCfg.Type = config.OutputTypeFile
+	}
+
+	s, err := output.NewSink(cfg, sinkCfg, episodeSinkSpec, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sink{Sink: s}, nil
+}
+
+func (s *Sink) OnEpisode(episode Episode) {
+	if err := s.Write(episode); err != nil {
+		log.Errorf("failed to write cfs throttle episode %s: %v", episode.ID, err)
+	}
+}
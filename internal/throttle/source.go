@@ -0,0 +1,124 @@
+package throttle
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cen-ngc5139/shepherd/internal/binary"
+	"github.com/cen-ngc5139/shepherd/internal/bpf"
+	"github.com/cen-ngc5139/shepherd/internal/enricher"
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+)
+
+// counters cgroup 累计的限流次数与限流时长
+type counters struct {
+	periods     uint64
+	throttledNs uint64
+}
+
+// source 读取各 cgroup 的累计限流统计，只返回发生过限流的 cgroup
+type source interface {
+	read() (map[uint64]counters, error)
+}
+
+// kprobeSource 读取 throttle_cfs_rq/unthrottle_cfs_rq 在内核态累计的统计
+type kprobeSource struct {
+	stats    *ebpf.Map
+	resolver *enricher.CgroupResolver
+}
+
+func newKprobeSource(coll *ebpf.Collection, resolver *enricher.CgroupResolver) (*kprobeSource, error) {
+	stats, ok := coll.Maps[bpf.CfsThrottleStats]
+	if !ok {
+		return nil, errors.Errorf("map %s not found", bpf.CfsThrottleStats)
+	}
+
+	return &kprobeSource{stats: stats, resolver: resolver}, nil
+}
+
+func (s *kprobeSource) read() (map[uint64]counters, error) {
+	var (
+		id   uint64
+		stat binary.ShepherdCfsThrottleStatT
+	)
+
+	current := make(map[uint64]counters)
+	var removed []uint64
+	iter := s.stats.Iterate()
+	for iter.Next(&id, &stat) {
+		if _, ok := s.resolver.Resolve(id); !ok {
+			removed = append(removed, id)
+			continue
+		}
+		current[id] = counters{periods: stat.Periods, throttledNs: stat.ThrottledNs}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to iterate %s", bpf.CfsThrottleStats)
+	}
+
+	// 已删除的 cgroup 不会再被限流，清理后避免 map 被占满
+	for _, id := range removed {
+		_ = s.stats.Delete(id)
+	}
+
+	return current, nil
+}
+
+// cpuStatSource 轮询全部 cgroup 的 cpu.stat，内核函数无法挂载 kprobe 时使用
+type cpuStatSource struct {
+	root     string
+	resolver *enricher.CgroupResolver
+}
+
+func (s *cpuStatSource) read() (map[uint64]counters, error) {
+	current := make(map[uint64]counters)
+	for _, id := range s.resolver.Match(func(string) bool { return true }) {
+		path, ok := s.resolver.Path(id)
+		if !ok {
+			continue
+		}
+
+		c, err := readCPUStat(filepath.Join(s.root, path, "cpu.stat"))
+		if err != nil || c.periods == 0 {
+			// 根 cgroup 没有带宽控制，cgroup 也可能已被删除
+			continue
+		}
+		current[id] = c
+	}
+
+	return current, nil
+}
+
+func readCPUStat(path string) (counters, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return counters{}, err
+	}
+	defer f.Close()
+
+	var c counters
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "nr_throttled":
+			c.periods = v
+		case "throttled_usec":
+			c.throttledNs = v * 1000
+		}
+	}
+
+	return c, scanner.Err()
+}
@@ -0,0 +1,285 @@
+package throttle
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cen-ngc5139/shepherd/internal/bpf"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/enricher"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cen-ngc5139/shepherd/internal/metadata"
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultInterval     = time.Second
+	defaultRecoverAfter = 5 * time.Second
+
+	// maxRecentEpisodes 保留的已结束事件数量
+	maxRecentEpisodes = 256
+)
+
+// target 被限流的 cgroup 及其所属 Pod
+type target struct {
+	id        uint64
+	path      string
+	pod       string
+	namespace string
+	podUID    string
+	container string
+}
+
+// Tracker 周期读取各 cgroup 的限流统计，识别持续的限流事件，并将期间的调度延迟事件关联到限流事件
+type Tracker struct {
+	cfg      config.ThrottleConfig
+	node     string
+	source   source
+	detach   func()
+	resolver *enricher.CgroupResolver
+	enricher enricher.Enricher
+	metrics  *metrics
+
+	mu        sync.Mutex
+	primed    bool
+	last      map[uint64]counters
+	active    map[uint64]*Episode
+	recent    []Episode
+	listeners []Listener
+}
+
+func NewTracker(cfg config.ThrottleConfig, coll *ebpf.Collection, e enricher.Enricher, nodeName string) (*Tracker, error) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.RecoverAfter <= 0 {
+		cfg.RecoverAfter = defaultRecoverAfter
+	}
+	if cfg.CgroupRoot == "" {
+		cfg.CgroupRoot = enricher.DefaultCgroupRoot
+	}
+
+	t := &Tracker{
+		cfg:      cfg,
+		node:     nodeName,
+		resolver: enricher.NewCgroupResolver(cfg.CgroupRoot),
+		enricher: e,
+		metrics:  newMetrics(),
+		last:     make(map[uint64]counters),
+		active:   make(map[uint64]*Episode),
+	}
+
+	funcs := make([]string, 0, len(bpf.CfsThrottleKprobes))
+	for _, fn := range bpf.CfsThrottleKprobes {
+		funcs = append(funcs, fn)
+	}
+
+	source := cfg.Source
+	if source == "" {
+		source = config.ThrottleSourceCPUStat
+		if bpf.KernelFuncsExist(funcs...) {
+			source = config.ThrottleSourceKprobe
+		}
+	}
+
+	switch source {
+	case config.ThrottleSourceKprobe:
+		if !bpf.KernelFuncsExist(funcs...) {
+			return nil, errors.Errorf("kernel functions %v not found in kallsyms, use source cpustat", funcs)
+		}
+		s, err := newKprobeSource(coll, t.resolver)
+		if err != nil {
+			return nil, err
+		}
+		kprober := bpf.NewCustomFuncsKprober(bpf.CfsThrottleKprobes, coll)
+		t.source, t.detach = s, kprober.DetachKprobes
+	case config.ThrottleSourceCPUStat:
+		t.source = &cpuStatSource{root: cfg.CgroupRoot, resolver: t.resolver}
+	default:
+		return nil, errors.Errorf("unknown throttle source %q", cfg.Source)
+	}
+
+	t.AddListener(t.metrics)
+	log.Infof("cfs throttle detection started, source %s, interval %s", source, cfg.Interval)
+	return t, nil
+}
+
+func (t *Tracker) AddListener(l Listener) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.listeners = append(t.listeners, l)
+}
+
+// Start 周期读取限流统计，阻塞直至 ctx 结束
+func (t *Tracker) Start(ctx context.Context) error {
+	ticker := time.NewTicker(t.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if t.detach != nil {
+				t.detach()
+			}
+			return nil
+		case now := <-ticker.C:
+			if err := t.poll(now); err != nil {
+				log.Errorf("failed to read cfs throttle stats: %v", err)
+			}
+		}
+	}
+}
+
+func (t *Tracker) poll(now time.Time) error {
+	current, err := t.source.read()
+	if err != nil {
+		return err
+	}
+
+	var notify []Episode
+
+	t.mu.Lock()
+	// 首次读取只记录基线，不把启动前的累计值计入
+	primed := t.primed
+	t.primed = true
+
+	for id, c := range current {
+		prev := t.last[id]
+		t.last[id] = c
+		if !primed {
+			continue
+		}
+
+		delta := c
+		// 计数回退说明 cgroup 被删除后 inode 被复用
+		if c.periods >= prev.periods && c.throttledNs >= prev.throttledNs {
+			delta = counters{periods: c.periods - prev.periods, throttledNs: c.throttledNs - prev.throttledNs}
+		}
+		if delta.periods == 0 {
+			continue
+		}
+
+		episode, ok := t.active[id]
+		if !ok {
+			episode = newEpisode(t.node, t.resolve(id), now)
+			t.active[id] = episode
+			notify = append(notify, *episode)
+		}
+		episode.Periods += delta.periods
+		episode.ThrottledNs += delta.throttledNs
+		episode.lastThrottled = now
+		t.metrics.observe(episode, delta)
+	}
+
+	for id := range t.last {
+		if _, ok := current[id]; !ok {
+			delete(t.last, id)
+		}
+	}
+
+	for id, episode := range t.active {
+		_, exists := current[id]
+		if exists && now.Sub(episode.lastThrottled) < t.cfg.RecoverAfter {
+			continue
+		}
+
+		episode.State = EpisodeResolved
+		episode.EndedAt = now
+		delete(t.active, id)
+		t.recent = append(t.recent, *episode)
+		if len(t.recent) > maxRecentEpisodes {
+			t.recent = t.recent[len(t.recent)-maxRecentEpisodes:]
+		}
+		notify = append(notify, *episode)
+	}
+
+	listeners := t.listeners
+	t.mu.Unlock()
+
+	for _, episode := range notify {
+		if episode.State == EpisodeActive {
+			log.Infof("cfs throttle episode started: cgroup %s pod %s/%s", episode.Cgroup, episode.Namespace, episode.Pod)
+		} else {
+			log.Infof("cfs throttle episode resolved: cgroup %s, %d periods, throttled %s, %d latency events",
+				episode.Cgroup, episode.Periods, time.Duration(episode.ThrottledNs), episode.LatencyEvents)
+		}
+		for _, l := range listeners {
+			l.OnEpisode(episode)
+		}
+	}
+
+	return nil
+}
+
+// resolve 解析 cgroup 路径及所属 Pod，Pod 级 cgroup 通过路径中的 Pod UID 匹配
+func (t *Tracker) resolve(id uint64) target {
+	tg := target{id: id}
+	tg.path, _ = t.resolver.Resolve(id)
+
+	if pod, ok := t.enricher.Lookup(id); ok {
+		tg.pod, tg.namespace, tg.podUID, tg.container = pod.Name, pod.Namespace, pod.UID, pod.ContainerName
+		return tg
+	}
+
+	tg.podUID = enricher.PodUIDFromPath(tg.path)
+	if tg.podUID == "" {
+		return tg
+	}
+	for _, pod := range t.enricher.Pods() {
+		if pod.UID == tg.podUID {
+			tg.pod, tg.namespace = pod.Name, pod.Namespace
+			break
+		}
+	}
+
+	return tg
+}
+
+// Handle 将调度延迟事件关联到被延迟进程所在 cgroup 或其祖先 cgroup 的进行中限流事件
+func (t *Tracker) Handle(event metadata.SchedEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.active) == 0 || event.CgroupId == 0 {
+		return
+	}
+
+	path, ok := t.resolver.Path(event.CgroupId)
+	if !ok {
+		return
+	}
+
+	for _, episode := range t.active {
+		if episode.Cgroup == "" || (path != episode.Cgroup && !strings.HasPrefix(path, episode.Cgroup+"/")) {
+			continue
+		}
+
+		episode.LatencyEvents++
+		episode.LatencyDelayNs += event.DelayNs
+		t.metrics.observeLatency(episode, event.DelayNs)
+	}
+}
+
+// Episodes 返回进行中或最近结束的限流事件，state 为空时返回全部
+func (t *Tracker) Episodes(state EpisodeState) []Episode {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var episodes []Episode
+	if state == "" || state == EpisodeActive {
+		for _, episode := range t.active {
+			episodes = append(episodes, *episode)
+		}
+		sort.Slice(episodes, func(i, j int) bool { return episodes[i].StartedAt.Before(episodes[j].StartedAt) })
+	}
+	if state == "" || state == EpisodeResolved {
+		episodes = append(episodes, t.recent...)
+	}
+
+	return episodes
+}
@@ -0,0 +1,33 @@
+package tracepipe
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type debugState struct {
+	Enable bool `json:"enable"`
+}
+
+// RegisterRoutes 注册调试输出的查询与开关接口
+func (f *Forwarder) RegisterRoutes(r gin.IRouter) {
+	r.GET("/debug", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, debugState{Enable: f.Enabled()})
+	})
+
+	r.PUT("/debug", func(ctx *gin.Context) {
+		var state debugState
+		if err := ctx.ShouldBindJSON(&state); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := f.SetEnabled(state.Enable); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, debugState{Enable: f.Enabled()})
+	})
+}
@@ -0,0 +1,194 @@
+package tracepipe
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cen-ngc5139/shepherd/internal/bpf"
+	"github.com/cen-ngc5139/shepherd/internal/config"
+	"github.com/cen-ngc5139/shepherd/internal/log"
+	"github.com/cilium/ebpf"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+	"golang.org/x/time/rate"
+)
+
+const (
+	debugEnabledMap = "debug_enabled"
+
+	// printkMarker trace.c 中 bpf_printk 输出的前缀，trace_pipe 中还包含其他程序的输出
+	printkMarker = "bpf_trace_printk: shepherd: "
+
+	defaultRateLimit = 50
+
+	// pollTimeoutMs 等待 trace_pipe 可读的超时，超时后检查是否需要退出
+	pollTimeoutMs = 500
+)
+
+// Forwarder 开关 BPF 程序的调试输出，并将 trace_pipe 中本程序的输出按限速转发到日志
+type Forwarder struct {
+	enabledMap *ebpf.Map
+	enable     bool
+	rateLimit  int
+
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc // 非空时正在转发
+}
+
+func New(coll *ebpf.Collection, cfg config.DebugConfig) (*Forwarder, error) {
+	m, ok := coll.Maps[debugEnabledMap]
+	if !ok {
+		return nil, errors.Errorf("map %s not found", debugEnabledMap)
+	}
+
+	rateLimit := cfg.RateLimit
+	if rateLimit <= 0 {
+		rateLimit = defaultRateLimit
+	}
+
+	return &Forwarder{
+		enabledMap: m,
+		enable:     cfg.Enable,
+		rateLimit:  rateLimit,
+	}, nil
+}
+
+// Start 按配置开启调试输出，ctx 结束时关闭
+func (f *Forwarder) Start(ctx context.Context) error {
+	f.mu.Lock()
+	f.ctx = ctx
+	f.mu.Unlock()
+
+	// 开启失败不影响采集，仍可在之后通过 API 重试
+	if f.enable {
+		if err := f.SetEnabled(true); err != nil {
+			log.Warningf("failed to enable bpf debug output: %v", err)
+		}
+	}
+
+	<-ctx.Done()
+	if err := f.SetEnabled(false); err != nil {
+		log.Warningf("failed to disable bpf debug output: %v", err)
+	}
+
+	return nil
+}
+
+// Enabled 返回是否正在转发调试输出
+func (f *Forwarder) Enabled() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.cancel != nil
+}
+
+// SetEnabled 开关 BPF 侧的 bpf_printk 与 trace_pipe 转发
+func (f *Forwarder) SetEnabled(on bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.ctx == nil {
+		return errors.New("debug forwarder is not started")
+	}
+	if on == (f.cancel != nil) {
+		return nil
+	}
+
+	if !on {
+		f.cancel()
+		f.cancel = nil
+		return f.setFlag(false)
+	}
+
+	dir := bpf.TracefsDir()
+	if dir == "" {
+		return errors.New("tracefs is not mounted")
+	}
+	fd, err := unix.Open(filepath.Join(dir, "trace_pipe"), unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return errors.Wrap(err, "failed to open trace_pipe")
+	}
+	if err := f.setFlag(true); err != nil {
+		unix.Close(fd)
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(f.ctx)
+	f.cancel = cancel
+	go f.forward(ctx, fd)
+
+	log.Infof("bpf debug output enabled, forwarding %s/trace_pipe at most %d lines/s", dir, f.rateLimit)
+	return nil
+}
+
+func (f *Forwarder) setFlag(on bool) error {
+	var key, value uint32
+	if on {
+		value = 1
+	}
+
+	return errors.Wrap(f.enabledMap.Put(key, value), "failed to update debug_enabled")
+}
+
+// forward 读取 trace_pipe 直到 ctx 结束，超出限速的行被丢弃并在恢复时汇总
+func (f *Forwarder) forward(ctx context.Context, fd int) {
+	defer unix.Close(fd)
+
+	limiter := rate.NewLimiter(rate.Limit(f.rateLimit), f.rateLimit)
+	var dropped int
+
+	scanner := bufio.NewScanner(&pipeReader{ctx: ctx, fd: fd})
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.Contains(line, printkMarker) {
+			continue
+		}
+		if !limiter.Allow() {
+			dropped++
+			continue
+		}
+
+		if dropped > 0 {
+			log.Warningf("bpf debug: %d lines dropped by rate limit", dropped)
+			dropped = 0
+		}
+		// <comm>-<tid> [cpu] flags timestamp: bpf_trace_printk: shepherd: ...
+		log.Infof("bpf debug: %s", strings.TrimSpace(line))
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Warningf("failed to read trace_pipe: %v", err)
+	}
+}
+
+// pipeReader 非阻塞读取 trace_pipe，没有数据时轮询等待，ctx 结束后返回 io.EOF
+type pipeReader struct {
+	ctx context.Context
+	fd  int
+}
+
+func (r *pipeReader) Read(p []byte) (int, error) {
+	for {
+		if r.ctx.Err() != nil {
+			return 0, io.EOF
+		}
+
+		n, err := unix.Read(r.fd, p)
+		if err == nil {
+			return n, nil
+		}
+		if !errors.Is(err, unix.EAGAIN) && !errors.Is(err, unix.EINTR) {
+			return 0, err
+		}
+
+		fds := []unix.PollFd{{Fd: int32(r.fd), Events: unix.POLLIN}}
+		if _, err := unix.Poll(fds, pollTimeoutMs); err != nil && !errors.Is(err, unix.EINTR) {
+			return 0, err
+		}
+	}
+}
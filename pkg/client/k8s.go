@@ -2,6 +2,7 @@ package client
 
 import (
 	"fmt"
+	"github.com/cen-ngc5139/shepherd/api/v1alpha1"
 	"github.com/pkg/errors"
 	k8sruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
@@ -20,10 +21,12 @@ var (
 
 func init() {
 	_ = clientgoscheme.AddToScheme(Scheme)
+	_ = v1alpha1.AddToScheme(Scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
 type K8sClusterInterface interface {
+	GetK8sClient() client.Client
 	GetK8sClientSet() *kubernetes.Clientset
 	GetK8sConfig() *rest.Config
 	CreateClient() error
@@ -35,6 +38,10 @@ type K8sClusterManager struct {
 	K8sConf *rest.Config
 }
 
+func (m *K8sClusterManager) GetK8sClient() client.Client {
+	return m.K8sCli
+}
+
 func (m *K8sClusterManager) GetK8sConfig() *rest.Config {
 	return m.K8sConf
 }
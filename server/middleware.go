@@ -7,7 +7,7 @@ import (
 )
 
 func InitPrometheusMetrics(r *gin.Engine) {
-	schedMetrics := output.NewSchedMetrics(cache.SchedMetricsMap, cache.SchedPreemptedMap)
+	schedMetrics := output.NewSchedMetrics(cache.SchedMetricsMap, cache.SchedPreemptedMap, cache.SchedCPUMap, cache.SchedWakerMap, cache.SchedImbalanceMap)
 	traceMetrics := output.NewTraceMetrics(schedMetrics)
 	r.GET("/metrics", traceMetrics.MetricsHandler())
 }
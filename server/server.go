@@ -63,6 +63,11 @@ func NewServer(middleware ...gin.HandlerFunc) *Server {
 	}
 }
 
+// Register 在 /api/v1 下注册额外的接口
+func (s *Server) Register(fn func(r gin.IRouter)) {
+	fn(s.router.Group("/api/v1"))
+}
+
 func (s *Server) Start() error {
 	// Initializing the server in a goroutine so that it won't block the graceful shutdown handling below
 	go func() {